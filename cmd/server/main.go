@@ -7,20 +7,27 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"Blog-API/internal/delivery/controllers"
 	"Blog-API/internal/delivery/router"
+	"Blog-API/internal/domain"
 	"Blog-API/internal/infrastructure/ai"
 	"Blog-API/internal/infrastructure/cache"
 	"Blog-API/internal/infrastructure/database"
 	"Blog-API/internal/infrastructure/email"
 	"Blog-API/internal/infrastructure/filesystem"
 	"Blog-API/internal/infrastructure/jwt"
+	"Blog-API/internal/infrastructure/ldap"
 	"Blog-API/internal/infrastructure/middleware"
 	"Blog-API/internal/infrastructure/oauth"
 	"Blog-API/internal/infrastructure/password"
+	"Blog-API/internal/infrastructure/ratelimit"
+	"Blog-API/internal/infrastructure/scheduler"
+	"Blog-API/internal/infrastructure/secretbox"
+	"Blog-API/internal/infrastructure/totp"
 	"Blog-API/internal/infrastructure/worker"
 	"Blog-API/internal/repository"
 	"Blog-API/internal/usecase"
@@ -54,11 +61,51 @@ func main() {
 	}
 
 	//---worker Pool---
-	workerPool := worker.NewPool(4, 100)
-	workerPool.Start()
+	var workerPool domain.WorkerPool
+	var jobQueue domain.JobQueue
+	if cfg.Worker.Backend == "memory" {
+		workerPool = worker.NewPool(cfg.Worker.Workers, cfg.Worker.QueueSize)
+	} else {
+		jobQueue = worker.NewRedisQueue(redisClient, cfg.Worker.Workers, cfg.Worker.QueueSize, cfg.Worker.VisibilityTimeout)
+		workerPool = jobQueue
+	}
+	jobRetryPolicy := domain.RetryPolicy{
+		MaxAttempts:    cfg.Worker.MaxAttempts,
+		InitialBackoff: cfg.Worker.InitialBackoff,
+		MaxBackoff:     cfg.Worker.MaxBackoff,
+	}
 	//---Services---
-	passwordService := password.NewPasswordService()
-	jwtService := jwt.NewJWTService(cfg.JWT.Secret, cfg.JWT.AccessExpiry, cfg.JWT.RefreshExpiry)
+	passwordPolicy := password.Policy{
+		MinLength:   cfg.Password.MinLength,
+		MinScore:    cfg.Password.MinScore,
+		HistorySize: cfg.Password.HistorySize,
+	}
+	for _, class := range cfg.Password.RequiredClasses {
+		switch strings.TrimSpace(class) {
+		case "upper":
+			passwordPolicy.RequireUpper = true
+		case "lower":
+			passwordPolicy.RequireLower = true
+		case "number":
+			passwordPolicy.RequireNumber = true
+		case "special":
+			passwordPolicy.RequireSpecial = true
+		case "", "none":
+		default:
+			log.Fatalf("Unknown password character class %q", class)
+		}
+	}
+	passwordService := password.NewPasswordService(password.Argon2Params{
+		Memory:      uint32(cfg.Password.Argon2Memory),
+		Iterations:  uint32(cfg.Password.Argon2Iterations),
+		Parallelism: uint8(cfg.Password.Argon2Parallelism),
+	}, passwordPolicy)
+	jwtKeys, err := jwt.LoadKeySet(cfg.JWT.Algorithm, cfg.JWT.Secret, cfg.JWT.SigningKeyFile, cfg.JWT.VerificationKeyFiles)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	jwtService := jwt.NewJWTService(jwtKeys, cfg.JWT.AccessExpiry, cfg.JWT.RefreshExpiry, jwt.NewRedisDenylist(redisClient))
+	totpService := totp.NewTOTPService(cfg.TwoFactor.Issuer)
 	aiService := ai.NewAIService(cfg.AI.GroqAPIKey)
 	baseURL := fmt.Sprintf("http://localhost:%s", cfg.Server.Port)
 	fileService := filesystem.NewFileService(cfg.Upload.Path)
@@ -70,7 +117,39 @@ func main() {
 		baseURL,
 		cfg.Email.TemplatePath,
 	)
+	payloadSecret := cfg.Worker.PayloadSecret
+	if payloadSecret == "" {
+		// emails still work, but ones queued before a restart can't be sent
+		log.Println("WARNING: JOB_PAYLOAD_SECRET is not set, using a random per-process secret")
+		payloadSecret = passwordService.GenerateSecureToken(32)
+	}
+	jobSecrets, err := secretbox.NewSealer(payloadSecret)
+	if err != nil {
+		log.Fatalf("Failed to set up job payload encryption: %v", err)
+	}
+	//---job types---
+	workerPool.Register(usecase.EmailJobName, func() domain.Job {
+		return &usecase.EmailJob{EmailService: emailService, Secrets: jobSecrets}
+	}, jobRetryPolicy)
+	workerPool.Start()
+	stateSecret := cfg.OAuth.StateSecret
+	if stateSecret == "" {
+		// logins still work, but states signed before a restart won't verify
+		log.Println("WARNING: OAUTH_STATE_SECRET is not set, using a random per-process secret")
+		stateSecret = passwordService.GenerateSecureToken(32)
+	}
+	oauthState, err := oauth.NewStateService(stateSecret, cfg.OAuth.StateTTL, cfg.OAuth.AllowedRedirects, cfg.OAuth.DefaultRedirect)
+	if err != nil {
+		log.Fatalf("Failed to set up OAuth state: %v", err)
+	}
 	//---Oauth---
+	var directoryService domain.DirectoryService
+	if cfg.LDAP.URL != "" {
+		directoryService, err = ldap.NewLDAPService(ldap.Config(cfg.LDAP))
+		if err != nil {
+			log.Fatalf("Failed to set up LDAP login: %v", err)
+		}
+	}
 	googleOAuthConfig := &oauth2.Config{
 		ClientID:     cfg.OAuth.Google.ClientID,
 		ClientSecret: cfg.OAuth.Google.ClientSecret,
@@ -85,25 +164,81 @@ func main() {
 		Scopes:       cfg.OAuth.GitHub.Scopes,
 		Endpoint:     github.Endpoint,
 	}
-	oauthService := oauth.NewOAuthService(googleOAuthConfig, githubOAuthConfig)
+	var oidcProviders []oauth.OIDCConfig
+	for _, p := range cfg.OAuth.OIDC {
+		oidcProviders = append(oidcProviders, oauth.OIDCConfig(p))
+	}
+	oauthService, err := oauth.NewOAuthService(googleOAuthConfig, githubOAuthConfig, oidcProviders)
+	if err != nil {
+		log.Fatalf("Failed to set up OAuth providers: %v", err)
+	}
 	//---Oauth---
 	cacheService := cache.NewRedisCache(redisClient)
+	rateLimiter := ratelimit.NewRedisRateLimiter(redisClient)
+	loginThrottler := ratelimit.NewRedisLoginThrottler(redisClient, cfg.Login.MaxFailures, cfg.Login.IPMaxFailures, cfg.Login.FailureWindow, cfg.Login.LockoutDuration)
 	//---repositories---
 	userRepo := repository.NewUserRepository(mongoDB)
 	blogRepo := repository.NewBlogRepository(mongoDB)
 	sessionRepo := repository.NewSessionRepository(mongoDB)
+	securityEventRepo := repository.NewSecurityEventRepository(mongoDB)
+	tokenRepo := repository.NewOneTimeTokenRepository(mongoDB)
+	accessTokenRepo := repository.NewAccessTokenRepository(mongoDB)
+	roleRepo := repository.NewRoleRepository(mongoDB)
+	auditLogRepo := repository.NewAuditLogRepository(mongoDB)
+	loginHistoryRepo := repository.NewLoginHistoryRepository(mongoDB)
 	//---use cases---
-	userUseCase := usecase.NewUserUseCase(userRepo, passwordService, jwtService, sessionRepo, emailService, fileService, workerPool, oauthService)
-	blogUseCase := usecase.NewBlogUseCase(blogRepo, userRepo, cacheService)
+	sessionUseCase := usecase.NewSessionUseCase(sessionRepo)
+	auditLog := usecase.NewAuditLog(auditLogRepo)
+	roleUseCase := usecase.NewRoleUseCase(roleRepo, userRepo, auditLog)
+	userUseCase := usecase.NewUserUseCase(userRepo, passwordService, jwtService, sessionRepo, tokenRepo, emailService, fileService, workerPool, cacheService, usecase.UserUseCaseDeps{
+		OAuth:          oauthService,
+		OAuthState:     oauthState,
+		TOTP:           totpService,
+		Directory:      directoryService,
+		RateLimiter:    rateLimiter,
+		LoginThrottler: loginThrottler,
+		SecurityEvents: securityEventRepo,
+		LoginHistory:   loginHistoryRepo,
+		AccessTokens:   accessTokenRepo,
+		Permissions:    roleUseCase,
+		Audit:          auditLog,
+		Secrets:        jobSecrets,
+	})
+	blogUseCase := usecase.NewBlogUseCase(blogRepo, userRepo, cacheService, roleUseCase, auditLog)
 	aiUseCase := usecase.NewAIUseCase(aiService)
+	adminUseCase := usecase.NewAdminUseCase(userRepo, blogRepo, sessionRepo, securityEventRepo, cacheService, loginThrottler, accessTokenRepo, roleUseCase, auditLog, jwtService)
+	//---scheduler---
+	jobScheduler := scheduler.NewScheduler(redisClient, workerPool, jobRetryPolicy)
+	if err := jobScheduler.Register("session_cleanup", cfg.Scheduler.SessionCleanupSchedule, func() domain.Job {
+		return &usecase.SessionCleanupJob{SessionUseCase: sessionUseCase}
+	}); err != nil {
+		log.Fatalf("Failed to register schedule: %v", err)
+	}
+	if jobQueue != nil {
+		if err := jobScheduler.Register("dead_letter_cleanup", cfg.Scheduler.DeadLetterCleanupSchedule, func() domain.Job {
+			return &usecase.DeadLetterCleanupJob{Queue: jobQueue, Retention: cfg.Scheduler.DeadLetterRetention}
+		}); err != nil {
+			log.Fatalf("Failed to register schedule: %v", err)
+		}
+	}
+	// expired one-time tokens, access tokens and old login history need no
+	// schedule: MongoDB TTL indexes on those collections remove them
 	//---handlers---
 	userHandler := controllers.NewUserHandler(userUseCase)
 	blogHandler := controllers.NewBlogHandler(blogUseCase)
 	aiHandler := controllers.NewAIHandler(aiUseCase)
-	oauthHandler := controllers.NewOAuthHandler(userUseCase, oauthService, cfg.OAuth.StateSecret)
+	oauthHandler := controllers.NewOAuthHandler(userUseCase)
+	jobHandler := controllers.NewJobHandler(workerPool, jobQueue, jobScheduler)
+	adminHandler := controllers.NewAdminHandler(adminUseCase)
+	sessionHandler := controllers.NewSessionHandler(sessionUseCase)
+	jwksHandler := controllers.NewJWKSHandler(jwtService)
+	twoFactorHandler := controllers.NewTwoFactorHandler(userUseCase)
+	accessTokenHandler := controllers.NewAccessTokenHandler(userUseCase)
+	roleHandler := controllers.NewRoleHandler(roleUseCase)
+	auditHandler := controllers.NewAuditHandler(auditLog)
 
-	authMiddleware := middleware.NewAuthMiddleware(jwtService, sessionRepo)
-	router := router.SetupRouter(userHandler, blogHandler, aiHandler, oauthHandler, authMiddleware)
+	authMiddleware := middleware.NewAuthMiddleware(jwtService, sessionRepo, userRepo, accessTokenRepo, roleUseCase, auditLog)
+	router := router.SetupRouter(userHandler, blogHandler, aiHandler, oauthHandler, jobHandler, adminHandler, sessionHandler, jwksHandler, twoFactorHandler, accessTokenHandler, roleHandler, auditHandler, authMiddleware)
 
 	//Graceful server shutdown logic S
 
@@ -111,6 +246,9 @@ func main() {
 		Addr:    ":" + cfg.Server.Port,
 		Handler: router,
 	}
+	if cfg.Scheduler.Enabled {
+		jobScheduler.Start()
+	}
 	go func() {
 		log.Printf("Server starting on port %s", cfg.Server.Port)
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -129,7 +267,15 @@ func main() {
 	if err := httpServer.Shutdown(ctx); err != nil {
 		log.Printf("Server forced to shutdown: %v", err)
 	}
-	workerPool.Shutdown()
+	if cfg.Scheduler.Enabled {
+		jobScheduler.Stop()
+	}
+	// let queued jobs finish, within limits
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), cfg.Worker.ShutdownTimeout)
+	defer drainCancel()
+	if err := workerPool.Shutdown(drainCtx); err != nil {
+		log.Printf("Worker pool did not drain cleanly: %v", err)
+	}
 	if err := redisClient.Close(); err != nil {
 		log.Printf("Failed to close Redis client: %v", err)
 	}
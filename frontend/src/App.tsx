@@ -13,6 +13,7 @@ import CreateBlog from '@/pages/CreateBlog';
 import Profile from '@/pages/Profile';
 import AITools from '@/pages/AITools';
 import AdminPanel from '@/pages/AdminPanel';
+import OAuthCallback from '@/pages/OAuthCallback';
 import '@/index.css';
 
 const queryClient = new QueryClient({
@@ -61,6 +62,7 @@ const App: React.FC = () => {
                 <Route path="/" element={<Home />} />
                 <Route path="/login" element={<Login />} />
                 <Route path="/register" element={<Register />} />
+                <Route path="/oauth/callback" element={<OAuthCallback />} />
                 <Route path="/blogs" element={<Blogs />} />
                 <Route path="/blogs/:id" element={<BlogDetail />} />
                 <Route
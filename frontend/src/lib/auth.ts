@@ -7,6 +7,9 @@ import {
   UpdateProfileRequest,
 } from "@/types";
 
+// the page the API sends the browser back to after an OAuth login
+const oauthReturnTo = (): string => `${window.location.origin}/oauth/callback`;
+
 export const authAPI = {
   login: async (data: LoginRequest): Promise<LoginResponse> => {
     const response = await api.post("/auth/login", data);
@@ -54,11 +57,19 @@ export const authAPI = {
   },
 
   getGoogleOAuthURL: (): string => {
-    return `${api.defaults.baseURL}/auth/google/login`;
+    return `${api.defaults.baseURL}/auth/google/login?return_to=${encodeURIComponent(oauthReturnTo())}`;
   },
 
   getGitHubOAuthURL: (): string => {
-    return `${api.defaults.baseURL}/auth/github/login`;
+    return `${api.defaults.baseURL}/auth/github/login?return_to=${encodeURIComponent(oauthReturnTo())}`;
+  },
+
+  // the API answers with snake_case fields here
+  exchangeOAuthCode: async (
+    code: string
+  ): Promise<{ user: User; access_token: string; refresh_token: string }> => {
+    const response = await api.post("/auth/login/oauth", { code });
+    return response.data;
   },
 
   getProfile: async (): Promise<User> => {
@@ -2,6 +2,7 @@ import React, { useState } from 'react';
 import { Link, useNavigate } from 'react-router-dom';
 import { useForm } from 'react-hook-form';
 import { useAuth } from '@/contexts/AuthContext';
+import { authAPI } from '@/lib/auth';
 import { Eye, EyeOff, Mail, Lock } from 'lucide-react';
 import toast from 'react-hot-toast';
 
@@ -160,14 +161,14 @@ const Login: React.FC = () => {
 
             <div className="mt-6 grid grid-cols-2 gap-3">
               <a
-                href="/api/v1/auth/google/login"
+                href={authAPI.getGoogleOAuthURL()}
                 className="w-full inline-flex justify-center py-2 px-4 border border-gray-300 rounded-md shadow-sm bg-white text-sm font-medium text-gray-500 hover:bg-gray-50"
               >
                 Google
               </a>
 
               <a
-                href="/api/v1/auth/github/login"
+                href={authAPI.getGitHubOAuthURL()}
                 className="w-full inline-flex justify-center py-2 px-4 border border-gray-300 rounded-md shadow-sm bg-white text-sm font-medium text-gray-500 hover:bg-gray-50"
               >
                 GitHub
@@ -0,0 +1,60 @@
+import React, { useEffect, useRef } from 'react';
+import { useNavigate } from 'react-router-dom';
+import toast from 'react-hot-toast';
+import { authAPI } from '@/lib/auth';
+import LoadingSpinner from '@/components/LoadingSpinner';
+
+// The API redirects here after an OAuth login with a one-time code in the
+// URL fragment, which is traded for the tokens.
+const OAuthCallback: React.FC = () => {
+  const navigate = useNavigate();
+  const handled = useRef(false);
+
+  useEffect(() => {
+    if (handled.current) return;
+    handled.current = true;
+
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    // keep the code out of the history
+    window.history.replaceState(null, '', window.location.pathname);
+
+    const error = params.get('error');
+    if (error) {
+      toast.error(error);
+      navigate('/login', { replace: true });
+      return;
+    }
+    if (params.get('linked')) {
+      toast.success('Account linked successfully');
+      navigate('/profile', { replace: true });
+      return;
+    }
+
+    const code = params.get('code');
+    if (!code) {
+      navigate('/login', { replace: true });
+      return;
+    }
+
+    authAPI
+      .exchangeOAuthCode(code)
+      .then((response) => {
+        localStorage.setItem('accessToken', response.access_token);
+        localStorage.setItem('refreshToken', response.refresh_token);
+        // reload so the auth context picks up the new tokens
+        window.location.replace('/');
+      })
+      .catch(() => {
+        toast.error('Login failed, please try again');
+        navigate('/login', { replace: true });
+      });
+  }, [navigate]);
+
+  return (
+    <div className="min-h-screen flex items-center justify-center">
+      <LoadingSpinner size="lg" />
+    </div>
+  );
+};
+
+export default OAuthCallback;
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"Blog-API/internal/domain"
+	"Blog-API/internal/infrastructure/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AccessTokenHandler struct {
+	userUseCase domain.UserUseCase
+	validate    *validator.Validate
+}
+
+func NewAccessTokenHandler(userUseCase domain.UserUseCase) *AccessTokenHandler {
+	return &AccessTokenHandler{
+		userUseCase: userUseCase,
+		validate:    validator.New(),
+	}
+}
+
+// lists the user's personal access tokens, without the tokens themselves
+func (h *AccessTokenHandler) ListTokens(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	tokens, err := h.userUseCase.ListAccessTokens(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+func (h *AccessTokenHandler) CreateToken(c *gin.Context) {
+	claims, ok := middleware.GetTokenClaimsFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "User not authenticated"})
+		return
+	}
+	var req domain.CreateAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid request data: " + err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Validation failed: " + err.Error()})
+		return
+	}
+
+	resp, err := h.userUseCase.CreateAccessToken(claims, &req, clientInfo(c))
+	if err != nil {
+		c.JSON(accessTokenErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *AccessTokenHandler) RevokeToken(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	tokenID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid token ID"})
+		return
+	}
+
+	if err := h.userUseCase.RevokeAccessToken(userID, tokenID, clientInfo(c)); err != nil {
+		c.JSON(accessTokenErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Access token revoked successfully"})
+}
+
+func accessTokenErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "recent authentication required"):
+		return http.StatusForbidden
+	case strings.Contains(err.Error(), "more than"):
+		return http.StatusConflict
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
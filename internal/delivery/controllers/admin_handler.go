@@ -0,0 +1,308 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Blog-API/internal/domain"
+	"Blog-API/internal/infrastructure/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AdminHandler struct {
+	adminUseCase domain.AdminUseCase
+	validate     *validator.Validate
+}
+
+func NewAdminHandler(adminUseCase domain.AdminUseCase) *AdminHandler {
+	return &AdminHandler{
+		adminUseCase: adminUseCase,
+		validate:     validator.New(),
+	}
+}
+
+// GET /admin/users?search=&role=&verified=&provider=&created_after=&created_before=&page=&limit=
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	filter := domain.UserFilter{
+		Search:        c.Query("search"),
+		Role:          c.Query("role"),
+		OAuthProvider: c.Query("provider"),
+	}
+	if verifiedStr := c.Query("verified"); verifiedStr != "" {
+		verified, err := strconv.ParseBool(verifiedStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "verified must be true or false"})
+			return
+		}
+		filter.EmailVerified = &verified
+	}
+
+	layout := "2006-01-02"
+	if afterStr := c.Query("created_after"); afterStr != "" {
+		after, err := time.Parse(layout, afterStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "invalid created_after format. Please use YYYY-MM-DD."})
+			return
+		}
+		filter.CreatedAfter = &after
+	}
+	if beforeStr := c.Query("created_before"); beforeStr != "" {
+		before, err := time.Parse(layout, beforeStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "invalid created_before format. Please use YYYY-MM-DD."})
+			return
+		}
+		// include the whole day
+		before = before.Add(24*time.Hour - time.Nanosecond)
+		filter.CreatedBefore = &before
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	users, total, err := h.adminUseCase.ListUsers(filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	c.JSON(http.StatusOK, domain.PaginationResponse{
+		Data:       users,
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	})
+}
+
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	targetUserID, ok := targetUserIDParam(c)
+	if !ok {
+		return
+	}
+	details, err := h.adminUseCase.GetUserDetails(targetUserID)
+	if err != nil {
+		c.JSON(adminErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, details)
+}
+
+func (h *AdminHandler) UpdateUser(c *gin.Context) {
+	actor := actorFromContext(c)
+	targetUserID, ok := targetUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req domain.AdminUpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid request data: " + err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Validation failed: " + err.Error()})
+		return
+	}
+
+	user, err := h.adminUseCase.UpdateUser(actor, targetUserID, &req)
+	if err != nil {
+		c.JSON(adminErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AdminHandler) VerifyUserEmail(c *gin.Context) {
+	targetUserID, ok := targetUserIDParam(c)
+	if !ok {
+		return
+	}
+	if err := h.adminUseCase.VerifyUserEmail(actorFromContext(c), targetUserID); err != nil {
+		c.JSON(adminErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email marked as verified"})
+}
+
+func (h *AdminHandler) ResetUserSessions(c *gin.Context) {
+	targetUserID, ok := targetUserIDParam(c)
+	if !ok {
+		return
+	}
+	if err := h.adminUseCase.ResetUserSessions(actorFromContext(c), targetUserID); err != nil {
+		c.JSON(adminErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User sessions reset successfully"})
+}
+
+func (h *AdminHandler) DeleteUser(c *gin.Context) {
+	actor := actorFromContext(c)
+	targetUserID, ok := targetUserIDParam(c)
+	if !ok {
+		return
+	}
+	if err := h.adminUseCase.DeleteUser(actor, targetUserID); err != nil {
+		c.JSON(adminErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+// suspends or bans a user
+func (h *AdminHandler) RestrictUser(c *gin.Context) {
+	actor := actorFromContext(c)
+	targetUserID, ok := targetUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req domain.RestrictUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid request data: " + err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Validation failed: " + err.Error()})
+		return
+	}
+
+	user, err := h.adminUseCase.RestrictUser(actor, targetUserID, &req)
+	if err != nil {
+		c.JSON(adminErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AdminHandler) ReinstateUser(c *gin.Context) {
+	targetUserID, ok := targetUserIDParam(c)
+	if !ok {
+		return
+	}
+	user, err := h.adminUseCase.ReinstateUser(actorFromContext(c), targetUserID)
+	if err != nil {
+		c.JSON(adminErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AdminHandler) ListSecurityEvents(c *gin.Context) {
+	targetUserID, ok := targetUserIDParam(c)
+	if !ok {
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	events, total, err := h.adminUseCase.ListSecurityEvents(targetUserID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	c.JSON(http.StatusOK, domain.PaginationResponse{
+		Data:       events,
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	})
+}
+
+// clears two-factor authentication for a user who lost their authenticator
+func (h *AdminHandler) ResetTwoFactor(c *gin.Context) {
+	targetUserID, ok := targetUserIDParam(c)
+	if !ok {
+		return
+	}
+	if err := h.adminUseCase.ResetTwoFactor(actorFromContext(c), targetUserID); err != nil {
+		c.JSON(adminErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully"})
+}
+
+// lifts a lockout caused by repeated failed logins
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	targetUserID, ok := targetUserIDParam(c)
+	if !ok {
+		return
+	}
+	if err := h.adminUseCase.UnlockUser(actorFromContext(c), targetUserID); err != nil {
+		c.JSON(adminErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// issues a short-lived token for seeing the API as the user does
+func (h *AdminHandler) ImpersonateUser(c *gin.Context) {
+	actor := actorFromContext(c)
+	sessionID, _ := middleware.GetSessionIDFromContext(c)
+	targetUserID, ok := targetUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req domain.ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid request data: " + err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Validation failed: " + err.Error()})
+		return
+	}
+
+	resp, err := h.adminUseCase.ImpersonateUser(actor, sessionID, targetUserID, &req)
+	if err != nil {
+		c.JSON(adminErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func targetUserIDParam(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid target user ID"})
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+func adminErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	case strings.Contains(err.Error(), "already exists"), strings.Contains(err.Error(), "not suspended or banned"), strings.Contains(err.Error(), "not enabled"):
+		return http.StatusConflict
+	case strings.Contains(err.Error(), "bans are permanent"), strings.Contains(err.Error(), "must be in the future"):
+		return http.StatusBadRequest
+	case strings.Contains(err.Error(), "cannot"):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"Blog-API/internal/domain"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditHandler struct {
+	auditLog domain.AuditLog
+}
+
+func NewAuditHandler(auditLog domain.AuditLog) *AuditHandler {
+	return &AuditHandler{auditLog: auditLog}
+}
+
+// GET /admin/audit-log?actor_id=&impersonator_id=&action=&target_type=&target_id=&result=&ip=&from=&to=&page=&limit=
+func (h *AuditHandler) ListEntries(c *gin.Context) {
+	filter := domain.AuditFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		Result:     c.Query("result"),
+		IPAddress:  c.Query("ip"),
+	}
+	if actorStr := c.Query("actor_id"); actorStr != "" {
+		actorID, err := primitive.ObjectIDFromHex(actorStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid actor ID"})
+			return
+		}
+		filter.ActorID = &actorID
+	}
+	if impersonatorStr := c.Query("impersonator_id"); impersonatorStr != "" {
+		impersonatorID, err := primitive.ObjectIDFromHex(impersonatorStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid impersonator ID"})
+			return
+		}
+		filter.ImpersonatorID = &impersonatorID
+	}
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "invalid from format. Please use RFC 3339, e.g. 2024-01-02T15:04:05Z."})
+			return
+		}
+		filter.From = &from
+	}
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "invalid to format. Please use RFC 3339, e.g. 2024-01-02T15:04:05Z."})
+			return
+		}
+		filter.To = &to
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	entries, total, err := h.auditLog.List(filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	c.JSON(http.StatusOK, domain.PaginationResponse{
+		Data:       entries,
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	})
+}
+
+// checks the whole hash chain; slow on a large log
+func (h *AuditHandler) Verify(c *gin.Context) {
+	result, err := h.auditLog.Verify()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -145,7 +145,7 @@ func (h *BlogHandler) DeleteBlog(c *gin.Context) {
 
 	//userRole := domain.RoleUser
 
-	err = h.blogUseCase.DeleteBlog(id, userID, userRole)
+	err = h.blogUseCase.DeleteBlog(id, userID, userRole, clientInfo(c))
 	if err != nil {
 		status := http.StatusInternalServerError
 		if strings.Contains(err.Error(), "not found") {
@@ -429,7 +429,7 @@ func (h *BlogHandler) DeleteComment(c *gin.Context) {
 		return
 	}
 
-	if err := h.blogUseCase.DeleteComment(blogID, commentID, userID); err != nil {
+	if err := h.blogUseCase.DeleteComment(blogID, commentID, userID, clientInfo(c)); err != nil {
 		status := http.StatusInternalServerError
 		if strings.Contains(err.Error(), "forbidden") {
 			status = http.StatusForbidden
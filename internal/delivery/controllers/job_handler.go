@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"Blog-API/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	workerPool domain.WorkerPool
+	jobQueue   domain.JobQueue
+	scheduler  domain.Scheduler
+}
+
+// jobQueue is nil when the in-memory worker backend is in use
+func NewJobHandler(workerPool domain.WorkerPool, jobQueue domain.JobQueue, scheduler domain.Scheduler) *JobHandler {
+	return &JobHandler{
+		workerPool: workerPool,
+		jobQueue:   jobQueue,
+		scheduler:  scheduler,
+	}
+}
+
+func (h *JobHandler) Stats(c *gin.Context) {
+	stats, err := h.workerPool.Stats(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+func (h *JobHandler) ListSchedules(c *gin.Context) {
+	schedules, err := h.scheduler.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
+
+func (h *JobHandler) ListDeadLetters(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	jobs, total, err := h.jobQueue.ListDeadLetters(c.Request.Context(), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	for _, job := range jobs {
+		redactPayload(job)
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	c.JSON(http.StatusOK, domain.PaginationResponse{
+		Data:       jobs,
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	})
+}
+
+func (h *JobHandler) GetDeadLetter(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	job, err := h.jobQueue.GetDeadLetter(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(deadLetterErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	redactPayload(job)
+	c.JSON(http.StatusOK, job)
+}
+
+func (h *JobHandler) RequeueDeadLetter(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	if err := h.jobQueue.RequeueDeadLetter(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(deadLetterErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Job requeued successfully"})
+}
+
+func (h *JobHandler) DeleteDeadLetter(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	if err := h.jobQueue.DeleteDeadLetter(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(deadLetterErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Dead-letter job deleted successfully"})
+}
+
+func (h *JobHandler) available(c *gin.Context) bool {
+	if h.jobQueue == nil {
+		c.JSON(http.StatusNotImplemented, domain.ErrorResponse{Error: "dead-letter jobs are only kept by the redis worker backend"})
+		return false
+	}
+	return true
+}
+
+// payload fields that could let whoever reads them into someone's account
+var secretPayloadFields = []string{"token", "sealed_token"}
+
+// hides secrets in the job's payload; the stored job keeps them for a requeue
+func redactPayload(job *domain.DeadLetterJob) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return
+	}
+	redacted := false
+	for _, field := range secretPayloadFields {
+		if _, ok := payload[field]; ok {
+			payload[field] = "[redacted]"
+			redacted = true
+		}
+	}
+	if !redacted {
+		return
+	}
+	if data, err := json.Marshal(payload); err == nil {
+		job.Payload = data
+	}
+}
+
+func deadLetterErrorStatus(err error) int {
+	if errors.Is(err, domain.ErrDeadLetterNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
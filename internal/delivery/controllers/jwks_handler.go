@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"net/http"
+
+	"Blog-API/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	jwtService domain.JWTService
+}
+
+func NewJWKSHandler(jwtService domain.JWTService) *JWKSHandler {
+	return &JWKSHandler{
+		jwtService: jwtService,
+	}
+}
+
+// publishes the public keys tokens are signed with so other services can verify them
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	// verifiers may cache briefly; rotated-in keys are published before they sign anything
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwtService.JWKS())
+}
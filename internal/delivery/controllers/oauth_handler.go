@@ -2,76 +2,204 @@ package controllers
 
 import (
 	"Blog-API/internal/domain"
-	"crypto/rand"
-	"encoding/hex"
+	"Blog-API/internal/infrastructure/middleware"
+	"errors"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type OAuthHandler struct {
-	userUseCase  domain.UserUseCase
-	oauthService domain.OAuthService
-	stateSecret  string
+	userUseCase domain.UserUseCase
+	validate    *validator.Validate
 }
 
-func NewOAuthHandler(userUseCase domain.UserUseCase, oauthService domain.OAuthService, stateSecret string) *OAuthHandler {
+func NewOAuthHandler(userUseCase domain.UserUseCase) *OAuthHandler {
 	return &OAuthHandler{
-		userUseCase:  userUseCase,
-		oauthService: oauthService,
-		stateSecret:  stateSecret,
+		userUseCase: userUseCase,
+		validate:    validator.New(),
 	}
 }
 
+// ties the signed state to the browser that started the login
+const oauthNonceCookie = "oauth_nonce"
+
 func (h *OAuthHandler) OAuthLogin(c *gin.Context) {
 	provider := c.Param("provider")
-	//generate random state for csfr protection
-	b := make([]byte, 16)
-	rand.Read(b)
-	state := hex.EncodeToString(b)
-
-	// Decide cookie security based on Gin mode: non-release => not Secure for localhost HTTP
-	secure := gin.Mode() == gin.ReleaseMode
-
-	//set state in a secure httponly cookie (Secure=false on localhost in dev)
-	c.SetCookie("oauthstate", state, int(10*time.Minute.Seconds()), "/", "localhost", secure, true)
-
-	// get the redirect url from the service
-	url, err := h.oauthService.GetAuthURL(provider, state)
+	url, flow, err := h.userUseCase.StartOAuthLogin(provider, c.Query("return_to"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: err.Error()})
 		return
 	}
+	setOAuthNonceCookie(c, flow.Nonce, int(10*time.Minute.Seconds()))
 
 	//redirect the user's browser to the provider's login page
 	c.Redirect(http.StatusTemporaryRedirect, url)
 }
+
 func (h *OAuthHandler) OAuthCallback(c *gin.Context) {
 	provider := c.Param("provider")
-	// get the state from the cookie and query Param
-	storedState, err := c.Cookie("oauthstate")
+	nonce, _ := c.Cookie(oauthNonceCookie)
+	setOAuthNonceCookie(c, "", -1)
+
+	// until the state checks out its return_to can't be trusted, so errors are JSON
+	state, flow, err := h.userUseCase.VerifyOAuthState(provider, c.Query("state"), nonce)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "missing oaut state cookie"})
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if providerErr := c.Query("error"); providerErr != "" {
+		oauthFailed(c, state, http.StatusUnauthorized, errors.New("oauth login failed at the provider: "+providerErr))
 		return
 	}
-	queryState := c.Query("state")
-
-	// Clear cookie using the same Secure decision
-	secure := gin.Mode() == gin.ReleaseMode
-	c.SetCookie("oauthstate", "", -1, "/", "localhost", secure, true)
-
 	//get the authorization code
 	code := c.Query("code")
 	if code == "" {
-		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "oauth code is missing"})
+		oauthFailed(c, state, http.StatusBadRequest, errors.New("oauth code is missing"))
+		return
+	}
+
+	// the flow was started by LinkIdentity rather than OAuthLogin
+	if state.LinkUserID != "" {
+		userID, err := primitive.ObjectIDFromHex(state.LinkUserID)
+		if err != nil {
+			oauthFailed(c, state, http.StatusBadRequest, errors.New("invalid oauth state"))
+			return
+		}
+		user, err := h.userUseCase.LinkIdentity(provider, code, userID, flow)
+		if err != nil {
+			oauthFailed(c, state, identityErrorStatus(err), err)
+			return
+		}
+		if state.ReturnTo != "" {
+			c.Redirect(http.StatusFound, state.ReturnTo+"#linked="+url.QueryEscape(provider))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": provider + " account linked successfully", "user": user})
+		return
+	}
+
+	loginResponse, err := h.userUseCase.OAuthLogin(provider, code, flow, clientInfo(c))
+	if err != nil {
+		oauthFailed(c, state, authErrorStatus(err), err)
+		return
+	}
+	if state.ReturnTo == "" {
+		c.JSON(http.StatusOK, loginResponse)
+		return
+	}
+	// the fragment never reaches a server, and the code works once, for a minute
+	handoff, err := h.userUseCase.CreateLoginHandoff(loginResponse)
+	if err != nil {
+		oauthFailed(c, state, http.StatusInternalServerError, err)
+		return
+	}
+	c.Redirect(http.StatusFound, state.ReturnTo+"#code="+url.QueryEscape(handoff))
+}
+
+// trades the code from an OAuth redirect for the login response
+func (h *OAuthHandler) RedeemLoginHandoff(c *gin.Context) {
+	var req domain.LoginHandoffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid request data: " + err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Validation failed: " + err.Error()})
 		return
 	}
 
-	loginResponse, err := h.userUseCase.OAuthLogin(provider, queryState, code, storedState)
+	loginResponse, err := h.userUseCase.RedeemLoginHandoff(req.Code)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, loginResponse)
 }
+
+// sends the browser back to the frontend with the error, or answers with JSON
+func oauthFailed(c *gin.Context, state *domain.OAuthState, status int, err error) {
+	if state.ReturnTo != "" {
+		c.Redirect(http.StatusFound, state.ReturnTo+"#error="+url.QueryEscape(err.Error()))
+		return
+	}
+	c.JSON(status, domain.ErrorResponse{Error: err.Error()})
+}
+
+// host-only, so it works on whatever domain the API is served from
+func setOAuthNonceCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oauthNonceCookie,
+		Value:    value,
+		Path:     "/api/v1/auth",
+		MaxAge:   maxAge,
+		Secure:   gin.Mode() == gin.ReleaseMode,
+		HttpOnly: true,
+		// Lax still sends it on the top-level redirect back from the provider
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// login methods of the current user
+func (h *OAuthHandler) ListIdentities(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	methods, err := h.userUseCase.GetLoginMethods(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, methods)
+}
+
+// starts linking a provider account. Returns the provider's URL instead of
+// redirecting, since the browser can't send the bearer token on a redirect.
+func (h *OAuthHandler) LinkIdentity(c *gin.Context) {
+	provider := c.Param("provider")
+	claims, ok := middleware.GetTokenClaimsFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "User not authenticated"})
+		return
+	}
+
+	authURL, flow, err := h.userUseCase.BeginIdentityLink(claims, provider, c.Query("return_to"))
+	if err != nil {
+		c.JSON(identityErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	setOAuthNonceCookie(c, flow.Nonce, int(10*time.Minute.Seconds()))
+	c.JSON(http.StatusOK, gin.H{"auth_url": authURL})
+}
+
+func (h *OAuthHandler) UnlinkIdentity(c *gin.Context) {
+	claims, ok := middleware.GetTokenClaimsFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "User not authenticated"})
+		return
+	}
+	if err := h.userUseCase.UnlinkIdentity(claims, c.Param("provider")); err != nil {
+		c.JSON(identityErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlinked successfully"})
+}
+
+func identityErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "recent authentication required"):
+		return http.StatusForbidden
+	case strings.Contains(err.Error(), "already linked"), strings.Contains(err.Error(), "cannot remove"):
+		return http.StatusConflict
+	case strings.Contains(err.Error(), "no ") && strings.Contains(err.Error(), "is linked"):
+		return http.StatusNotFound
+	case strings.Contains(err.Error(), "invalid"):
+		return http.StatusBadRequest
+	case err.Error() == "session not found":
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
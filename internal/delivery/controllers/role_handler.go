@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"Blog-API/internal/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type RoleHandler struct {
+	roleUseCase domain.RoleUseCase
+	validate    *validator.Validate
+}
+
+func NewRoleHandler(roleUseCase domain.RoleUseCase) *RoleHandler {
+	return &RoleHandler{
+		roleUseCase: roleUseCase,
+		validate:    validator.New(),
+	}
+}
+
+// every permission a role can be given
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"permissions": domain.Permissions})
+}
+
+// built-in roles first, then custom ones
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.roleUseCase.ListRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	role, err := h.roleUseCase.GetRole(c.Param("name"))
+	if err != nil {
+		c.JSON(roleErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, role)
+}
+
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req domain.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid request data: " + err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Validation failed: " + err.Error()})
+		return
+	}
+
+	role, err := h.roleUseCase.CreateRole(actorFromContext(c), &req)
+	if err != nil {
+		c.JSON(roleErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, role)
+}
+
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	var req domain.UpdateRoleDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid request data: " + err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Validation failed: " + err.Error()})
+		return
+	}
+
+	role, err := h.roleUseCase.UpdateRole(actorFromContext(c), c.Param("name"), &req)
+	if err != nil {
+		c.JSON(roleErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, role)
+}
+
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	if err := h.roleUseCase.DeleteRole(actorFromContext(c), c.Param("name")); err != nil {
+		c.JSON(roleErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+func roleErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "forbidden"), strings.Contains(err.Error(), "built-in"):
+		return http.StatusForbidden
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	case strings.Contains(err.Error(), "already exists"), strings.Contains(err.Error(), "is assigned"):
+		return http.StatusConflict
+	case strings.Contains(err.Error(), "invalid"):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"net/http"
+
+	"Blog-API/internal/domain"
+	"Blog-API/internal/infrastructure/middleware"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SessionHandler struct {
+	sessionUseCase domain.SessionUseCase
+}
+
+func NewSessionHandler(sessionUseCase domain.SessionUseCase) *SessionHandler {
+	return &SessionHandler{
+		sessionUseCase: sessionUseCase,
+	}
+}
+
+// lists the devices the user is signed in on
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	sessionID, _ := middleware.GetSessionIDFromContext(c)
+
+	sessions, err := h.sessionUseCase.ListSessions(userID, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// signs out one device
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid session ID"})
+		return
+	}
+
+	if err := h.sessionUseCase.RevokeSession(userID, sessionID); err != nil {
+		c.JSON(http.StatusNotFound, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// signs out every device except the one making the request
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	sessionID, _ := middleware.GetSessionIDFromContext(c)
+
+	if err := h.sessionUseCase.RevokeOtherSessions(userID, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Signed out of all other sessions"})
+}
+
+// where the request came from, recorded on new sessions
+func clientInfo(c *gin.Context) domain.ClientInfo {
+	impersonatorID, _ := middleware.GetImpersonatorIDFromContext(c)
+	return domain.ClientInfo{
+		IPAddress:      c.ClientIP(),
+		UserAgent:      c.Request.UserAgent(),
+		ImpersonatorID: impersonatorID,
+	}
+}
+
+// the signed-in user and where the request came from, for the audit log
+func actorFromContext(c *gin.Context) domain.Actor {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	role, _ := middleware.GetUserRoleFromContext(c)
+	return domain.Actor{
+		UserID: userID,
+		Role:   role,
+		Client: clientInfo(c),
+	}
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"Blog-API/internal/domain"
+	"Blog-API/internal/infrastructure/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type TwoFactorHandler struct {
+	userUseCase domain.UserUseCase
+	validate    *validator.Validate
+}
+
+func NewTwoFactorHandler(userUseCase domain.UserUseCase) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		userUseCase: userUseCase,
+		validate:    validator.New(),
+	}
+}
+
+// second login step for accounts with two-factor authentication
+func (h *TwoFactorHandler) VerifyLogin(c *gin.Context) {
+	var req domain.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid request data: " + err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Validation failed: " + err.Error()})
+		return
+	}
+
+	loginResponse, err := h.userUseCase.VerifyTwoFactorLogin(req.ChallengeToken, req.Code, clientInfo(c))
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, loginResponse)
+}
+
+func (h *TwoFactorHandler) Status(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	status, err := h.userUseCase.GetTwoFactorStatus(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// starts enrollment; the returned otpauth URI is usually shown as a QR code
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	setup, err := h.userUseCase.SetupTwoFactor(userID)
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, setup)
+}
+
+func (h *TwoFactorHandler) Confirm(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	req, ok := h.bindCode(c)
+	if !ok {
+		return
+	}
+	codes, err := h.userUseCase.ConfirmTwoFactor(userID, req.Code, clientInfo(c))
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, domain.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	req, ok := h.bindCode(c)
+	if !ok {
+		return
+	}
+	if err := h.userUseCase.DisableTwoFactor(userID, req.Code, clientInfo(c)); err != nil {
+		c.JSON(twoFactorErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	req, ok := h.bindCode(c)
+	if !ok {
+		return
+	}
+	codes, err := h.userUseCase.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, domain.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *TwoFactorHandler) bindCode(c *gin.Context) (*domain.TwoFactorCodeRequest, bool) {
+	var req domain.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid request data: " + err.Error()})
+		return nil, false
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Validation failed: " + err.Error()})
+		return nil, false
+	}
+	return &req, true
+}
+
+func twoFactorErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "invalid"):
+		return http.StatusUnauthorized
+	case strings.Contains(err.Error(), "too many"):
+		return http.StatusTooManyRequests
+	case strings.Contains(err.Error(), "already enabled"), strings.Contains(err.Error(), "not enabled"), strings.Contains(err.Error(), "not been set up"):
+		return http.StatusConflict
+	case strings.Contains(err.Error(), "cannot"), strings.Contains(err.Error(), "password reset is required"):
+		return http.StatusForbidden
+	}
+	var restriction *domain.AccountRestriction
+	if errors.As(err, &restriction) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
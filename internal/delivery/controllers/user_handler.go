@@ -1,7 +1,9 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"Blog-API/internal/domain"
@@ -69,15 +71,56 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := h.userUseCase.Login(req.Email, req.Password)
+	response, err := h.userUseCase.Login(req.Email, req.Password, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: err.Error()})
+		if respondRateLimited(c, err) {
+			return
+		}
+		c.JSON(authErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// logs in with directory (LDAP) credentials
+func (h *UserHandler) LDAPLogin(c *gin.Context) {
+	var req domain.LDAPLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid request data: " + err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Validation failed: " + err.Error()})
+		return
+	}
+
+	response, err := h.userUseCase.LDAPLogin(req.Username, req.Password, clientInfo(c))
+	if err != nil {
+		if respondRateLimited(c, err) {
+			return
+		}
+		c.JSON(ldapErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func ldapErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "not enabled"):
+		return http.StatusNotFound
+	case strings.Contains(err.Error(), "already exists"):
+		return http.StatusConflict
+	case strings.Contains(err.Error(), "not allowed"):
+		return http.StatusForbidden
+	case strings.Contains(err.Error(), "directory"):
+		// the directory itself failed, not the user
+		return http.StatusBadGateway
+	}
+	return authErrorStatus(err)
+}
+
 func (h *UserHandler) GetProfile(c *gin.Context) {
 	userID, exists := middleware.GetUserIDFromContext(c)
 	if !exists {
@@ -107,9 +150,9 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	response, err := h.userUseCase.RefreshToken(req.RefreshToken)
+	response, err := h.userUseCase.RefreshToken(req.RefreshToken, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: err.Error()})
+		c.JSON(authErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
 		return
 	}
 
@@ -117,13 +160,13 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 }
 
 func (h *UserHandler) Logout(c *gin.Context) {
-	userID, exists := middleware.GetUserIDFromContext(c)
+	claims, exists := middleware.GetTokenClaimsFromContext(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "User not authenticated"})
 		return
 	}
 
-	err := h.userUseCase.Logout(userID)
+	err := h.userUseCase.Logout(claims)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
 		return
@@ -159,26 +202,127 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 			status = http.StatusConflict
 		} else if err.Error() == "user not found" {
 			status = http.StatusNotFound
+		} else if errors.Is(err, domain.ErrQueueFull) {
+			c.JSON(http.StatusServiceUnavailable, domain.ErrorResponse{Error: "Too many emails are queued, please try again shortly"})
+			return
 		}
 		c.JSON(status, domain.ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	message := "Profile updated successfully"
+	if req.Email != nil && updatedUser.PendingEmail == *req.Email {
+		message = "Profile updated. Check your new email address for a link to confirm the change"
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Profile updated successfully",
+		"message": message,
 		"user":    updatedUser,
 	})
 }
 
+// the link sent to the new address; applies the email change
+func (h *UserHandler) ConfirmEmailChange(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "token is required"})
+		return
+	}
+
+	user, err := h.userUseCase.ConfirmEmailChange(token)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "invalid or expired") {
+			status = http.StatusBadRequest
+		} else if err.Error() == "email already exists" {
+			status = http.StatusConflict
+		}
+		c.JSON(status, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email address changed successfully",
+		"user":    user,
+	})
+}
+
+// the link sent to the old address; stops a pending email change
+func (h *UserHandler) CancelEmailChange(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "token is required"})
+		return
+	}
+
+	if err := h.userUseCase.CancelEmailChange(token); err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "invalid or expired") {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email change cancelled. If you didn't request it, please reset your password"})
+}
+
+// the user's own logins, newest first
+func (h *UserHandler) LoginHistory(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	events, total, err := h.userUseCase.ListLoginHistory(userID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	c.JSON(http.StatusOK, domain.PaginationResponse{
+		Data:       events,
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	})
+}
+
+// the "this wasn't me" link from a new-device alert
+func (h *UserHandler) SecureAccount(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "token is required"})
+		return
+	}
+
+	if err := h.userUseCase.SecureAccount(token, clientInfo(c)); err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "invalid or expired") {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions have been signed out. Check your email for a link to set a new password"})
+}
+
 // promotion , demotion and profile picture//
 func (h *UserHandler) PromoteUser(c *gin.Context) {
-	adminUserID, _ := middleware.GetUserIDFromContext(c)
+	actor := actorFromContext(c)
 	targetUserID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid target user ID"})
 		return
 	}
-	err = h.userUseCase.UpdateRole(adminUserID, targetUserID, domain.RoleAdmin)
+	err = h.userUseCase.UpdateRole(actor, targetUserID, domain.RoleAdmin)
 	if err != nil {
 		status := http.StatusInternalServerError
 		if strings.Contains(err.Error(), "forbidden") {
@@ -192,13 +336,13 @@ func (h *UserHandler) PromoteUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User Promoted to admin successfully"})
 }
 func (h *UserHandler) DemoteUser(c *gin.Context) {
-	adminUserID, _ := middleware.GetUserIDFromContext(c)
+	actor := actorFromContext(c)
 	targetUserID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: err.Error()})
 		return
 	}
-	err = h.userUseCase.UpdateRole(adminUserID, targetUserID, domain.RoleUser)
+	err = h.userUseCase.UpdateRole(actor, targetUserID, domain.RoleUser)
 	if err != nil {
 		status := http.StatusInternalServerError
 		if strings.Contains(err.Error(), "forbidden") {
@@ -211,6 +355,37 @@ func (h *UserHandler) DemoteUser(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Admin demoted to user successfully"})
 }
+
+// gives the user any built-in or custom role
+func (h *UserHandler) SetRole(c *gin.Context) {
+	actor := actorFromContext(c)
+	targetUserID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid target user ID"})
+		return
+	}
+	var req domain.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid request data: " + err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Validation failed: " + err.Error()})
+		return
+	}
+	err = h.userUseCase.UpdateRole(actor, targetUserID, req.Role)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "forbidden") {
+			status = http.StatusForbidden
+		} else if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully"})
+}
 func (h *UserHandler) UploadProfilePicture(c *gin.Context) {
 	userID, exists := middleware.GetUserIDFromContext(c)
 	if !exists {
@@ -304,6 +479,9 @@ func (h *UserHandler) SendPasswordResetEmail(c *gin.Context) {
 	}
 
 	if err := h.userUseCase.SendPasswordResetEmail(req.Email); err != nil {
+		if respondRateLimited(c, err) {
+			return
+		}
 		// Generic response to avoid email enumeration
 		if strings.Contains(err.Error(), "password reset email has been sent") {
 			c.JSON(http.StatusOK, domain.PasswordResetResponse{Message: "If an account exists for this email, a password reset email has been sent."})
@@ -316,6 +494,46 @@ func (h *UserHandler) SendPasswordResetEmail(c *gin.Context) {
 	c.JSON(http.StatusOK, domain.PasswordResetResponse{Message: "If an account exists for this email, a password reset email has been sent."})
 }
 
+// emails a passwordless login link
+func (h *UserHandler) SendMagicLink(c *gin.Context) {
+	var req domain.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid request data: " + err.Error()})
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Validation failed: " + err.Error()})
+		return
+	}
+
+	if err := h.userUseCase.SendMagicLink(req.Email, clientInfo(c)); err != nil {
+		if respondRateLimited(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// Generic response to avoid email enumeration
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a login link has been sent."})
+}
+
+// the link from the email; logs the user in
+func (h *UserHandler) MagicLinkLogin(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "token is required"})
+		return
+	}
+
+	loginResponse, err := h.userUseCase.MagicLinkLogin(token, clientInfo(c))
+	if err != nil {
+		c.JSON(authErrorStatus(err), domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, loginResponse)
+}
+
 func (h *UserHandler) ResetPassword(c *gin.Context) {
 	var req domain.NewPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -327,9 +545,9 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	if err := h.userUseCase.ResetPassword(req.Token, req.NewPassword); err != nil {
+	if err := h.userUseCase.ResetPassword(req.Token, req.NewPassword, clientInfo(c)); err != nil {
 		status := http.StatusInternalServerError
-		if strings.Contains(err.Error(), "invalid or expired") || strings.Contains(err.Error(), "password must") {
+		if strings.Contains(err.Error(), "invalid or expired") || strings.HasPrefix(err.Error(), "password ") {
 			status = http.StatusBadRequest
 		}
 		c.JSON(status, domain.ErrorResponse{Error: err.Error()})
@@ -338,3 +556,53 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 
 	c.JSON(http.StatusOK, domain.NewPasswordResponse{Message: "Password reset successful"})
 }
+
+// confirms the password (and two-factor code) again before sensitive changes
+func (h *UserHandler) Reauthenticate(c *gin.Context) {
+	claims, ok := middleware.GetTokenClaimsFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "User not authenticated"})
+		return
+	}
+	var req domain.ReauthenticateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{Error: "Invalid request data: " + err.Error()})
+		return
+	}
+
+	if err := h.userUseCase.Reauthenticate(claims, &req, clientInfo(c)); err != nil {
+		if respondRateLimited(c, err) {
+			return
+		}
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "invalid") {
+			status = http.StatusUnauthorized
+		} else if strings.Contains(err.Error(), "required") || strings.Contains(err.Error(), "no password") {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, domain.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Re-authenticated successfully"})
+}
+
+// answers 429 with a Retry-After header if err is a rate limit
+func respondRateLimited(c *gin.Context, err error) bool {
+	var rateLimited *domain.RateLimitError
+	if !errors.As(err, &rateLimited) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(rateLimited.RetryAfterSeconds()))
+	c.JSON(http.StatusTooManyRequests, domain.ErrorResponse{Error: rateLimited.Error()})
+	return true
+}
+
+// restricted accounts and ones waiting on a password reset get 403 so clients
+// can tell them apart from bad credentials
+func authErrorStatus(err error) int {
+	var restriction *domain.AccountRestriction
+	if errors.As(err, &restriction) || strings.Contains(err.Error(), "password reset is required") {
+		return http.StatusForbidden
+	}
+	return http.StatusUnauthorized
+}
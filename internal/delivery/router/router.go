@@ -2,6 +2,7 @@ package router
 
 import (
 	"Blog-API/internal/delivery/controllers"
+	"Blog-API/internal/domain"
 	"Blog-API/internal/infrastructure/middleware"
 
 	"github.com/gin-gonic/gin"
@@ -11,10 +12,21 @@ func SetupRouter(userHandler *controllers.UserHandler,
 	blogHandler *controllers.BlogHandler,
 	aiHandler *controllers.AIHandler,
 	oauthHandler *controllers.OAuthHandler,
+	jobHandler *controllers.JobHandler,
+	adminHandler *controllers.AdminHandler,
+	sessionHandler *controllers.SessionHandler,
+	jwksHandler *controllers.JWKSHandler,
+	twoFactorHandler *controllers.TwoFactorHandler,
+	accessTokenHandler *controllers.AccessTokenHandler,
+	roleHandler *controllers.RoleHandler,
+	auditHandler *controllers.AuditHandler,
 	authMiddleware *middleware.AuthMiddleware,
 ) *gin.Engine {
 	router := gin.Default()
 
+	// public keys for verifying our tokens
+	router.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
@@ -23,37 +35,111 @@ func SetupRouter(userHandler *controllers.UserHandler,
 		{
 			auth.POST("/register", userHandler.Register)
 			auth.POST("/login", userHandler.Login)
+			auth.POST("/login/2fa", twoFactorHandler.VerifyLogin)
+			auth.POST("/login/oauth", oauthHandler.RedeemLoginHandoff)
+			auth.POST("/login/ldap", userHandler.LDAPLogin)
+			auth.POST("/magic-link", userHandler.SendMagicLink)
+			auth.GET("/magic-link/verify", userHandler.MagicLinkLogin)
 			auth.POST("/refresh", userHandler.RefreshToken)
 			// Email and Password routes
 			auth.POST("/send-verification", userHandler.SendVerificationEmail)
 			auth.GET("/verify-email", userHandler.VerifyEmail)
 			auth.POST("/forgot-password", userHandler.SendPasswordResetEmail)
 			auth.POST("/reset-password", userHandler.ResetPassword)
+			auth.GET("/email-change/confirm", userHandler.ConfirmEmailChange)
+			auth.GET("/email-change/cancel", userHandler.CancelEmailChange)
+			auth.GET("/secure-account", userHandler.SecureAccount)
 			//Oauth routes
 			auth.GET("/:provider/login", oauthHandler.OAuthLogin)
 			auth.GET("/:provider/callback", oauthHandler.OAuthCallback)
 		}
 		// protected auth routes
+		// account security settings; off limits to impersonation tokens
 		authProtected := v1.Group("/auth")
-		authProtected.Use(authMiddleware.AuthRequired())
+		authProtected.Use(authMiddleware.AuthRequired(), authMiddleware.NoImpersonation())
 		{
 			authProtected.POST("/logout", userHandler.Logout)
+			authProtected.POST("/reauthenticate", userHandler.Reauthenticate)
+
+			// signed-in devices
+			authProtected.GET("/sessions", sessionHandler.ListSessions)
+			authProtected.DELETE("/sessions/:id", sessionHandler.RevokeSession)
+			authProtected.DELETE("/sessions", sessionHandler.RevokeOtherSessions)
+			authProtected.GET("/login-history", userHandler.LoginHistory)
+
+			// two-factor authentication
+			authProtected.GET("/2fa", twoFactorHandler.Status)
+			authProtected.POST("/2fa/setup", twoFactorHandler.Setup)
+			authProtected.POST("/2fa/confirm", twoFactorHandler.Confirm)
+			authProtected.POST("/2fa/disable", twoFactorHandler.Disable)
+			authProtected.POST("/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
 		}
 
+		// the profile can also be read with a personal access token
+		v1.GET("/users/profile", authMiddleware.AuthRequired(domain.ScopeRead), userHandler.GetProfile)
+
 		// user routes (authenticated)
 		users := v1.Group("/users")
 		users.Use(authMiddleware.AuthRequired())
 		{
-			users.GET("/profile", userHandler.GetProfile)
-			users.PUT("/profile", userHandler.UpdateProfile)
-			users.POST("/profile/picture", userHandler.UploadProfilePicture)
+			// the email address can be changed here
+			noImpersonation := authMiddleware.NoImpersonation()
+			users.PUT("/profile", noImpersonation, userHandler.UpdateProfile)
+			users.POST("/profile/picture", noImpersonation, userHandler.UploadProfilePicture)
+
+			// linked OAuth accounts
+			users.GET("/identities", oauthHandler.ListIdentities)
+			users.POST("/identities/:provider", noImpersonation, oauthHandler.LinkIdentity)
+			users.DELETE("/identities/:provider", noImpersonation, oauthHandler.UnlinkIdentity)
+
+			// personal access tokens
+			users.GET("/tokens", accessTokenHandler.ListTokens)
+			users.POST("/tokens", noImpersonation, accessTokenHandler.CreateToken)
+			users.DELETE("/tokens/:id", noImpersonation, accessTokenHandler.RevokeToken)
 		}
-		// admin only routes
+		// staff routes; each needs a permission from the user's role
 		admin := v1.Group("/admin")
-		admin.Use(authMiddleware.AuthRequired(), authMiddleware.AdminRequired())
+		admin.Use(authMiddleware.AuthRequired(), authMiddleware.NoImpersonation())
 		{
-			admin.PUT("/users/:id/promote", userHandler.PromoteUser)
-			admin.PUT("/users/:id/demote", userHandler.DemoteUser)
+			can := authMiddleware.RequirePermission
+
+			admin.PUT("/users/:id/promote", can(domain.PermissionRoleAssign), userHandler.PromoteUser)
+			admin.PUT("/users/:id/demote", can(domain.PermissionRoleAssign), userHandler.DemoteUser)
+			admin.PUT("/users/:id/role", can(domain.PermissionRoleAssign), userHandler.SetRole)
+
+			// user management
+			admin.GET("/users", can(domain.PermissionUserView), adminHandler.ListUsers)
+			admin.GET("/users/:id", can(domain.PermissionUserView), adminHandler.GetUser)
+			admin.PATCH("/users/:id", can(domain.PermissionUserUpdate), adminHandler.UpdateUser)
+			admin.DELETE("/users/:id", can(domain.PermissionUserDelete), adminHandler.DeleteUser)
+			admin.POST("/users/:id/verify-email", can(domain.PermissionUserUpdate), adminHandler.VerifyUserEmail)
+			admin.DELETE("/users/:id/sessions", can(domain.PermissionUserUpdate), adminHandler.ResetUserSessions)
+			admin.POST("/users/:id/restriction", can(domain.PermissionUserBan), adminHandler.RestrictUser)
+			admin.DELETE("/users/:id/restriction", can(domain.PermissionUserBan), adminHandler.ReinstateUser)
+			admin.GET("/users/:id/security-events", can(domain.PermissionUserView), adminHandler.ListSecurityEvents)
+			admin.DELETE("/users/:id/2fa", can(domain.PermissionUserUpdate), adminHandler.ResetTwoFactor)
+			admin.DELETE("/users/:id/lock", can(domain.PermissionUserUpdate), adminHandler.UnlockUser)
+			admin.POST("/users/:id/impersonate", can(domain.PermissionUserImpersonate), adminHandler.ImpersonateUser)
+
+			// roles and their permissions
+			admin.GET("/permissions", can(domain.PermissionRoleManage, domain.PermissionRoleAssign), roleHandler.ListPermissions)
+			admin.GET("/roles", can(domain.PermissionRoleManage, domain.PermissionRoleAssign), roleHandler.ListRoles)
+			admin.GET("/roles/:name", can(domain.PermissionRoleManage, domain.PermissionRoleAssign), roleHandler.GetRole)
+			admin.POST("/roles", can(domain.PermissionRoleManage), roleHandler.CreateRole)
+			admin.PATCH("/roles/:name", can(domain.PermissionRoleManage), roleHandler.UpdateRole)
+			admin.DELETE("/roles/:name", can(domain.PermissionRoleManage), roleHandler.DeleteRole)
+
+			// append-only record of admin actions, auth events and deletions
+			admin.GET("/audit-log", can(domain.PermissionAuditView), auditHandler.ListEntries)
+			admin.GET("/audit-log/verify", can(domain.PermissionAuditView), auditHandler.Verify)
+
+			// background jobs that ran out of retries
+			admin.GET("/jobs/stats", can(domain.PermissionJobManage), jobHandler.Stats)
+			admin.GET("/jobs/dead", can(domain.PermissionJobManage), jobHandler.ListDeadLetters)
+			admin.GET("/jobs/dead/:id", can(domain.PermissionJobManage), jobHandler.GetDeadLetter)
+			admin.POST("/jobs/dead/:id/requeue", can(domain.PermissionJobManage), jobHandler.RequeueDeadLetter)
+			admin.DELETE("/jobs/dead/:id", can(domain.PermissionJobManage), jobHandler.DeleteDeadLetter)
+			admin.GET("/schedules", can(domain.PermissionJobManage), jobHandler.ListSchedules)
 		}
 		// blog routes
 		blogs := v1.Group("/blogs")
@@ -76,21 +162,22 @@ func SetupRouter(userHandler *controllers.UserHandler,
 				filter.GET("/date", blogHandler.FilterBlogsByDate)
 			}
 
-			// protected routes (auth required)
-			blogs.Use(authMiddleware.AuthRequired())
-			blogs.POST("/", blogHandler.CreateBlog)
-			blogs.PUT("/:id", blogHandler.UpdateBlog)
-			blogs.DELETE("/:id", blogHandler.DeleteBlog)
+			// protected routes (auth required); personal access tokens need the scope
+			blogsWrite := authMiddleware.AuthRequired(domain.ScopeBlogsWrite)
+			commentsWrite := authMiddleware.AuthRequired(domain.ScopeCommentsWrite)
+			blogs.POST("/", blogsWrite, blogHandler.CreateBlog)
+			blogs.PUT("/:id", blogsWrite, blogHandler.UpdateBlog)
+			blogs.DELETE("/:id", blogsWrite, blogHandler.DeleteBlog)
 
 			//comments
 
-			blogs.POST("/:id/comments", blogHandler.AddComment)
-			blogs.PUT("/:id/comments/:commentId", blogHandler.UpdateComment)
-			blogs.DELETE("/:id/comments/:commentId", blogHandler.DeleteComment)
+			blogs.POST("/:id/comments", commentsWrite, blogHandler.AddComment)
+			blogs.PUT("/:id/comments/:commentId", commentsWrite, blogHandler.UpdateComment)
+			blogs.DELETE("/:id/comments/:commentId", commentsWrite, blogHandler.DeleteComment)
 
 			//Reactions
-			blogs.POST("/:id/like", blogHandler.LikeBlog)
-			blogs.POST("/:id/dislike", blogHandler.DislikeBlog)
+			blogs.POST("/:id/like", blogsWrite, blogHandler.LikeBlog)
+			blogs.POST("/:id/dislike", blogsWrite, blogHandler.DislikeBlog)
 		}
 
 		// AI routes (authenticated)
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// what a personal access token may be used for
+const (
+	ScopeRead          = "read"
+	ScopeBlogsWrite    = "blogs:write"
+	ScopeCommentsWrite = "comments:write"
+)
+
+var AccessTokenScopes = []string{ScopeRead, ScopeBlogsWrite, ScopeCommentsWrite}
+
+// personal access tokens start with this, so they can be told apart from JWTs
+// and spotted by secret scanners
+const AccessTokenPrefix = "bpat_"
+
+// long-lived token for scripts. Only its SHA-256 hash is stored, and Mongo
+// removes it once ExpiresAt has passed.
+type PersonalAccessToken struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"-"`
+	Name       string             `bson:"name" json:"name"`
+	TokenHash  string             `bson:"token_hash" json:"-"`
+	Hint       string             `bson:"hint" json:"hint"` // the start of the token, to recognise it in lists
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"` // nil never expires
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+}
+
+func (t *PersonalAccessToken) Expired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type PersonalAccessTokenRepository interface {
+	Create(token *PersonalAccessToken) error
+	GetByHash(tokenHash string) (*PersonalAccessToken, error)
+	// newest first
+	ListByUserID(userID primitive.ObjectID) ([]*PersonalAccessToken, error)
+	CountByUserID(userID primitive.ObjectID) (int64, error)
+	// deletes the token if it belongs to the user
+	Delete(id, userID primitive.ObjectID) error
+	DeleteByUserID(userID primitive.ObjectID) error
+	UpdateLastUsed(id primitive.ObjectID) error
+}
+
+type CreateAccessTokenRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=read blogs:write comments:write"`
+	// omitted or 0 for a token that doesn't expire
+	ExpiresInDays int `json:"expires_in_days,omitempty" validate:"omitempty,min=1,max=365"`
+}
+
+// returned once, when the token is created
+type CreateAccessTokenResponse struct {
+	Token       string               `json:"token"`
+	AccessToken *PersonalAccessToken `json:"access_token"`
+}
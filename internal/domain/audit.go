@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// audited actions
+const (
+	AuditLogin              = "auth.login"
+	AuditPasswordReset      = "auth.password_reset"
+	AuditAccountSecure      = "auth.account_secure"
+	AuditTwoFactorEnable    = "auth.two_factor_enable"
+	AuditTwoFactorDisable   = "auth.two_factor_disable"
+	AuditAccessTokenCreate  = "auth.access_token_create"
+	AuditAccessTokenRevoke  = "auth.access_token_revoke"
+	AuditUserUpdate         = "user.update"
+	AuditUserRoleChange     = "user.role_change"
+	AuditUserDelete         = "user.delete"
+	AuditUserVerifyEmail    = "user.verify_email"
+	AuditUserSessionsReset  = "user.sessions_reset"
+	AuditUserRestrict       = "user.restrict"
+	AuditUserReinstate      = "user.reinstate"
+	AuditUserTwoFactorReset = "user.two_factor_reset"
+	AuditUserUnlock         = "user.unlock"
+	AuditUserImpersonate    = "user.impersonate"
+	AuditRoleCreate         = "role.create"
+	AuditRoleUpdate         = "role.update"
+	AuditRoleDelete         = "role.delete"
+	AuditBlogDelete         = "blog.delete"
+	AuditCommentDelete      = "comment.delete"
+)
+
+// written by the auth middleware for every request made with an impersonation token
+const AuditImpersonatedRequest = "user.impersonated_request"
+
+// what an audited action was done to
+const (
+	AuditTargetUser        = "user"
+	AuditTargetRole        = "role"
+	AuditTargetBlog        = "blog"
+	AuditTargetComment     = "comment"
+	AuditTargetAccessToken = "access_token"
+)
+
+const (
+	AuditResultSuccess = "success"
+	AuditResultFailure = "failure"
+)
+
+// who performed an audited action, and from where
+type Actor struct {
+	UserID primitive.ObjectID
+	Role   string
+	Client ClientInfo // Client.ImpersonatorID is set when an admin acts as the user
+}
+
+// one record in the audit log. Entries are only ever appended: each one
+// stores the hash of the previous entry and a hash over its own fields, so
+// an edited or deleted entry breaks the chain.
+type AuditEntry struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Seq        int64              `bson:"seq" json:"seq"`
+	ActorID    primitive.ObjectID `bson:"actor_id,omitempty" json:"actor_id,omitempty"` // zero for anonymous requests, e.g. a failed login
+	ActorRole  string             `bson:"actor_role,omitempty" json:"actor_role,omitempty"`
+	Action     string             `bson:"action" json:"action"`
+	TargetType string             `bson:"target_type,omitempty" json:"target_type,omitempty"`
+	TargetID   string             `bson:"target_id,omitempty" json:"target_id,omitempty"`
+	IPAddress  string             `bson:"ip_address,omitempty" json:"ip_address,omitempty"`
+	UserAgent  string             `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	// the admin who did this while impersonating the actor
+	ImpersonatorID primitive.ObjectID `bson:"impersonator_id,omitempty" json:"impersonator_id,omitempty"`
+	Before         map[string]string  `bson:"before,omitempty" json:"before,omitempty"` // the fields the action changed, as they were
+	After          map[string]string  `bson:"after,omitempty" json:"after,omitempty"`
+	Result         string             `bson:"result" json:"result"`
+	Error          string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	PrevHash       string             `bson:"prev_hash" json:"prev_hash"`
+	Hash           string             `bson:"hash" json:"hash"`
+}
+
+type AuditFilter struct {
+	ActorID        *primitive.ObjectID
+	ImpersonatorID *primitive.ObjectID
+	Action         string
+	TargetType     string
+	TargetID       string
+	Result         string
+	IPAddress      string
+	From           *time.Time
+	To             *time.Time
+}
+
+type AuditLogRepository interface {
+	// fails with "audit entry already exists" when another entry took the sequence number
+	Append(entry *AuditEntry) error
+	// the entry with the highest sequence number
+	Last() (*AuditEntry, error)
+	// newest first
+	List(filter AuditFilter, page, limit int) ([]*AuditEntry, int64, error)
+	// up to limit entries after seq, oldest first
+	ListAfter(seq int64, limit int) ([]*AuditEntry, error)
+}
+
+// result of checking the hash chain
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Entries  int64  `json:"entries"`
+	LastSeq  int64  `json:"last_seq"`
+	LastHash string `json:"last_hash,omitempty"` // note it somewhere safe; a chain rewritten from the start still verifies
+	BrokenAt int64  `json:"broken_at,omitempty"` // sequence number of the first bad entry
+	Problem  string `json:"problem,omitempty"`
+}
+
+type AuditLog interface {
+	// appends the entry, marking it failed when err is set. Write errors are
+	// logged rather than returned so they never undo the action itself.
+	Record(entry *AuditEntry, err error)
+	List(filter AuditFilter, page, limit int) ([]*AuditEntry, int64, error)
+	// walks the whole chain and reports the first entry that doesn't match
+	Verify() (*AuditVerification, error)
+}
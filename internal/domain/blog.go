@@ -19,6 +19,7 @@ type Blog struct {
 	Likes          []string           `bson:"likes,omitempty" json:"likes,omitempty"`
 	Dislikes       []string           `bson:"dislikes,omitempty" json:"dislikes,omitempty"`
 	Comments       []Comment          `bson:"comments,omitempty" json:"comments,omitempty"`
+	Hidden         bool               `bson:"hidden,omitempty" json:"-"` // set while the author is banned with hidden content
 	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
 }
@@ -72,6 +73,9 @@ type BlogRepository interface {
 	AddDislike(blogID primitive.ObjectID, userID string) error
 	RemoveDislike(blogID primitive.ObjectID, userID string) error
 	GetTagIDByName(name string) (primitive.ObjectID, error)
+	CountByAuthor(authorID primitive.ObjectID) (int64, error)
+	CountCommentsByAuthor(authorID primitive.ObjectID) (int64, error)
+	SetHiddenByAuthor(authorID primitive.ObjectID, hidden bool) error
 }
 
 type BlogUseCase interface {
@@ -79,14 +83,14 @@ type BlogUseCase interface {
 	GetBlog(id primitive.ObjectID) (*Blog, error)
 	GetAllBlogs(page, limit int, sort string) ([]*Blog, int64, error)
 	UpdateBlog(id primitive.ObjectID, blog *Blog, userID primitive.ObjectID, userRole string) (*Blog, error)
-	DeleteBlog(id primitive.ObjectID, userID primitive.ObjectID, userRole string) error
+	DeleteBlog(id primitive.ObjectID, userID primitive.ObjectID, userRole string, client ClientInfo) error
 	SearchBlogsByTitle(title string, page, limit int) ([]*Blog, int64, error)
 	SearchBlogsByAuthor(author string, page, limit int) ([]*Blog, int64, error)
 	FilterBlogsByTags(tags []string, page, limit int) ([]*Blog, int64, error)
 	FilterBlogsByDate(startDate, endDate time.Time, page, limit int) ([]*Blog, int64, error)
 	GetPopularBlogs(limit int) ([]*Blog, error)
 	AddComment(blogID primitive.ObjectID, comment *Comment) error
-	DeleteComment(blogID, commentID primitive.ObjectID, userID primitive.ObjectID) error
+	DeleteComment(blogID, commentID primitive.ObjectID, userID primitive.ObjectID, client ClientInfo) error
 	UpdateComment(blogID, commentID primitive.ObjectID, content string, userID primitive.ObjectID) error
 	LikeBlog(blogID primitive.ObjectID, userID string) error
 	DislikeBlog(blogID primitive.ObjectID, userID string) error
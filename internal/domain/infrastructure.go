@@ -2,14 +2,13 @@ package domain
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"mime/multipart"
-	"net/http"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/oauth2"
 )
 
 // Graceful shutdown interfaces
@@ -17,23 +16,227 @@ type Job interface {
 	Run(ctx context.Context) error
 }
 
+// a job that can be persisted by name and rebuilt from its JSON payload
+type NamedJob interface {
+	Job
+	JobName() string
+}
+
+// returns an empty job of a registered type, ready to have its payload decoded into it
+type JobFactory func() Job
+
+// how many times a failed job is attempted and how long to wait between attempts
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// backoff before the given retry attempt (1-based), doubling up to MaxBackoff
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
+
+// lane a job is queued in; workers always drain higher lanes first
+type JobPriority int
+
+const (
+	PriorityHigh JobPriority = iota
+	PriorityNormal
+	PriorityLow
+)
+
+var JobPriorities = []JobPriority{PriorityHigh, PriorityNormal, PriorityLow}
+
+func (p JobPriority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityLow:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// implemented by jobs that should not go in the normal lane
+type PrioritizedJob interface {
+	Priority() JobPriority
+}
+
+// lane for a job, defaulting to normal
+func PriorityOf(job Job) JobPriority {
+	if p, ok := job.(PrioritizedJob); ok {
+		return p.Priority()
+	}
+	return PriorityNormal
+}
+
+var (
+	ErrQueueFull  = errors.New("worker pool: queue is full, try again later")
+	ErrPoolClosed = errors.New("worker pool: shutting down")
+)
+
+// point-in-time view of a worker pool
+type PoolStats struct {
+	Backend     string           `json:"backend"`
+	Workers     int              `json:"workers"`
+	QueueDepth  map[string]int64 `json:"queue_depth"`
+	Delayed     int64            `json:"delayed"`
+	DeadLetters int64            `json:"dead_letters"`
+	InFlight    int64            `json:"in_flight"`
+	Processed   int64            `json:"processed"`
+	Failed      int64            `json:"failed"`
+	AvgWaitMs   float64          `json:"avg_wait_ms"`
+	AvgRunMs    float64          `json:"avg_run_ms"`
+	MaxRunMs    float64          `json:"max_run_ms"`
+}
+
 type WorkerPool interface {
+	// blocks until the job is queued; prefer TrySubmit or SubmitContext on request paths
 	Submit(job Job)
-	Shutdown()
+	// queues the job or returns ErrQueueFull straight away
+	TrySubmit(job Job) error
+	// waits for room in the queue until ctx is done
+	SubmitContext(ctx context.Context, job Job) error
+	// drains queued jobs; when ctx expires in-flight job contexts are cancelled
+	Shutdown(ctx context.Context) error
 	Start()
+	Register(name string, factory JobFactory, policy RetryPolicy)
+	Stats(ctx context.Context) (*PoolStats, error)
+}
+
+// encrypts secrets, such as one-time tokens in queued emails, before they
+// are stored somewhere others can read them
+type SecretSealer interface {
+	Seal(plaintext string) (string, error)
+	Open(sealed string) (string, error)
+}
+
+// a job that ran out of retry attempts
+type DeadLetterJob struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempts   int             `json:"attempts"`
+	LastError  string          `json:"last_error"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+	FailedAt   time.Time       `json:"failed_at"`
+}
+
+// durable worker pool that keeps failed jobs in a dead-letter list
+type JobQueue interface {
+	WorkerPool
+	ListDeadLetters(ctx context.Context, page, limit int) ([]*DeadLetterJob, int64, error)
+	GetDeadLetter(ctx context.Context, id string) (*DeadLetterJob, error)
+	RequeueDeadLetter(ctx context.Context, id string) error
+	DeleteDeadLetter(ctx context.Context, id string) error
+	// removes dead letters that failed before the given time
+	PurgeDeadLetters(ctx context.Context, before time.Time) (int64, error)
+}
+
+var ErrDeadLetterNotFound = errors.New("dead-letter job not found")
+
+// outcomes of a scheduled run
+const (
+	ScheduleStatusQueued    = "queued"
+	ScheduleStatusRunning   = "running"
+	ScheduleStatusSucceeded = "succeeded"
+	ScheduleStatusFailed    = "failed"
+)
+
+// a recurring job and what happened the last time it ran
+type Schedule struct {
+	Name         string     `json:"name"`
+	Spec         string     `json:"spec"`
+	NextRun      time.Time  `json:"next_run"`
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastStatus   string     `json:"last_status,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastInstance string     `json:"last_instance,omitempty"`
+}
+
+// runs jobs on the worker pool according to cron expressions
+type Scheduler interface {
+	Register(name, spec string, factory JobFactory) error
+	Start()
+	Stop()
+	List(ctx context.Context) ([]*Schedule, error)
 }
 
 // OAuth service
 type OAuthService interface {
-	GetAuthURL(provider, state string) (string, error)
-	ExchangeCodeForToken(provider, code string) (*oauth2.Token, error)
-	GetUserInfo(provider string, token *oauth2.Token) (oauthID, email, username string, err error)
+	GetAuthURL(provider string, flow *OAuthFlow) (string, error)
+	// trades the authorization code for the provider's tokens and returns who logged in
+	Exchange(provider, code string, flow *OAuthFlow) (*OAuthUserInfo, error)
+}
+
+// per-login secrets sent to the provider
+type OAuthFlow struct {
+	State        string // the signed OAuthState
+	Nonce        string // bound into OpenID Connect ID tokens, and kept in a browser cookie
+	CodeVerifier string // PKCE (RFC 7636), derived from the nonce
+}
+
+// what an OAuth login was started for; signed into the state parameter so the
+// callback needs nothing stored on the server
+type OAuthState struct {
+	Provider   string `json:"p"`
+	Nonce      string `json:"n"`
+	ReturnTo   string `json:"r,omitempty"` // where the browser goes afterwards; empty answers with JSON
+	LinkUserID string `json:"l,omitempty"` // set when linking an identity to this user instead of logging in
+	ExpiresAt  int64  `json:"e"`
+}
+
+type OAuthStateService interface {
+	// starts a flow; returnTo must be on the allow-list, empty means the default
+	Issue(provider, returnTo, linkUserID string) (*OAuthState, *OAuthFlow, error)
+	// checks the signature and expiry and rebuilds the flow
+	Verify(value string) (*OAuthState, *OAuthFlow, error)
+}
+
+// the account at the provider
+type OAuthUserInfo struct {
+	Subject  string
+	Email    string
+	Username string
+	// whether the provider has confirmed the owner controls Email
+	EmailVerified bool
+}
+
+// LDAP or another directory that employees log in with
+type DirectoryService interface {
+	// binds as the user to check the password and reads their entry and groups
+	Authenticate(username, password string) (*DirectoryUser, error)
+}
+
+var ErrDirectoryCredentials = errors.New("directory: invalid username or password")
+
+// a directory entry, with the role its groups map to
+type DirectoryUser struct {
+	Subject  string // stable ID of the entry, e.g. its entryUUID
+	Username string
+	Email    string
+	Role     string
 }
 
 // cache interefaces
 type Cache interface {
 	Get(ctx context.Context, key string, dest interface{}) error
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	// like Get, but also deletes the key so only one caller receives the value
+	Take(ctx context.Context, key string, dest interface{}) error
+	// atomically adds one to a counter and returns the new value; the counter
+	// is created with the expiration on the first call
+	Increment(ctx context.Context, key string, expiration time.Duration) (int64, error)
 	Delete(ctx context.Context, key string) error
 	DeleteByPattern(ctx context.Context, Pattern string) error
 }
@@ -42,25 +245,87 @@ var ErrCacheMiss = errors.New("cache: key not found")
 
 // interface for JWT operations
 type JWTService interface {
-	GenerateAccessToken(userID primitive.ObjectID, email, role string) (string, error)
-	GenerateRefreshToken(userID primitive.ObjectID, email, role string) (string, error)
+	GenerateAccessToken(user *User, sessionID primitive.ObjectID) (string, error)
+	GenerateRefreshToken(user *User, sessionID primitive.ObjectID) (string, error)
 	ValidateToken(tokenString string) (*JWTClaims, error)
-	RefreshAccessToken(refreshToken string) (string, error)
+	GenerateChallengeToken(user *User) (string, error)
+	GenerateMagicLinkToken(user *User) (string, error)
+	// lets admin act as user; bound to the admin's session
+	GenerateImpersonationToken(user, admin *User, sessionID primitive.ObjectID, readOnly bool, expiry time.Duration) (string, error)
+	RevokeToken(claims *JWTClaims) error
+	// revokes a single-use token; fails if it had already been used
+	ConsumeToken(claims *JWTClaims) error
+	JWKS() *JSONWebKeySet
+}
+
+// time-based one-time passwords (RFC 6238) for two-factor authentication
+type TOTPService interface {
+	GenerateSecret() (string, error)
+	ProvisioningURI(secret, accountName string) string
+	Validate(secret, code string, at time.Time) (step int64, ok bool)
+}
+
+// public signing key published at /.well-known/jwks.json (RFC 7517)
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // OKP curve
+	X   string `json:"x,omitempty"`   // OKP public key
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// revoked token IDs (jti), remembered until the token would have expired
+type TokenDenylist interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// like Revoke, but reports false if the jti was already revoked
+	RevokeOnce(ctx context.Context, jti string, expiresAt time.Time) (bool, error)
+	IsRevoked(ctx context.Context, jti string) (bool, error)
 }
 
 // claims in a JWT token
 type JWTClaims struct {
-	UserID primitive.ObjectID `json:"user_id"`
-	Email  string             `json:"email"`
-	Role   string             `json:"role"`
-	Exp    int64              `json:"exp"`
-	Iat    int64              `json:"iat"`
+	ID        string             `json:"jti"`
+	UserID    primitive.ObjectID `json:"user_id"`
+	SessionID primitive.ObjectID `json:"sid"` // the device session the token was issued for
+	TokenType string             `json:"typ"` // TokenTypeAccess or TokenTypeRefresh
+	Version   int                `json:"ver"` // must match User.TokenVersion
+	Email     string             `json:"email"`
+	Role      string             `json:"role"`
+	Exp       int64              `json:"exp"`
+	Iat       int64              `json:"iat"`
+	// impersonation tokens only: the admin acting as the user (RFC 8693)
+	Act      *ActorClaims `json:"act,omitempty"`
+	ReadOnly bool         `json:"read_only,omitempty"`
+}
+
+type ActorClaims struct {
+	UserID  primitive.ObjectID `json:"sub"`
+	Role    string             `json:"role"`
+	Version int                `json:"ver"` // must match the admin's TokenVersion
 }
 type FileService interface {
 	SaveProfilePicture(userID primitive.ObjectID, file multipart.File, handler *multipart.FileHeader) (*Photo, error)
 	// You could add other methods here later, like DeleteFile(filePath string) error
 }
 
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	// issued by Login in place of tokens until the second factor is verified
+	TokenTypeTwoFactorChallenge = "2fa_challenge"
+	// emailed for passwordless login; works once
+	TokenTypeMagicLink = "magic_link"
+	// an access token an admin uses to act as another user
+	TokenTypeImpersonation = "impersonation"
+)
+
 // returns the expiration time
 func (c *JWTClaims) GetExpirationTime() (*jwt.NumericDate, error) {
 	return jwt.NewNumericDate(time.Unix(c.Exp, 0)), nil
@@ -91,26 +356,29 @@ func (c *JWTClaims) GetAudience() (jwt.ClaimStrings, error) {
 	return jwt.ClaimStrings{}, nil
 }
 
-// interface for authentication middleware
-type AuthMiddleware interface {
-	AuthRequired() func(http.Handler) http.Handler
-	AdminRequired() func(http.Handler) http.Handler
-	OptionalAuth() func(http.Handler) http.Handler
-	ExtractUserFromContext(ctx context.Context) (*User, bool)
-}
-
 // interface for email operations
 type EmailService interface {
 	SendPasswordResetEmail(email, username, token string) error
 	SendWelcomeEmail(email, username string) error
 	SendVerificationEmail(email, username, token string) error
+	SendMagicLinkEmail(email, username, token string) error
+	SendAccountLockedEmail(email, username string, lockedUntil time.Time) error
+	// asks the new address to confirm an email change
+	SendEmailChangeConfirmation(email, username, token string) error
+	// tells the old address about the change, with a link to cancel it
+	SendEmailChangeNotice(email, username, newEmail, cancelToken string) error
+	// tells the user about a login from a new device, with a link to secure the account
+	SendNewDeviceAlert(email, username, device, ipAddress string, loginAt time.Time, secureToken string) error
 }
 
 // defines the interface for password operations
 type PasswordService interface {
 	HashPassword(password string) (string, error)
 	CheckPassword(password, hash string) bool
+	// true for legacy bcrypt hashes and argon2id hashes with outdated parameters
+	NeedsRehash(hash string) bool
 	ValidatePassword(password string) error
+	HistorySize() int
 	GenerateSecureToken(length int) string
 }
 
@@ -121,25 +389,6 @@ const (
 	UserContextKey ContextKey = "user"
 )
 
-// password reset token
-type PasswordResetToken struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
-	Token     string             `bson:"token" json:"token"`
-	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
-	Used      bool               `bson:"used" json:"used"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-}
-
-// interface for password reset token operations
-type PasswordResetTokenRepository interface {
-	Create(token *PasswordResetToken) error
-	GetByToken(token string) (*PasswordResetToken, error)
-	GetByUserID(userID primitive.ObjectID) (*PasswordResetToken, error)
-	MarkAsUsed(token string) error
-	DeleteExpired() error
-}
-
 // request for password reset
 type ForgotPasswordRequest struct {
 	Email string `json:"email" validate:"required,email"`
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// how a login was attempted; OAuth logins use "oauth:" plus the provider name
+const (
+	LoginMethodPassword       = "password"
+	LoginMethodLDAP           = "ldap"
+	LoginMethodMagicLink      = "magic_link"
+	LoginMethodTwoFactor      = "two_factor"
+	LoginMethodRefresh        = "refresh"
+	LoginMethodReauthenticate = "reauthenticate"
+	LoginMethodOAuthPrefix    = "oauth:"
+)
+
+// a successful or failed login to an account, shown to its owner. Mongo
+// removes events once they are 180 days old.
+type LoginEvent struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID `bson:"user_id" json:"-"`
+	Method        string             `bson:"method" json:"method"`
+	Success       bool               `bson:"success" json:"success"`
+	FailureReason string             `bson:"failure_reason,omitempty" json:"failure_reason,omitempty"`
+	IPAddress     string             `bson:"ip_address,omitempty" json:"ip_address,omitempty"`
+	UserAgent     string             `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	Device        string             `bson:"device" json:"device"` // e.g. "Firefox on Windows"
+	// hash of the device and the network it was on; a login with one the
+	// user hasn't logged in from before sends them an alert
+	DeviceFingerprint string    `bson:"device_fingerprint" json:"device_fingerprint"`
+	NewDevice         bool      `bson:"new_device,omitempty" json:"new_device,omitempty"`
+	CreatedAt         time.Time `bson:"created_at" json:"created_at"`
+}
+
+type LoginHistoryRepository interface {
+	Create(event *LoginEvent) error
+	// newest first
+	ListByUserID(userID primitive.ObjectID, page, limit int) ([]*LoginEvent, int64, error)
+	// whether the user has logged in successfully from the fingerprint, or
+	// from anywhere when fingerprint is empty. Token refreshes don't count.
+	HasSuccessfulLogin(userID primitive.ObjectID, fingerprint string) (bool, error)
+}
@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// what a role allows; ".any" permissions act on other users' content
+const (
+	PermissionBlogUpdateAny    = "blog.update.any"
+	PermissionBlogDeleteAny    = "blog.delete.any"
+	PermissionCommentDeleteAny = "comment.delete.any"
+	PermissionUserView         = "user.view"
+	PermissionUserUpdate       = "user.update"
+	PermissionUserBan          = "user.ban"
+	PermissionUserDelete       = "user.delete"
+	PermissionUserImpersonate  = "user.impersonate"
+	PermissionRoleAssign       = "role.assign"
+	PermissionRoleManage       = "role.manage"
+	PermissionJobManage        = "job.manage"
+	PermissionAuditView        = "audit.view"
+)
+
+var Permissions = []string{
+	PermissionBlogUpdateAny,
+	PermissionBlogDeleteAny,
+	PermissionCommentDeleteAny,
+	PermissionUserView,
+	PermissionUserUpdate,
+	PermissionUserBan,
+	PermissionUserDelete,
+	PermissionUserImpersonate,
+	PermissionRoleAssign,
+	PermissionRoleManage,
+	PermissionJobManage,
+	PermissionAuditView,
+}
+
+const (
+	RoleEditor    = "editor"
+	RoleModerator = "moderator"
+)
+
+// a named set of permissions. Built-in roles are defined in code and can't be
+// changed; admins can add custom ones.
+type Role struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	Permissions []string           `bson:"permissions" json:"permissions"`
+	BuiltIn     bool               `bson:"-" json:"built_in"`
+	CreatedAt   time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt   time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+func (r *Role) HasPermission(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+var BuiltInRoles = []*Role{
+	{Name: RoleAdmin, Description: "Full access", Permissions: Permissions, BuiltIn: true},
+	{Name: RoleEditor, Description: "Edits and removes any blog or comment", Permissions: []string{PermissionBlogUpdateAny, PermissionBlogDeleteAny, PermissionCommentDeleteAny}, BuiltIn: true},
+	{Name: RoleModerator, Description: "Removes comments and suspends or bans users", Permissions: []string{PermissionCommentDeleteAny, PermissionUserView, PermissionUserBan}, BuiltIn: true},
+	{Name: RoleUser, Description: "Manages their own content", Permissions: []string{}, BuiltIn: true},
+}
+
+// the built-in role with this name, or nil
+func BuiltInRole(name string) *Role {
+	for _, r := range BuiltInRoles {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+type RoleRepository interface {
+	Create(role *Role) error
+	GetByName(name string) (*Role, error)
+	List() ([]*Role, error)
+	Update(role *Role) error
+	Delete(name string) error
+}
+
+// answers whether a user's role grants a permission; unknown roles grant nothing
+type PermissionChecker interface {
+	HasPermission(role, permission string) (bool, error)
+	// fails unless assignerRole holds every permission of both roles, so
+	// nobody can hand out or take away more than they have
+	CanAssign(assignerRole, fromRole, toRole string) error
+	// fails unless actorRole holds every permission of targetRole, so staff
+	// can't edit, sign out or delete users who have more than they do
+	CanManage(actorRole, targetRole string) error
+	// roles with any permission; their users need two-factor authentication
+	// and can't be restricted
+	IsPrivileged(role string) (bool, error)
+}
+
+type RoleUseCase interface {
+	PermissionChecker
+	ListRoles() ([]*Role, error)
+	GetRole(name string) (*Role, error)
+	// the actor's role must hold every permission it puts in a role
+	CreateRole(actor Actor, req *CreateRoleRequest) (*Role, error)
+	UpdateRole(actor Actor, name string, req *UpdateRoleDefinitionRequest) (*Role, error)
+	DeleteRole(actor Actor, name string) error
+}
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name" validate:"required,min=2,max=50"`
+	Description string   `json:"description,omitempty" validate:"max=200"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+type UpdateRoleDefinitionRequest struct {
+	Description *string  `json:"description,omitempty" validate:"omitempty,max=200"`
+	Permissions []string `json:"permissions,omitempty" validate:"omitempty,dive,required"`
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// kinds of security events
+const (
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+	SecurityEventAccountLocked     = "account_locked"
+	SecurityEventEmailChangeCancel = "email_change_cancelled"
+	SecurityEventAccountSecured    = "account_secured"
+)
+
+// something suspicious that happened to an account
+type SecurityEvent struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	SessionID primitive.ObjectID `bson:"session_id,omitempty" json:"session_id,omitempty"`
+	Type      string             `bson:"type" json:"type"`
+	IPAddress string             `bson:"ip_address,omitempty" json:"ip_address,omitempty"`
+	UserAgent string             `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	Details   string             `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type SecurityEventRepository interface {
+	Create(event *SecurityEvent) error
+	ListByUserID(userID primitive.ObjectID, page, limit int) ([]*SecurityEvent, int64, error)
+}
+
+// fixed-window request limits shared across instances
+type RateLimiter interface {
+	// counts a hit against key and reports whether it is still within limit for the window
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// failed password logins, counted per account (email) and per client IP
+type LoginThrottler interface {
+	// how long the caller must wait before trying to log in again; locked is
+	// true when the account itself is locked rather than just slowed down
+	Check(ctx context.Context, email, ip string) (wait time.Duration, locked bool, err error)
+	// counts a failed attempt; locked is true only for the attempt that locked the account
+	RecordFailure(ctx context.Context, email, ip string) (locked bool, err error)
+	// clears the account's failures after a successful login
+	Reset(ctx context.Context, email string) error
+	// lifts a lockout early
+	Unlock(ctx context.Context, email string) error
+}
+
+// returned when a caller has to wait before trying again
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Reason     string // defaults to "too many requests"
+}
+
+func (e *RateLimitError) Error() string {
+	reason := e.Reason
+	if reason == "" {
+		reason = "too many requests"
+	}
+	return fmt.Sprintf("%s, please try again in %d seconds", reason, e.RetryAfterSeconds())
+}
+
+func (e *RateLimitError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
@@ -6,21 +6,21 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// user session
+// user session, one per logged-in device
 type Session struct {
-	ID                         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	UserID                     primitive.ObjectID `bson:"user_id" json:"user_id"`
-	Username                   string             `bson:"username" json:"username"`
-	Token                      string             `bson:"token" json:"token,omitempty"` // For JWT refresh token
-	VerificationToken          string             `bson:"verification_token,omitempty" json:"verification_token,omitempty"`
-	PasswordResetToken         string             `bson:"password_reset_token,omitempty" json:"password_reset_token,omitempty"`
-	ResetCode                  int                `bson:"reset_code,omitempty" json:"reset_code,omitempty"`
-	IsActive                   bool               `bson:"is_active" json:"is_active"`
-	CreatedAt                  time.Time          `bson:"created_at" json:"created_at"`
-	ExpiresAt                  time.Time          `bson:"expires_at" json:"expires_at"` // For JWT session
-	VerificationTokenExpiresAt time.Time          `bson:"verification_expires_at,omitempty" json:"verification_expires_at,omitempty"`
-	ResetTokenExpiresAt        time.Time          `bson:"reset_expires_at,omitempty" json:"reset_expires_at,omitempty"`
-	LastActivity               time.Time          `bson:"last_activity" json:"last_activity"`
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID           primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Username         string             `bson:"username" json:"username"`
+	RefreshTokenHash string             `bson:"refresh_token_hash" json:"-"` // SHA-256 of the current refresh token; replaced on every refresh
+	UserAgent        string             `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	IPAddress        string             `bson:"ip_address,omitempty" json:"ip_address,omitempty"`
+	Current          bool               `bson:"-" json:"current"` // set when listing, true for the caller's own session
+	IsActive         bool               `bson:"is_active" json:"is_active"`
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt        time.Time          `bson:"expires_at" json:"expires_at"` // For JWT session
+	LastActivity     time.Time          `bson:"last_activity" json:"last_activity"`
+	// when the user last proved who they are on this device: logging in or Reauthenticate
+	AuthenticatedAt time.Time `bson:"authenticated_at" json:"authenticated_at"`
 }
 
 // interface for session data operations
@@ -32,10 +32,13 @@ type SessionRepository interface {
 	Update(session *Session) error
 	Delete(id primitive.ObjectID) error
 	DeleteByUserID(userID primitive.ObjectID) error
+	DeleteByUserIDExcept(userID, keepSessionID primitive.ObjectID) error
+	// swaps the refresh token hash only if it still equals oldHash; false means the token was already rotated
+	RotateRefreshToken(id primitive.ObjectID, oldHash, newHash string) (bool, error)
+	ListActiveByUserID(userID primitive.ObjectID) ([]*Session, error)
 	DeleteExpired() error
 	UpdateLastActivity(id primitive.ObjectID) error
-	GetByVerificationToken(token string) (*Session, error)
-	GetByResetToken(token string) (*Session, error)
+	MarkAuthenticated(id primitive.ObjectID) error
 }
 
 // interface for session business logic
@@ -44,5 +47,16 @@ type SessionUseCase interface {
 	GetSessionByUserID(userID primitive.ObjectID) (*Session, error)
 	DeleteSession(userID primitive.ObjectID) error
 	CleanupExpiredSessions() error
-	UpdateSessionActivity(userID primitive.ObjectID) error
+	UpdateSessionActivity(sessionID primitive.ObjectID) error
+	ListSessions(userID, currentSessionID primitive.ObjectID) ([]*Session, error)
+	RevokeSession(userID, sessionID primitive.ObjectID) error
+	RevokeOtherSessions(userID, currentSessionID primitive.ObjectID) error
+}
+
+// where a login came from, recorded on the session
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+	// the admin behind the request when it was made with an impersonation token
+	ImpersonatorID primitive.ObjectID
 }
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// what a one-time token can be redeemed for
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	// sent to the new address; confirms an email change
+	TokenPurposeEmailChange = "email_change"
+	// sent to the old address; stops a pending email change
+	TokenPurposeEmailChangeCancel = "email_change_cancel"
+	// sent with new-device alerts; signs the user out everywhere
+	TokenPurposeSecureAccount = "secure_account"
+)
+
+// single-use token sent by email. Only its SHA-256 hash is stored, and Mongo
+// removes it once ExpiresAt has passed.
+type OneTimeToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Purpose   string             `bson:"purpose" json:"purpose"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	Email     string             `bson:"email,omitempty" json:"email,omitempty"` // the requested address, for email changes
+	Attempts  int                `bson:"attempts" json:"attempts"`               // failed redemptions, e.g. a rejected new password
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty" json:"used_at,omitempty"`
+}
+
+type OneTimeTokenRepository interface {
+	// stores the token, replacing any unused one for the same user and purpose
+	Create(token *OneTimeToken) error
+	// finds an unused, unexpired token
+	GetValid(purpose, tokenHash string) (*OneTimeToken, error)
+	IncrementAttempts(id primitive.ObjectID) error
+	// marks the token used; false if it already was
+	MarkUsed(id primitive.ObjectID) (bool, error)
+	// deletes the user's tokens for the given purposes, or all of them if none are given
+	DeleteByUserID(userID primitive.ObjectID, purposes ...string) error
+}
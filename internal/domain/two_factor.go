@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+// TOTP second factor. The secret is stored at setup and only required at
+// login once the user has confirmed a code from their authenticator app.
+type TwoFactor struct {
+	Secret        string     `bson:"secret"`
+	Enabled       bool       `bson:"enabled"`
+	EnabledAt     *time.Time `bson:"enabled_at,omitempty"`
+	RecoveryCodes []string   `bson:"recovery_codes"` // SHA-256 hashes, removed as they are used
+	LastUsedStep  int64      `bson:"last_used_step"` // stops a code being replayed within its window
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorStatus struct {
+	Enabled                bool `json:"enabled"`
+	Required               bool `json:"required"` // admins must enroll
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// a TOTP code or a recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
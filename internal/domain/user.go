@@ -8,18 +8,99 @@ import (
 )
 
 type User struct {
-	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Username       string             `bson:"username" json:"username" validate:"required,min=3,max=50"`
-	Email          string             `bson:"email" json:"email" validate:"required,email"`
-	Password       string             `bson:"password" json:"-" validate:"required,min=6"` // "-" means don't include in JSON
-	Role           string             `bson:"role" json:"role"`
-	EmailVerified  bool               `bson:"email_verified" json:"email_verified"`
-	ProfilePicture *Photo             `bson:"profile_picture,omitempty" json:"profile_picture,omitempty"`
-	Bio            string             `bson:"bio,omitempty" json:"bio,omitempty"`
-	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
-	OAuthProvider  string             `bson:"oauth_provider,omitempty" json:"oauth_provider,omitempty"`
-	OAuthID        string             `bson:"oauth_id,omitempty" json:"oauth_id,omitempty"`
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	Username       string              `bson:"username" json:"username" validate:"required,min=3,max=50"`
+	Email          string              `bson:"email" json:"email" validate:"required,email"`
+	Password       string              `bson:"password" json:"-" validate:"required,min=6"` // "-" means don't include in JSON
+	Role           string              `bson:"role" json:"role"`
+	EmailVerified  bool                `bson:"email_verified" json:"email_verified"`
+	ProfilePicture *Photo              `bson:"profile_picture,omitempty" json:"profile_picture,omitempty"`
+	Bio            string              `bson:"bio,omitempty" json:"bio,omitempty"`
+	CreatedAt      time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time           `bson:"updated_at" json:"updated_at"`
+	Restriction    *AccountRestriction `bson:"restriction,omitempty" json:"restriction,omitempty"`
+	TokenVersion   int                 `bson:"token_version" json:"-"` // bumped to invalidate every token issued so far
+	TwoFactor      *TwoFactor          `bson:"two_factor,omitempty" json:"-"`
+	// hashes of previous passwords, newest first
+	PasswordHistory []string `bson:"password_history,omitempty" json:"-"`
+	// address the user asked to switch to; Email changes once it is confirmed
+	PendingEmail string `bson:"pending_email,omitempty" json:"pending_email,omitempty"`
+	// OAuth accounts the user can log in with, at most one per provider
+	LinkedIdentities []LinkedIdentity `bson:"linked_identities,omitempty" json:"linked_identities,omitempty"`
+	// set when the owner reports a login that wasn't them; password logins
+	// are refused until the password is reset
+	PasswordResetRequired bool `bson:"password_reset_required,omitempty" json:"password_reset_required,omitempty"`
+}
+
+// an account at an OAuth provider that logs in to this user
+type LinkedIdentity struct {
+	Provider string    `bson:"provider" json:"provider"`
+	Subject  string    `bson:"subject" json:"-"` // the provider's ID for the account
+	Email    string    `bson:"email,omitempty" json:"email,omitempty"`
+	LinkedAt time.Time `bson:"linked_at" json:"linked_at"`
+}
+
+// the identity linked for provider, or nil
+func (u *User) Identity(provider string) *LinkedIdentity {
+	for i := range u.LinkedIdentities {
+		if u.LinkedIdentities[i].Provider == provider {
+			return &u.LinkedIdentities[i]
+		}
+	}
+	return nil
+}
+
+// ways the user can log in: a password plus each linked identity
+func (u *User) LoginMethodCount() int {
+	n := len(u.LinkedIdentities)
+	if u.Password != "" {
+		n++
+	}
+	return n
+}
+
+const (
+	RestrictionSuspended = "suspended"
+	RestrictionBanned    = "banned"
+)
+
+// a suspension or ban placed on an account by an admin
+type AccountRestriction struct {
+	Type        string             `bson:"type" json:"type"`
+	Reason      string             `bson:"reason" json:"reason"`
+	IssuedBy    primitive.ObjectID `bson:"issued_by" json:"issued_by"`
+	IssuedAt    time.Time          `bson:"issued_at" json:"issued_at"`
+	ExpiresAt   *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"` // nil means until lifted
+	HideContent bool               `bson:"hide_content" json:"hide_content"`
+}
+
+// returns the restriction currently in force, ignoring suspensions that have run out
+func (u *User) ActiveRestriction() *AccountRestriction {
+	r := u.Restriction
+	if r == nil {
+		return nil
+	}
+	if r.ExpiresAt != nil && time.Now().After(*r.ExpiresAt) {
+		return nil
+	}
+	return r
+}
+
+// whether login asks for a TOTP or recovery code
+func (u *User) TwoFactorEnabled() bool {
+	return u.TwoFactor != nil && u.TwoFactor.Enabled
+}
+
+// message shown to a restricted user when they try to authenticate
+func (r *AccountRestriction) Error() string {
+	msg := "account " + r.Type
+	if r.ExpiresAt != nil {
+		msg += " until " + r.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if r.Reason != "" {
+		msg += ": " + r.Reason
+	}
+	return msg
 }
 
 // Constants for user roles to avoid magic strings.
@@ -43,34 +124,155 @@ type UserRepository interface {
 	Update(user *User) error
 	Delete(id primitive.ObjectID) error
 	UpdateProfile(id primitive.ObjectID, updates map[string]interface{}) error
-	UpdatePassword(id primitive.ObjectID, password string) error
+	// history replaces the stored password history
+	UpdatePassword(id primitive.ObjectID, password string, history []string) error
 	UpdateRole(id primitive.ObjectID, role string) error
 	UpdateProfilePicture(id primitive.ObjectID, photo *Photo) error
 	VerifyEmail(id primitive.ObjectID) error
 	UpdateEmailVerificationStatus(id primitive.ObjectID, verified bool) error
 
+	// finds the user a provider account is linked to
 	GetByOAuth(provider, oauthID string) (*User, error)
+	// fails if the user already has an identity for the provider, or if the
+	// provider account is linked to someone else
+	AddLinkedIdentity(id primitive.ObjectID, identity *LinkedIdentity) error
+	RemoveLinkedIdentity(id primitive.ObjectID, provider string) error
+	List(filter UserFilter, page, limit int) ([]*User, int64, error)
+	SetRestriction(id primitive.ObjectID, restriction *AccountRestriction) error
+	ClearRestriction(id primitive.ObjectID) error
+	IncrementTokenVersion(id primitive.ObjectID) error
+	SetTwoFactor(id primitive.ObjectID, twoFactor *TwoFactor) error
+	ClearTwoFactor(id primitive.ObjectID) error
+	// records step as used; false if it (or a later one) already was
+	ConsumeTOTPStep(id primitive.ObjectID, step int64) (bool, error)
+	// removes the hashed recovery code; false if it wasn't there
+	ConsumeRecoveryCode(id primitive.ObjectID, codeHash string) (bool, error)
+}
+
+// filters for the admin user listing; zero values are ignored
+type UserFilter struct {
+	Search        string // case-insensitive match on username or email
+	Role          string
+	EmailVerified *bool
+	OAuthProvider string // matches accounts with an identity from this provider; "none" matches accounts without any
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
 }
 
 type UserUseCase interface {
 	Register(username, email, password string) (*User, error)
-	Login(email, password string) (*LoginResponse, error)
+	Login(email, password string, client ClientInfo) (*LoginResponse, error)
+	// logs in with directory credentials, creating the user on first login
+	LDAPLogin(username, password string, client ClientInfo) (*LoginResponse, error)
 	GetByID(id primitive.ObjectID) (*User, error)
 	ValidatePassword(password string) error
 	HashPassword(password string) (string, error)
 	CheckPassword(password, hash string) bool
-	RefreshToken(refreshToken string) (*LoginResponse, error)
-	Logout(userID primitive.ObjectID) error
+	RefreshToken(refreshToken string, client ClientInfo) (*LoginResponse, error)
+	Logout(claims *JWTClaims) error
 	VerifyEmail(token string) error
 	SendVerificationEmail(email string) error
 	SendPasswordResetEmail(email string) error
-	ResetPassword(token, newPassword string) error
+	ResetPassword(token, newPassword string, client ClientInfo) error
+	ConfirmEmailChange(token string) (*User, error)
+	CancelEmailChange(token string) error
+
+	// successful and failed logins, newest first
+	ListLoginHistory(userID primitive.ObjectID, page, limit int) ([]*LoginEvent, int64, error)
+	// the "this wasn't me" link from a new-device alert
+	SecureAccount(token string, client ClientInfo) error
 
 	UpdateProfile(id primitive.ObjectID, req *UpdateProfileRequest) (*User, error)
-	UpdateRole(adminUserID, targetUserID primitive.ObjectID, role string) error
+	UpdateRole(actor Actor, targetUserID primitive.ObjectID, role string) error
 	UploadProfilePicture(userID primitive.ObjectID, file multipart.File, handler *multipart.FileHeader) (*User, error)
 
-	OAuthLogin(provider, state, code, storedState string) (*LoginResponse, error)
+	// OAuth logins: StartOAuthLogin returns the provider's URL; the callback
+	// checks its state with VerifyOAuthState before calling OAuthLogin
+	StartOAuthLogin(provider, returnTo string) (authURL string, flow *OAuthFlow, err error)
+	VerifyOAuthState(provider, state, browserNonce string) (*OAuthState, *OAuthFlow, error)
+	OAuthLogin(provider, code string, flow *OAuthFlow, client ClientInfo) (*LoginResponse, error)
+	// single-use code a frontend trades for the tokens of a login that redirected to it
+	CreateLoginHandoff(resp *LoginResponse) (string, error)
+	RedeemLoginHandoff(code string) (*LoginResponse, error)
+
+	// confirms the password or a two-factor code for the current session
+	Reauthenticate(claims *JWTClaims, req *ReauthenticateRequest, client ClientInfo) error
+
+	// linked OAuth identities; changes need a recently re-authenticated session
+	GetLoginMethods(userID primitive.ObjectID) (*LoginMethods, error)
+	BeginIdentityLink(claims *JWTClaims, provider, returnTo string) (authURL string, flow *OAuthFlow, err error)
+	LinkIdentity(provider, code string, userID primitive.ObjectID, flow *OAuthFlow) (*User, error)
+	UnlinkIdentity(claims *JWTClaims, provider string) error
+
+	// personal access tokens for scripts; creating one needs a recent login
+	CreateAccessToken(claims *JWTClaims, req *CreateAccessTokenRequest, client ClientInfo) (*CreateAccessTokenResponse, error)
+	ListAccessTokens(userID primitive.ObjectID) ([]*PersonalAccessToken, error)
+	RevokeAccessToken(userID, tokenID primitive.ObjectID, client ClientInfo) error
+
+	// passwordless login
+	SendMagicLink(email string, client ClientInfo) error
+	MagicLinkLogin(token string, client ClientInfo) (*LoginResponse, error)
+
+	// two-factor authentication
+	VerifyTwoFactorLogin(challengeToken, code string, client ClientInfo) (*LoginResponse, error)
+	GetTwoFactorStatus(userID primitive.ObjectID) (*TwoFactorStatus, error)
+	SetupTwoFactor(userID primitive.ObjectID) (*TwoFactorSetupResponse, error)
+	ConfirmTwoFactor(userID primitive.ObjectID, code string, client ClientInfo) ([]string, error)
+	DisableTwoFactor(userID primitive.ObjectID, code string, client ClientInfo) error
+	RegenerateRecoveryCodes(userID primitive.ObjectID, code string) ([]string, error)
+}
+
+// user management for administrators
+type AdminUseCase interface {
+	ListUsers(filter UserFilter, page, limit int) ([]*User, int64, error)
+	GetUserDetails(id primitive.ObjectID) (*AdminUserDetails, error)
+	// the changes below are written to the audit log as done by actor
+	UpdateUser(actor Actor, targetUserID primitive.ObjectID, req *AdminUpdateUserRequest) (*User, error)
+	VerifyUserEmail(actor Actor, targetUserID primitive.ObjectID) error
+	ResetUserSessions(actor Actor, targetUserID primitive.ObjectID) error
+	DeleteUser(actor Actor, targetUserID primitive.ObjectID) error
+	RestrictUser(actor Actor, targetUserID primitive.ObjectID, req *RestrictUserRequest) (*User, error)
+	ReinstateUser(actor Actor, targetUserID primitive.ObjectID) (*User, error)
+	ResetTwoFactor(actor Actor, targetUserID primitive.ObjectID) error
+	UnlockUser(actor Actor, targetUserID primitive.ObjectID) error
+	// issues a short-lived token for acting as the user, tied to the admin's session
+	ImpersonateUser(actor Actor, sessionID, targetUserID primitive.ObjectID, req *ImpersonateRequest) (*ImpersonationResponse, error)
+	ListSecurityEvents(targetUserID primitive.ObjectID, page, limit int) ([]*SecurityEvent, int64, error)
+}
+
+type AdminUserDetails struct {
+	User         *User `json:"user"`
+	BlogCount    int64 `json:"blog_count"`
+	CommentCount int64 `json:"comment_count"`
+}
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+	// impersonation tokens are read-only unless this is set
+	AllowWrite       bool `json:"allow_write"`
+	ExpiresInMinutes int  `json:"expires_in_minutes,omitempty" validate:"omitempty,min=1,max=60"` // defaults to 15
+}
+
+type ImpersonationResponse struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	ReadOnly    bool      `json:"read_only"`
+	User        *User     `json:"user"`
+}
+
+type RestrictUserRequest struct {
+	Type        string     `json:"type" validate:"required,oneof=suspended banned"`
+	Reason      string     `json:"reason" validate:"required,max=500"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // suspensions only; omit for an indefinite suspension
+	HideContent bool       `json:"hide_content"`
+}
+
+type AdminUpdateUserRequest struct {
+	Username      *string `json:"username,omitempty" validate:"omitempty,min=3,max=50"`
+	Email         *string `json:"email,omitempty" validate:"omitempty,email"`
+	Bio           *string `json:"bio,omitempty" validate:"omitempty,max=500"`
+	Role          *string `json:"role,omitempty" validate:"omitempty,max=50"` // any built-in or custom role
+	EmailVerified *bool   `json:"email_verified,omitempty"`
 }
 
 type RegisterRequest struct {
@@ -84,10 +286,31 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type LDAPLoginRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// the password, plus a two-factor code if the account has one
+type ReauthenticateRequest struct {
+	Password string `json:"password,omitempty"`
+	Code     string `json:"code,omitempty"`
+}
+
+type LoginMethods struct {
+	Password   bool             `json:"password"`
+	Identities []LinkedIdentity `json:"identities"`
+}
+
 type LoginResponse struct {
-	User         *User  `json:"user"`
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	User         *User  `json:"user,omitempty"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// set instead of the fields above when the account has two-factor authentication
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+	// admins have to enroll before they can use the admin endpoints
+	TwoFactorSetupRequired bool `json:"two_factor_setup_required,omitempty"`
 }
 
 type UpdateProfileRequest struct {
@@ -96,6 +319,10 @@ type UpdateProfileRequest struct {
 	Bio      *string `json:"bio,omitempty" validate:"omitempty,max=500"`
 }
 
+type LoginHandoffRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
@@ -113,6 +340,10 @@ type EmailVerificationResponse struct {
 	User    *User  `json:"user"`
 }
 
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
 type PasswordResetRequest struct {
 	Email string `json:"email" validate:"required,email"`
 }
@@ -135,5 +366,5 @@ type ErrorResponse struct {
 }
 
 type UpdateRoleRequest struct {
-	Role string `json:"role" validate:"required,oneof=user admin"`
+	Role string `json:"role" validate:"required,max=50"` // any built-in or custom role
 }
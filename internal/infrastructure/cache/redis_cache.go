@@ -34,6 +34,24 @@ func (r *redisCache) Set(ctx context.Context, key string, value interface{}, exp
 	}
 	return r.client.Set(ctx, key, data, expiration).Err()
 }
+func (r *redisCache) Take(ctx context.Context, key string, dest interface{}) error {
+	val, err := r.client.GetDel(ctx, key).Result()
+	if err == redis.Nil {
+		return domain.ErrCacheMiss
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(val), dest)
+}
+func (r *redisCache) Increment(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	pipe := r.client.TxPipeline()
+	pipe.SetNX(ctx, key, 0, expiration)
+	count := pipe.Incr(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
 func (r *redisCache) Delete(ctx context.Context, key string) error {
 	return r.client.Del(ctx, key).Err()
 }
@@ -7,6 +7,7 @@ import (
 	"html/template"
 	"net/smtp"
 	"path/filepath"
+	"time"
 )
 
 type EmailService struct {
@@ -19,11 +20,16 @@ type EmailService struct {
 }
 
 type EmailData struct {
-	Username string
-	Token    string
-	Link     string
-	Subject  string
-	To       string
+	Username    string
+	Token       string
+	Link        string
+	Subject     string
+	To          string
+	LockedUntil string
+	NewEmail    string
+	Device      string
+	IPAddress   string
+	LoginAt     string
 }
 
 // type EmailTemplate struct {
@@ -76,6 +82,73 @@ func (e *EmailService) SendPasswordResetEmail(to, username, token string) error
 	return e.sendEmail("password_reset.html", data)
 }
 
+func (e *EmailService) SendMagicLinkEmail(to, username, token string) error {
+	data := EmailData{
+		Username: username,
+		Token:    token,
+		Link:     fmt.Sprintf("%s/api/v1/auth/magic-link/verify?token=%s", e.baseURL, token),
+		Subject:  "Your Login Link",
+		To:       to,
+	}
+
+	return e.sendEmail("magic_link.html", data)
+}
+
+// tells the owner their account was locked after repeated failed logins
+func (e *EmailService) SendAccountLockedEmail(to, username string, lockedUntil time.Time) error {
+	data := EmailData{
+		Username:    username,
+		Link:        fmt.Sprintf("%s/reset-password", e.baseURL),
+		Subject:     "Your Account Has Been Temporarily Locked",
+		To:          to,
+		LockedUntil: lockedUntil.UTC().Format("Jan 2, 2006 at 15:04 UTC"),
+	}
+
+	return e.sendEmail("account_locked.html", data)
+}
+
+func (e *EmailService) SendEmailChangeConfirmation(to, username, token string) error {
+	data := EmailData{
+		Username: username,
+		Token:    token,
+		Link:     fmt.Sprintf("%s/api/v1/auth/email-change/confirm?token=%s", e.baseURL, token),
+		Subject:  "Confirm Your New Email Address",
+		To:       to,
+	}
+
+	return e.sendEmail("email_change.html", data)
+}
+
+// sent to the old address so its owner can stop a change they didn't ask for
+func (e *EmailService) SendEmailChangeNotice(to, username, newEmail, cancelToken string) error {
+	data := EmailData{
+		Username: username,
+		Token:    cancelToken,
+		Link:     fmt.Sprintf("%s/api/v1/auth/email-change/cancel?token=%s", e.baseURL, cancelToken),
+		Subject:  "Your Email Address Is Being Changed",
+		To:       to,
+		NewEmail: newEmail,
+	}
+
+	return e.sendEmail("email_change_notice.html", data)
+}
+
+// the link signs the account out everywhere and asks for a new password
+func (e *EmailService) SendNewDeviceAlert(to, username, device, ipAddress string, loginAt time.Time, secureToken string) error {
+	data := EmailData{
+		Username:  username,
+		Token:     secureToken,
+		Link:      fmt.Sprintf("%s/api/v1/auth/secure-account?token=%s", e.baseURL, secureToken),
+		Subject:   "New Login to Your Account",
+		To:        to,
+		Device:    device,
+		IPAddress: ipAddress,
+		LoginAt:   loginAt.UTC().Format("Jan 2, 2006 at 15:04 UTC"),
+	}
+
+	return e.sendEmail("new_device.html", data)
+}
+
 func (e *EmailService) sendEmail(templateName string, data EmailData) error {
 	// Load and parse base + content templates
 	tmplt, err := template.ParseFiles(
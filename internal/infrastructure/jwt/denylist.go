@@ -0,0 +1,49 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"Blog-API/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const denylistKeyPrefix = "jwt:denylist:"
+
+// RedisDenylist keeps revoked token IDs until the tokens expire
+type RedisDenylist struct {
+	client *redis.Client
+}
+
+func NewRedisDenylist(client *redis.Client) domain.TokenDenylist {
+	return &RedisDenylist{client: client}
+}
+
+func (d *RedisDenylist) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		// already expired, nothing to deny
+		return nil
+	}
+	return d.client.Set(ctx, denylistKeyPrefix+jti, 1, ttl).Err()
+}
+
+func (d *RedisDenylist) RevokeOnce(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return false, nil
+	}
+	return d.client.SetNX(ctx, denylistKeyPrefix+jti, 1, ttl).Result()
+}
+
+func (d *RedisDenylist) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+	n, err := d.client.Exists(ctx, denylistKeyPrefix+jti).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
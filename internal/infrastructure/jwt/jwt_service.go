@@ -1,6 +1,7 @@
 package jwt
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -9,75 +10,126 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	// how long a user has to enter their second factor after the password
+	challengeExpiry = 5 * time.Minute
+	magicLinkExpiry = 15 * time.Minute
+)
+
 type JWTService struct {
-	secretKey     string
+	keys          *KeySet
 	accessExpiry  time.Duration
 	refreshExpiry time.Duration
+	denylist      domain.TokenDenylist
 }
 
-func NewJWTService(secretKey string, accessExpiry, refreshExpiry time.Duration) domain.JWTService {
+func NewJWTService(keys *KeySet, accessExpiry, refreshExpiry time.Duration, denylist domain.TokenDenylist) domain.JWTService {
 	return &JWTService{
-		secretKey:     secretKey,
+		keys:          keys,
 		accessExpiry:  accessExpiry,
 		refreshExpiry: refreshExpiry,
+		denylist:      denylist,
 	}
 }
 
 // generates a new access token
-func (j *JWTService) GenerateAccessToken(userID primitive.ObjectID, email, role string) (string, error) {
-	claims := &domain.JWTClaims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		Exp:    time.Now().Add(j.accessExpiry).Unix(),
-		Iat:    time.Now().Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secretKey))
+func (j *JWTService) GenerateAccessToken(user *domain.User, sessionID primitive.ObjectID) (string, error) {
+	return j.sign(user, sessionID, domain.TokenTypeAccess, j.accessExpiry)
 }
 
 // generates a new refresh token
-func (j *JWTService) GenerateRefreshToken(userID primitive.ObjectID, email, role string) (string, error) {
-	claims := &domain.JWTClaims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		Exp:    time.Now().Add(j.refreshExpiry).Unix(),
-		Iat:    time.Now().Unix(),
-	}
+func (j *JWTService) GenerateRefreshToken(user *domain.User, sessionID primitive.ObjectID) (string, error) {
+	return j.sign(user, sessionID, domain.TokenTypeRefresh, j.refreshExpiry)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secretKey))
+// generates the short-lived token Login hands out while the second factor is pending
+func (j *JWTService) GenerateChallengeToken(user *domain.User) (string, error) {
+	return j.sign(user, primitive.NilObjectID, domain.TokenTypeTwoFactorChallenge, challengeExpiry)
 }
 
-// validates a JWT token and returns claims
-func (j *JWTService) ValidateToken(tokenString string) (*domain.JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &domain.JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(j.secretKey), nil
-	})
+// generates the token embedded in a passwordless login link
+func (j *JWTService) GenerateMagicLinkToken(user *domain.User) (string, error) {
+	return j.sign(user, primitive.NilObjectID, domain.TokenTypeMagicLink, magicLinkExpiry)
+}
+
+// generates a token admin can use to act as user. It lives on admin's session,
+// so signing the admin out ends it too.
+func (j *JWTService) GenerateImpersonationToken(user, admin *domain.User, sessionID primitive.ObjectID, readOnly bool, expiry time.Duration) (string, error) {
+	claims := newClaims(user, sessionID, domain.TokenTypeImpersonation, expiry)
+	claims.Act = &domain.ActorClaims{UserID: admin.ID, Role: admin.Role, Version: admin.TokenVersion}
+	claims.ReadOnly = readOnly
+	return j.keys.sign(claims)
+}
+
+func (j *JWTService) sign(user *domain.User, sessionID primitive.ObjectID, tokenType string, expiry time.Duration) (string, error) {
+	return j.keys.sign(newClaims(user, sessionID, tokenType, expiry))
+}
+
+func newClaims(user *domain.User, sessionID primitive.ObjectID, tokenType string, expiry time.Duration) *domain.JWTClaims {
+	now := time.Now()
+	return &domain.JWTClaims{
+		ID:        primitive.NewObjectID().Hex(),
+		UserID:    user.ID,
+		SessionID: sessionID,
+		TokenType: tokenType,
+		Version:   user.TokenVersion,
+		Email:     user.Email,
+		Role:      user.Role,
+		Exp:       now.Add(expiry).Unix(),
+		Iat:       now.Unix(),
+	}
+}
 
+// validates a JWT token and returns claims; revoked tokens are rejected
+func (j *JWTService) ValidateToken(tokenString string) (*domain.JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &domain.JWTClaims{}, j.keys.keyFunc)
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*domain.JWTClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*domain.JWTClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	revoked, err := j.denylist.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		return nil, fmt.Errorf("token has been revoked")
 	}
 
-	return nil, fmt.Errorf("invalid token")
+	return claims, nil
 }
 
-// generates a new access token using a valid refresh token
-func (j *JWTService) RefreshAccessToken(refreshToken string) (string, error) {
-	claims, err := j.ValidateToken(refreshToken)
+// puts the token on the denylist until it would have expired anyway
+func (j *JWTService) RevokeToken(claims *domain.JWTClaims) error {
+	if claims.ID == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return j.denylist.Revoke(ctx, claims.ID, time.Unix(claims.Exp, 0))
+}
+
+// marks a single-use token as used; only the first caller succeeds
+func (j *JWTService) ConsumeToken(claims *domain.JWTClaims) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	first, err := j.denylist.RevokeOnce(ctx, claims.ID, time.Unix(claims.Exp, 0))
 	if err != nil {
-		return "", err
+		return err
 	}
+	if !first {
+		return fmt.Errorf("token has already been used")
+	}
+	return nil
+}
 
-	// Generate new access token
-	return j.GenerateAccessToken(claims.UserID, claims.Email, claims.Role)
-} 
\ No newline at end of file
+// public keys other services can verify our tokens with
+func (j *JWTService) JWKS() *domain.JSONWebKeySet {
+	return j.keys.jwks()
+}
@@ -0,0 +1,217 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"Blog-API/internal/domain"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// supported values for JWT_ALGORITHM
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// a public key tokens may be verified with, looked up by the kid header
+type verificationKey struct {
+	kid    string
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// KeySet holds the key new tokens are signed with and every key still
+// accepted for verification, so keys can be rotated without logging users out
+type KeySet struct {
+	signingMethod jwt.SigningMethod
+	signingKID    string
+	signingKey    interface{}
+	keys          map[string]verificationKey
+	order         []string // kids in the order they were configured, for the JWKS
+	secret        []byte   // HS256 tokens, which carry no kid
+}
+
+// LoadKeySet builds the key set for the given algorithm. HS256 signs with the
+// shared secret; RS256 and EdDSA sign with the PEM private key in
+// signingKeyFile. verificationKeyFiles are PEM public (or private) keys of
+// retired signing keys that are still accepted. When an asymmetric algorithm is
+// used and secret is set, HS256 tokens issued before the switch keep working.
+func LoadKeySet(algorithm, secret, signingKeyFile string, verificationKeyFiles []string) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]verificationKey)}
+	if secret != "" {
+		ks.secret = []byte(secret)
+	}
+
+	switch algorithm {
+	case AlgorithmHS256:
+		if ks.secret == nil {
+			return nil, errors.New("JWT_SECRET is required for HS256")
+		}
+		ks.signingMethod = jwt.SigningMethodHS256
+		ks.signingKey = ks.secret
+	case AlgorithmRS256, AlgorithmEdDSA:
+		if signingKeyFile == "" {
+			return nil, fmt.Errorf("JWT_SIGNING_KEY_FILE is required for %s", algorithm)
+		}
+		private, public, err := readKeyFile(signingKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if private == nil {
+			return nil, fmt.Errorf("%s does not contain a private key", signingKeyFile)
+		}
+		key, err := ks.add(public)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", signingKeyFile, err)
+		}
+		if key.method.Alg() != algorithm {
+			return nil, fmt.Errorf("%s holds a %s key but JWT_ALGORITHM is %s", signingKeyFile, key.method.Alg(), algorithm)
+		}
+		ks.signingMethod = key.method
+		ks.signingKID = key.kid
+		ks.signingKey = private
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+	}
+
+	for _, path := range verificationKeyFiles {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		_, public, err := readKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := ks.add(public); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return ks, nil
+}
+
+func (ks *KeySet) add(public crypto.PublicKey) (verificationKey, error) {
+	var method jwt.SigningMethod
+	switch public.(type) {
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return verificationKey{}, fmt.Errorf("unsupported key type %T", public)
+	}
+	jwk := toJWK(public, method)
+	key := verificationKey{kid: jwk.Kid, method: method, public: public}
+	if _, exists := ks.keys[key.kid]; !exists {
+		ks.keys[key.kid] = key
+		ks.order = append(ks.order, key.kid)
+	}
+	return key, nil
+}
+
+// sign creates a token signed with the current signing key
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signingMethod, claims)
+	if ks.signingKID != "" {
+		token.Header["kid"] = ks.signingKID
+	}
+	return token.SignedString(ks.signingKey)
+}
+
+// keyFunc picks the verification key named by the token's kid header
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || ks.secret == nil {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return ks.secret, nil
+	}
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// jwks lists the public keys in JWK form; HS256 secrets are never published
+func (ks *KeySet) jwks() *domain.JSONWebKeySet {
+	set := &domain.JSONWebKeySet{Keys: []domain.JSONWebKey{}}
+	for _, kid := range ks.order {
+		key := ks.keys[kid]
+		set.Keys = append(set.Keys, toJWK(key.public, key.method))
+	}
+	return set
+}
+
+// toJWK converts a public key to a JWK whose kid is its RFC 7638 thumbprint
+func toJWK(public crypto.PublicKey, method jwt.SigningMethod) domain.JSONWebKey {
+	jwk := domain.JSONWebKey{Use: "sig", Alg: method.Alg()}
+	var thumbprintInput []byte
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		thumbprintInput, _ = json.Marshal(map[string]string{"e": jwk.E, "kty": jwk.Kty, "n": jwk.N})
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		thumbprintInput, _ = json.Marshal(map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X})
+	}
+	// json.Marshal sorts map keys, which is the member order the thumbprint requires
+	sum := sha256.Sum256(thumbprintInput)
+	jwk.Kid = base64.RawURLEncoding.EncodeToString(sum[:])
+	return jwk
+}
+
+// readKeyFile parses a PEM file holding either a private or a public key.
+// private is nil when the file only has a public key.
+func readKeyFile(path string) (crypto.Signer, crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if signer, ok := parsed.(crypto.Signer); ok {
+		return signer, signer.Public(), nil
+	}
+	return nil, parsed, nil
+}
@@ -0,0 +1,230 @@
+package ldap
+
+import (
+	"Blog-API/internal/domain"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+// how to find users and their groups in the directory. Filters use {username}
+// and {dn} as placeholders; the values are escaped before they are filled in.
+type Config struct {
+	URL                string // ldap:// or ldaps://
+	StartTLS           bool
+	InsecureSkipVerify bool
+	BindDN             string // service account used to search; empty binds anonymously
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string
+	UsernameAttribute  string
+	EmailAttribute     string
+	IDAttribute        string // falls back to the entry's DN when the entry doesn't have it
+	GroupBaseDN        string
+	GroupFilter        string // empty reads only the user's memberOf attribute
+	AdminGroups        []string
+	RoleGroups         []string // "role=group" pairs, checked in order after AdminGroups
+	AllowedGroups      []string // empty lets every user found by UserFilter log in
+	Timeout            time.Duration
+}
+
+type ldapService struct {
+	cfg Config
+}
+
+// groups in AdminGroups, RoleGroups and AllowedGroups can be given as a full
+// DN or just the group's name (the first value of its DN), compared
+// case-insensitively
+func NewLDAPService(cfg Config) (domain.DirectoryService, error) {
+	if cfg.URL == "" || cfg.BaseDN == "" {
+		return nil, errors.New("LDAP URL and base DN are required")
+	}
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(uid={username})"
+	}
+	if cfg.UsernameAttribute == "" {
+		cfg.UsernameAttribute = "uid"
+	}
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = "mail"
+	}
+	if cfg.GroupBaseDN == "" {
+		cfg.GroupBaseDN = cfg.BaseDN
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	return &ldapService{cfg: cfg}, nil
+}
+
+func (s *ldapService) Authenticate(username, password string) (*domain.DirectoryUser, error) {
+	// an empty password is an unauthenticated bind, which most servers accept
+	if username == "" || password == "" {
+		return nil, domain.ErrDirectoryCredentials
+	}
+
+	conn, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := s.bindServiceAccount(conn); err != nil {
+		return nil, err
+	}
+	entry, err := s.findUser(conn, username)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.groupsOf(conn, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+			return nil, domain.ErrDirectoryCredentials
+		}
+		return nil, fmt.Errorf("directory bind failed: %w", err)
+	}
+
+	role := s.roleOf(groups)
+	if role == domain.RoleUser && len(s.cfg.AllowedGroups) > 0 && !inAny(groups, s.cfg.AllowedGroups) {
+		return nil, errors.New("your directory account is not allowed to log in here")
+	}
+
+	user := &domain.DirectoryUser{
+		Subject:  entry.DN,
+		Username: entry.GetAttributeValue(s.cfg.UsernameAttribute),
+		Email:    entry.GetAttributeValue(s.cfg.EmailAttribute),
+		Role:     role,
+	}
+	if s.cfg.IDAttribute != "" {
+		if id := entry.GetAttributeValue(s.cfg.IDAttribute); id != "" {
+			user.Subject = id
+		}
+	}
+	if user.Username == "" {
+		user.Username = username
+	}
+	if user.Email == "" {
+		return nil, errors.New("directory entry has no email address")
+	}
+	return user, nil
+}
+
+func (s *ldapService) connect() (*goldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: s.cfg.InsecureSkipVerify}
+	conn, err := goldap.DialURL(s.cfg.URL, goldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("directory unavailable: %w", err)
+	}
+	conn.SetTimeout(s.cfg.Timeout)
+	if s.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("directory StartTLS failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (s *ldapService) bindServiceAccount(conn *goldap.Conn) error {
+	if s.cfg.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(s.cfg.BindDN, s.cfg.BindPassword); err != nil {
+		return fmt.Errorf("directory service account bind failed: %w", err)
+	}
+	return nil
+}
+
+func (s *ldapService) findUser(conn *goldap.Conn, username string) (*goldap.Entry, error) {
+	attributes := []string{"dn", s.cfg.UsernameAttribute, s.cfg.EmailAttribute, "memberOf"}
+	if s.cfg.IDAttribute != "" {
+		attributes = append(attributes, s.cfg.IDAttribute)
+	}
+	req := goldap.NewSearchRequest(
+		s.cfg.BaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases,
+		2, int(s.cfg.Timeout.Seconds()), false,
+		strings.ReplaceAll(s.cfg.UserFilter, "{username}", goldap.EscapeFilter(username)),
+		attributes, nil,
+	)
+	res, err := conn.Search(req)
+	if err != nil && !goldap.IsErrorWithCode(err, goldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("directory search failed: %w", err)
+	}
+	switch {
+	case res == nil || len(res.Entries) == 0:
+		return nil, domain.ErrDirectoryCredentials
+	case len(res.Entries) > 1:
+		return nil, errors.New("directory search matched more than one user")
+	}
+	return res.Entries[0], nil
+}
+
+// the DNs in the user's memberOf, plus the groups GroupFilter finds
+func (s *ldapService) groupsOf(conn *goldap.Conn, entry *goldap.Entry) ([]string, error) {
+	groups := entry.GetAttributeValues("memberOf")
+	if s.cfg.GroupFilter == "" {
+		return groups, nil
+	}
+	filter := strings.NewReplacer(
+		"{dn}", goldap.EscapeFilter(entry.DN),
+		"{username}", goldap.EscapeFilter(entry.GetAttributeValue(s.cfg.UsernameAttribute)),
+	).Replace(s.cfg.GroupFilter)
+	req := goldap.NewSearchRequest(
+		s.cfg.GroupBaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases,
+		0, int(s.cfg.Timeout.Seconds()), false,
+		filter, []string{"dn"}, nil,
+	)
+	res, err := conn.Search(req)
+	if err != nil {
+		return nil, fmt.Errorf("directory group search failed: %w", err)
+	}
+	for _, group := range res.Entries {
+		groups = append(groups, group.DN)
+	}
+	return groups, nil
+}
+
+// admin for the admin groups, then the first matching RoleGroups entry,
+// otherwise user
+func (s *ldapService) roleOf(groups []string) string {
+	if inAny(groups, s.cfg.AdminGroups) {
+		return domain.RoleAdmin
+	}
+	for _, pair := range s.cfg.RoleGroups {
+		role, group, ok := strings.Cut(pair, "=")
+		if ok && inAny(groups, []string{group}) {
+			return strings.TrimSpace(role)
+		}
+	}
+	return domain.RoleUser
+}
+
+// whether any of the user's group DNs matches one of names
+func inAny(groupDNs, names []string) bool {
+	for _, dn := range groupDNs {
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			if strings.EqualFold(dn, name) || strings.EqualFold(groupName(dn), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// "cn=admins,ou=groups,dc=example,dc=com" -> "admins"
+func groupName(dn string) string {
+	parsed, err := goldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 || len(parsed.RDNs[0].Attributes) == 0 {
+		return ""
+	}
+	return parsed.RDNs[0].Attributes[0].Value
+}
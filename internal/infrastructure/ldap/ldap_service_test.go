@@ -0,0 +1,417 @@
+package ldap
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"Blog-API/internal/domain"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// an in-memory directory speaking just enough LDAP for ldapService: simple
+// binds and subtree searches with and/or/equality/present filters
+type testDirectory struct {
+	entries   []testEntry
+	passwords map[string]string // DN -> password
+}
+
+type testEntry struct {
+	dn    string
+	attrs map[string][]string
+}
+
+// the bits of RFC 4511 the stand-in needs
+const (
+	resultSuccess            = 0
+	resultSizeLimitExceeded  = 4
+	resultInvalidCredentials = 49
+
+	opBindRequest   = 0
+	opBindResponse  = 1
+	opUnbindRequest = 2
+	opSearchRequest = 3
+	opSearchEntry   = 4
+	opSearchDone    = 5
+
+	filterAnd           = 0
+	filterOr            = 1
+	filterEqualityMatch = 3
+	filterPresent       = 7
+)
+
+const (
+	serviceAccountDN       = "cn=svc,dc=example,dc=com"
+	serviceAccountPassword = "svc-secret"
+	userPassword           = "correct-horse"
+
+	testBaseDN      = "ou=people,dc=example,dc=com"
+	testGroupBaseDN = "ou=groups,dc=example,dc=com"
+	adminsDN        = "cn=admins," + testGroupBaseDN
+	editorsDN       = "cn=editors," + testGroupBaseDN
+	staffDN         = "cn=staff," + testGroupBaseDN
+	janeDN          = "uid=jane," + testBaseDN
+	bobDN           = "uid=bob," + testBaseDN
+	carolDN         = "uid=carol," + testBaseDN
+	noMailDN        = "uid=nomail," + testBaseDN
+)
+
+func newTestDirectory() *testDirectory {
+	return &testDirectory{
+		entries: []testEntry{
+			{janeDN, map[string][]string{"uid": {"jane"}, "mail": {"jane@example.com"}, "employeeNumber": {"E100"}, "memberOf": {adminsDN}, "cn": {"Jane"}}},
+			{bobDN, map[string][]string{"uid": {"bob"}, "mail": {"bob@example.com"}, "cn": {"Bob"}}},
+			{carolDN, map[string][]string{"uid": {"carol"}, "mail": {"carol@example.com"}, "cn": {"Carol"}}},
+			{noMailDN, map[string][]string{"uid": {"nomail"}, "cn": {"No Mail"}}},
+			{"uid=dup1," + testBaseDN, map[string][]string{"uid": {"dup1"}, "mail": {"dup1@example.com"}, "cn": {"Duplicate"}}},
+			{"uid=dup2," + testBaseDN, map[string][]string{"uid": {"dup2"}, "mail": {"dup2@example.com"}, "cn": {"Duplicate"}}},
+			{adminsDN, map[string][]string{"cn": {"admins"}, "member": {janeDN}}},
+			{editorsDN, map[string][]string{"cn": {"editors"}, "member": {bobDN}}},
+			{staffDN, map[string][]string{"cn": {"staff"}, "member": {bobDN, carolDN}}},
+		},
+		passwords: map[string]string{
+			serviceAccountDN: serviceAccountPassword,
+			janeDN:           userPassword,
+			bobDN:            userPassword,
+			carolDN:          userPassword,
+			noMailDN:         userPassword,
+		},
+	}
+}
+
+// listens on a random local port and returns the ldap:// URL
+func (d *testDirectory) start(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+	return "ldap://" + listener.Addr().String()
+}
+
+func (d *testDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case opBindRequest:
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			code := resultInvalidCredentials
+			if want, ok := d.passwords[dn]; ok && password != "" && password == want {
+				code = resultSuccess
+			}
+			conn.Write(response(id, result(opBindResponse, code)).Bytes())
+		case opSearchRequest:
+			d.search(conn, id, op)
+		case opUnbindRequest:
+			return
+		}
+	}
+}
+
+func (d *testDirectory) search(conn net.Conn, id int64, op *ber.Packet) {
+	base := strings.ToLower(op.Children[0].Value.(string))
+	sizeLimit := int(op.Children[3].Value.(int64))
+	filter := op.Children[6]
+	var wanted []string
+	for _, attr := range op.Children[7].Children {
+		wanted = append(wanted, attr.Value.(string))
+	}
+
+	sent := 0
+	for _, entry := range d.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.dn), base) || !entry.matches(filter) {
+			continue
+		}
+		if sizeLimit > 0 && sent == sizeLimit {
+			conn.Write(response(id, result(opSearchDone, resultSizeLimitExceeded)).Bytes())
+			return
+		}
+		conn.Write(response(id, entry.packet(wanted)).Bytes())
+		sent++
+	}
+	conn.Write(response(id, result(opSearchDone, resultSuccess)).Bytes())
+}
+
+func (e testEntry) values(name string) []string {
+	for attr, values := range e.attrs {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func (e testEntry) matches(filter *ber.Packet) bool {
+	switch filter.Tag {
+	case filterAnd:
+		for _, child := range filter.Children {
+			if !e.matches(child) {
+				return false
+			}
+		}
+		return true
+	case filterOr:
+		for _, child := range filter.Children {
+			if e.matches(child) {
+				return true
+			}
+		}
+		return false
+	case filterEqualityMatch:
+		name := filter.Children[0].Value.(string)
+		want := filter.Children[1].Value.(string)
+		for _, value := range e.values(name) {
+			if strings.EqualFold(value, want) {
+				return true
+			}
+		}
+		return false
+	case filterPresent:
+		return len(e.values(filter.Data.String())) > 0
+	}
+	return false
+}
+
+func (e testEntry) packet(wanted []string) *ber.Packet {
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, opSearchEntry, nil, "SearchResultEntry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "objectName"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for _, name := range wanted {
+		values := e.values(name)
+		if len(values) == 0 {
+			continue
+		}
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		}
+		attr.AppendChild(set)
+		attributes.AppendChild(attr)
+	}
+	entry.AppendChild(attributes)
+	return entry
+}
+
+func result(tag ber.Tag, code int) *ber.Packet {
+	res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "LDAPResult")
+	res.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "resultCode"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return res
+}
+
+func response(id int64, op *ber.Packet) *ber.Packet {
+	msg := ber.NewSequence("LDAPMessage")
+	msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "messageID"))
+	msg.AppendChild(op)
+	return msg
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	url := newTestDirectory().start(t)
+	base := Config{
+		URL:          url,
+		BindDN:       serviceAccountDN,
+		BindPassword: serviceAccountPassword,
+		BaseDN:       testBaseDN,
+		GroupBaseDN:  testGroupBaseDN,
+		AdminGroups:  []string{"admins"},
+		RoleGroups:   []string{"editor=" + editorsDN},
+	}
+
+	tests := []struct {
+		name     string
+		cfg      func(cfg *Config)
+		username string
+		password string
+		want     *domain.DirectoryUser
+		wantErr  error  // compared with errors.Is
+		errText  string // or a substring of the error
+	}{
+		{
+			name:     "admin through memberOf",
+			username: "jane",
+			password: userPassword,
+			want:     &domain.DirectoryUser{Subject: janeDN, Username: "jane", Email: "jane@example.com", Role: domain.RoleAdmin},
+		},
+		{
+			name:     "ID attribute replaces the DN",
+			cfg:      func(cfg *Config) { cfg.IDAttribute = "employeeNumber" },
+			username: "jane",
+			password: userPassword,
+			want:     &domain.DirectoryUser{Subject: "E100", Username: "jane", Email: "jane@example.com", Role: domain.RoleAdmin},
+		},
+		{
+			name:     "role from a group search",
+			cfg:      func(cfg *Config) { cfg.GroupFilter = "(member={dn})" },
+			username: "bob",
+			password: userPassword,
+			want:     &domain.DirectoryUser{Subject: bobDN, Username: "bob", Email: "bob@example.com", Role: "editor"},
+		},
+		{
+			name:     "plain user without groups",
+			username: "carol",
+			password: userPassword,
+			want:     &domain.DirectoryUser{Subject: carolDN, Username: "carol", Email: "carol@example.com", Role: domain.RoleUser},
+		},
+		{
+			name: "allowed group lets a user in",
+			cfg: func(cfg *Config) {
+				cfg.GroupFilter = "(member={dn})"
+				cfg.AllowedGroups = []string{"staff"}
+			},
+			username: "carol",
+			password: userPassword,
+			want:     &domain.DirectoryUser{Subject: carolDN, Username: "carol", Email: "carol@example.com", Role: domain.RoleUser},
+		},
+		{
+			name:     "user outside the allowed groups",
+			cfg:      func(cfg *Config) { cfg.AllowedGroups = []string{"staff"} },
+			username: "carol",
+			password: userPassword,
+			errText:  "not allowed to log in",
+		},
+		{
+			name:     "mapped roles skip the allowed groups check",
+			cfg:      func(cfg *Config) { cfg.AllowedGroups = []string{"staff"} },
+			username: "jane",
+			password: userPassword,
+			want:     &domain.DirectoryUser{Subject: janeDN, Username: "jane", Email: "jane@example.com", Role: domain.RoleAdmin},
+		},
+		{
+			name:     "wrong password",
+			username: "jane",
+			password: "wrong",
+			wantErr:  domain.ErrDirectoryCredentials,
+		},
+		{
+			name:     "empty password",
+			username: "jane",
+			password: "",
+			wantErr:  domain.ErrDirectoryCredentials,
+		},
+		{
+			name:     "unknown user",
+			username: "nobody",
+			password: userPassword,
+			wantErr:  domain.ErrDirectoryCredentials,
+		},
+		{
+			name:     "filter injection is escaped",
+			username: "*",
+			password: userPassword,
+			wantErr:  domain.ErrDirectoryCredentials,
+		},
+		{
+			name:     "several matching entries",
+			cfg:      func(cfg *Config) { cfg.UserFilter = "(cn={username})" },
+			username: "Duplicate",
+			password: userPassword,
+			errText:  "more than one user",
+		},
+		{
+			name:     "entry without email",
+			username: "nomail",
+			password: userPassword,
+			errText:  "no email address",
+		},
+		{
+			name:     "wrong service account password",
+			cfg:      func(cfg *Config) { cfg.BindPassword = "wrong" },
+			username: "jane",
+			password: userPassword,
+			errText:  "service account bind failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			if tt.cfg != nil {
+				tt.cfg(&cfg)
+			}
+			svc, err := NewLDAPService(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := svc.Authenticate(tt.username, tt.password)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.errText != "":
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Fatalf("Authenticate() error = %v, want one containing %q", err, tt.errText)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Authenticate() error = %v", err)
+				}
+				if *got != *tt.want {
+					t.Errorf("Authenticate() = %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLDAPDirectoryUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "ldap://" + listener.Addr().String()
+	listener.Close()
+
+	svc, err := NewLDAPService(Config{URL: url, BaseDN: testBaseDN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Authenticate("jane", userPassword)
+	if err == nil || errors.Is(err, domain.ErrDirectoryCredentials) {
+		t.Errorf("Authenticate() error = %v, want a connection error", err)
+	}
+}
+
+func TestInAny(t *testing.T) {
+	groups := []string{"cn=Admins,ou=groups,dc=example,dc=com", "cn=staff,ou=groups,dc=example,dc=com"}
+	tests := []struct {
+		names []string
+		want  bool
+	}{
+		{[]string{"admins"}, true},
+		{[]string{" STAFF "}, true},
+		{[]string{"CN=admins,OU=groups,DC=example,DC=com"}, true},
+		{[]string{"editors"}, false},
+		{[]string{"groups"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := inAny(groups, tt.names); got != tt.want {
+			t.Errorf("inAny(%v) = %v, want %v", tt.names, got, tt.want)
+		}
+	}
+}
@@ -1,28 +1,46 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"Blog-API/internal/domain"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// how stale a session's last_activity may get before a request refreshes it
+const activityUpdateInterval = time.Minute
+
 type AuthMiddleware struct {
-	jwtService  domain.JWTService
-	sessionRepo domain.SessionRepository
+	jwtService   domain.JWTService
+	sessionRepo  domain.SessionRepository
+	userRepo     domain.UserRepository
+	accessTokens domain.PersonalAccessTokenRepository
+	permissions  domain.PermissionChecker
+	audit        domain.AuditLog
 }
 
-func NewAuthMiddleware(jwtService domain.JWTService, sessionRepo domain.SessionRepository) *AuthMiddleware {
+func NewAuthMiddleware(jwtService domain.JWTService, sessionRepo domain.SessionRepository, userRepo domain.UserRepository, accessTokens domain.PersonalAccessTokenRepository, permissions domain.PermissionChecker, audit domain.AuditLog) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtService:  jwtService,
-		sessionRepo: sessionRepo,
+		jwtService:   jwtService,
+		sessionRepo:  sessionRepo,
+		userRepo:     userRepo,
+		accessTokens: accessTokens,
+		permissions:  permissions,
+		audit:        audit,
 	}
 }
 
-// checks if user is authenticated
-func (a *AuthMiddleware) AuthRequired() gin.HandlerFunc {
+// checks if user is authenticated. Personal access tokens are only accepted
+// when the route lists scopes, and must hold one of them.
+func (a *AuthMiddleware) AuthRequired(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := extractToken(c)
 		if token == "" {
@@ -30,67 +48,234 @@ func (a *AuthMiddleware) AuthRequired() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if strings.HasPrefix(token, domain.AccessTokenPrefix) {
+			a.authenticateAccessToken(c, token, scopes)
+			return
+		}
 
 		claims, err := a.jwtService.ValidateToken(token)
-		if err != nil {
+		if err == nil && claims.TokenType == domain.TokenTypeImpersonation {
+			a.authenticateImpersonation(c, claims)
+			return
+		}
+		if err != nil || claims.TokenType != domain.TokenTypeAccess {
 			c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		// Additional security: Check if session exists and is active
-		session, err := a.sessionRepo.GetByUserID(claims.UserID)
-		if err != nil {
+		// Additional security: the device session the token was issued for must still exist
+		session, err := a.sessionRepo.GetByID(claims.SessionID)
+		if err != nil || session.UserID != claims.UserID {
 			c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Session not found"})
 			c.Abort()
 			return
 		}
 
-		if !session.IsActive {
+		if !session.IsActive || time.Now().After(session.ExpiresAt) {
 			c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Session inactive"})
 			c.Abort()
 			return
 		}
 
+		// suspended and banned users are turned away even with a valid token
+		user, err := a.userRepo.GetByID(claims.UserID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "User not found"})
+			c.Abort()
+			return
+		}
+		// role and password changes bump the version, retiring older tokens
+		if claims.Version != user.TokenVersion {
+			c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Token is no longer valid, please log in again"})
+			c.Abort()
+			return
+		}
+		if restriction := user.ActiveRestriction(); restriction != nil {
+			c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: restriction.Error()})
+			c.Abort()
+			return
+		}
+		if user.PasswordResetRequired {
+			c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: "A password reset is required before this account can be used"})
+			c.Abort()
+			return
+		}
+
+		if time.Since(session.LastActivity) > activityUpdateInterval {
+			a.sessionRepo.UpdateLastActivity(session.ID)
+		}
+
 		// Set user info in context
 		c.Set("user_id", claims.UserID)
+		c.Set("session_id", claims.SessionID)
 		c.Set("user_email", claims.Email)
 		c.Set("user_role", claims.Role)
+		c.Set("token_claims", claims)
+		c.Set("two_factor_enabled", user.TwoFactorEnabled())
 
 		c.Next()
 	}
 }
 
-// checks if user is admin
-func (a *AuthMiddleware) AdminRequired() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// First check if user is authenticated
-
-		// a.AuthRequired()(c)
-		// if c.IsAborted() {
-		// 	return
-		// }
-		// if !exists {
-		// 	c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "User role not found"})
-		// 	c.Abort()
-		// 	return
-		// }
-
-		// if role != "admin" {
-		// 	c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: "Admin access required"})
-		// 	c.Abort()
-		// 	return
-		// }
+func (a *AuthMiddleware) authenticateAccessToken(c *gin.Context, token string, scopes []string) {
+	if len(scopes) == 0 {
+		c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: "Personal access tokens can't be used for this endpoint"})
+		c.Abort()
+		return
+	}
 
-		role, exists := GetUserRoleFromContext(c)
-		if !exists {
-			c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Authorization information not found in context"})
-			c.Abort()
-			return
-		}
-		// Then check if user is admin
-		if role != domain.RoleAdmin {
-			c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: "Forbidden: Adminstrator access required"})
+	sum := sha256.Sum256([]byte(token))
+	accessToken, err := a.accessTokens.GetByHash(hex.EncodeToString(sum[:]))
+	if err != nil || accessToken.Expired() {
+		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Invalid or expired token"})
+		c.Abort()
+		return
+	}
+	allowed := false
+	for _, scope := range scopes {
+		allowed = allowed || accessToken.HasScope(scope)
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: "Token is missing the required scope: " + strings.Join(scopes, " or ")})
+		c.Abort()
+		return
+	}
+
+	user, err := a.userRepo.GetByID(accessToken.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "User not found"})
+		c.Abort()
+		return
+	}
+	if restriction := user.ActiveRestriction(); restriction != nil {
+		c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: restriction.Error()})
+		c.Abort()
+		return
+	}
+	// set by the "this wasn't me" link; the account stays closed until the owner resets the password
+	if user.PasswordResetRequired {
+		c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: "A password reset is required before this account can be used"})
+		c.Abort()
+		return
+	}
+
+	if accessToken.LastUsedAt == nil || time.Since(*accessToken.LastUsedAt) > activityUpdateInterval {
+		a.accessTokens.UpdateLastUsed(accessToken.ID)
+	}
+
+	// no session or token claims, so session-only handlers can't be reached
+	c.Set("user_id", user.ID)
+	c.Set("user_email", user.Email)
+	c.Set("user_role", user.Role)
+	c.Set("access_token_id", accessToken.ID)
+	c.Set("two_factor_enabled", user.TwoFactorEnabled())
+
+	c.Next()
+}
+
+// an admin acting as another user. The token lives on the admin's session and
+// stops working once the admin is signed out, loses the permission or is
+// restricted. Every request is logged and written to the audit log.
+func (a *AuthMiddleware) authenticateImpersonation(c *gin.Context, claims *domain.JWTClaims) {
+	if claims.Act == nil {
+		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Invalid or expired token"})
+		c.Abort()
+		return
+	}
+	if claims.ReadOnly && !isReadOnlyMethod(c.Request.Method) {
+		c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: "This impersonation token is read-only"})
+		c.Abort()
+		return
+	}
+
+	session, err := a.sessionRepo.GetByID(claims.SessionID)
+	if err != nil || session.UserID != claims.Act.UserID || !session.IsActive || time.Now().After(session.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Impersonation has ended, the admin's session is no longer active"})
+		c.Abort()
+		return
+	}
+	admin, err := a.userRepo.GetByID(claims.Act.UserID)
+	if err != nil || admin.TokenVersion != claims.Act.Version || admin.ActiveRestriction() != nil {
+		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Impersonation has ended, please start a new one"})
+		c.Abort()
+		return
+	}
+	// custom roles can lose the permission without the admin's token version changing
+	allowed, err := a.permissions.HasPermission(admin.Role, domain.PermissionUserImpersonate)
+	if err != nil || !allowed {
+		c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: "Forbidden: requires the " + domain.PermissionUserImpersonate + " permission"})
+		c.Abort()
+		return
+	}
+
+	user, err := a.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "User not found"})
+		c.Abort()
+		return
+	}
+	if claims.Version != user.TokenVersion {
+		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Token is no longer valid, please start a new impersonation"})
+		c.Abort()
+		return
+	}
+	if restriction := user.ActiveRestriction(); restriction != nil {
+		c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: restriction.Error()})
+		c.Abort()
+		return
+	}
+	// the user's role may have gained permissions since the token was issued
+	if privileged, err := a.permissions.IsPrivileged(user.Role); err != nil || privileged {
+		c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: "Users with the " + user.Role + " role cannot be impersonated"})
+		c.Abort()
+		return
+	}
+
+	if time.Since(session.LastActivity) > activityUpdateInterval {
+		a.sessionRepo.UpdateLastActivity(session.ID)
+	}
+
+	// handlers see the impersonated user; impersonator_id tags what they do
+	c.Set("user_id", user.ID)
+	c.Set("session_id", claims.SessionID)
+	c.Set("user_email", user.Email)
+	c.Set("user_role", user.Role)
+	c.Set("token_claims", claims)
+	c.Set("two_factor_enabled", user.TwoFactorEnabled())
+	c.Set("impersonator_id", admin.ID)
+
+	c.Next()
+
+	status := c.Writer.Status()
+	log.Printf("[impersonation] admin %s as user %s: %s %s -> %d", admin.ID.Hex(), user.ID.Hex(), c.Request.Method, c.Request.URL.Path, status)
+	var requestErr error
+	if status >= http.StatusBadRequest {
+		requestErr = errors.New(http.StatusText(status))
+	}
+	a.audit.Record(&domain.AuditEntry{
+		ActorID:        user.ID,
+		ActorRole:      user.Role,
+		ImpersonatorID: admin.ID,
+		Action:         domain.AuditImpersonatedRequest,
+		TargetType:     domain.AuditTargetUser,
+		TargetID:       user.ID.Hex(),
+		IPAddress:      c.ClientIP(),
+		UserAgent:      c.Request.UserAgent(),
+		After: map[string]string{
+			"method": c.Request.Method,
+			"path":   c.Request.URL.Path,
+			"status": strconv.Itoa(status),
+		},
+	}, requestErr)
+}
+
+// turns away impersonation tokens, for routes that change how the account is
+// signed in to or could be used to keep access after the impersonation ends
+func (a *AuthMiddleware) NoImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, impersonating := GetImpersonatorIDFromContext(c); impersonating {
+			c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: "This endpoint can't be used while impersonating a user"})
 			c.Abort()
 			return
 		}
@@ -98,26 +283,40 @@ func (a *AuthMiddleware) AdminRequired() gin.HandlerFunc {
 	}
 }
 
-// middleware checks for token but doesn't require it
-func (a *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// checks that the user's role grants one of the permissions. Privileged
+// users must have enrolled in two-factor authentication.
+func (a *AuthMiddleware) RequirePermission(permissions ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := extractToken(c)
-		if token == "" {
-			c.Next()
+		role, exists := GetUserRoleFromContext(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, domain.ErrorResponse{Error: "Authorization information not found in context"})
+			c.Abort()
 			return
 		}
-
-		claims, err := a.jwtService.ValidateToken(token)
-		if err != nil {
-			c.Next()
+		allowed := false
+		for _, permission := range permissions {
+			ok, err := a.permissions.HasPermission(role, permission)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, domain.ErrorResponse{Error: "Failed to check permissions"})
+				c.Abort()
+				return
+			}
+			allowed = allowed || ok
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: "Forbidden: requires the " + strings.Join(permissions, " or ") + " permission"})
+			c.Abort()
+			return
+		}
+		if !c.GetBool("two_factor_enabled") {
+			c.JSON(http.StatusForbidden, domain.ErrorResponse{Error: "Two-factor authentication must be enabled for privileged accounts, set it up at /api/v1/auth/2fa/setup"})
+			c.Abort()
 			return
 		}
-
-		// Set user info in context if token is valid
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_role", claims.Role)
-
 		c.Next()
 	}
 }
@@ -165,3 +364,33 @@ func GetUserRoleFromContext(c *gin.Context) (string, bool) {
 
 	return "", false
 }
+
+// extracts the current device session ID from gin context
+func GetSessionIDFromContext(c *gin.Context) (primitive.ObjectID, bool) {
+	sessionID, exists := c.Get("session_id")
+	if !exists {
+		return primitive.NilObjectID, false
+	}
+	id, ok := sessionID.(primitive.ObjectID)
+	return id, ok
+}
+
+// extracts the admin behind an impersonation token from gin context
+func GetImpersonatorIDFromContext(c *gin.Context) (primitive.ObjectID, bool) {
+	impersonatorID, exists := c.Get("impersonator_id")
+	if !exists {
+		return primitive.NilObjectID, false
+	}
+	id, ok := impersonatorID.(primitive.ObjectID)
+	return id, ok
+}
+
+// extracts the validated access token claims from gin context
+func GetTokenClaimsFromContext(c *gin.Context) (*domain.JWTClaims, bool) {
+	claims, exists := c.Get("token_claims")
+	if !exists {
+		return nil, false
+	}
+	cl, ok := claims.(*domain.JWTClaims)
+	return cl, ok
+}
@@ -0,0 +1,78 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"Blog-API/internal/domain"
+
+	"golang.org/x/oauth2"
+)
+
+func TestGitHubUsesPrimaryEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		emails  []map[string]interface{}
+		want    *domain.OAuthUserInfo
+		wantErr bool
+	}{
+		{
+			name: "verified primary",
+			emails: []map[string]interface{}{
+				{"email": "old@example.com", "primary": false, "verified": true},
+				{"email": "jane@example.com", "primary": true, "verified": true},
+			},
+			want: &domain.OAuthUserInfo{Subject: "42", Email: "jane@example.com", Username: "jane", EmailVerified: true},
+		},
+		{
+			name: "unverified primary",
+			emails: []map[string]interface{}{
+				{"email": "jane@example.com", "primary": true, "verified": false},
+			},
+			want: &domain.OAuthUserInfo{Subject: "42", Email: "jane@example.com", Username: "jane"},
+		},
+		{
+			name:    "no primary",
+			emails:  []map[string]interface{}{{"email": "jane@example.com", "primary": false, "verified": true}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, map[string]interface{}{"access_token": "access-token", "token_type": "Bearer"})
+			})
+			// the profile email is deliberately not the primary one
+			mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, map[string]interface{}{"id": 42, "login": "jane", "email": "typed-in@example.com"})
+			})
+			mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, tt.emails)
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			p := &githubProvider{
+				config: &oauth2.Config{ClientID: "id", Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/token"}},
+				apiURL: server.URL,
+			}
+			info, err := p.exchange(context.Background(), "code", &domain.OAuthFlow{})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("exchange() = %+v, want an error", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("exchange() error = %v", err)
+			}
+			if *info != *tt.want {
+				t.Errorf("exchange() = %+v, want %+v", info, tt.want)
+			}
+		})
+	}
+}
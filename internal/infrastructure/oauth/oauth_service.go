@@ -6,86 +6,154 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"regexp"
 
 	"golang.org/x/oauth2"
 	googleAPI "google.golang.org/api/oauth2/v2"
 	"google.golang.org/api/option"
 )
 
+// a login provider, looked up by the name in the /auth/:provider routes
+type provider interface {
+	authURL(flow *domain.OAuthFlow) (string, error)
+	exchange(ctx context.Context, code string, flow *domain.OAuthFlow) (*domain.OAuthUserInfo, error)
+}
+
 type oauthService struct {
-	googleConfig *oauth2.Config
-	githubConfig *oauth2.Config
+	providers map[string]provider
 }
 
-func NewOAuthService(googleCfg, githubCfg *oauth2.Config) domain.OAuthService {
-	return &oauthService{
-		googleConfig: googleCfg,
-		githubConfig: githubCfg,
+// provider names end up in URLs and linked identities
+var providerName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+func NewOAuthService(googleCfg, githubCfg *oauth2.Config, oidcProviders []OIDCConfig) (domain.OAuthService, error) {
+	s := &oauthService{
+		providers: map[string]provider{
+			"google": &googleProvider{config: googleCfg},
+			"github": &githubProvider{config: githubCfg, apiURL: "https://api.github.com"},
+		},
+	}
+	for _, cfg := range oidcProviders {
+		if !providerName.MatchString(cfg.Name) {
+			return nil, fmt.Errorf("invalid OIDC provider name %q", cfg.Name)
+		}
+		if _, exists := s.providers[cfg.Name]; exists {
+			return nil, fmt.Errorf("OIDC provider %q is defined more than once", cfg.Name)
+		}
+		p, err := newOIDCProvider(cfg, nil)
+		if err != nil {
+			return nil, fmt.Errorf("OIDC provider %q: %w", cfg.Name, err)
+		}
+		s.providers[cfg.Name] = p
 	}
+	return s, nil
 }
 
-func (s *oauthService) GetAuthURL(provider, state string) (string, error) {
-	switch provider {
-	case "google":
-		return s.googleConfig.AuthCodeURL(state), nil
-	case "github":
-		return s.githubConfig.AuthCodeURL(state), nil
-	default:
+func (s *oauthService) GetAuthURL(provider string, flow *domain.OAuthFlow) (string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
 		return "", errors.New("unsupported oauth provider")
 	}
+	return p.authURL(flow)
 }
 
-func (s *oauthService) ExchangeCodeForToken(provider, code string) (*oauth2.Token, error) {
-	switch provider {
-	case "google":
-		return s.googleConfig.Exchange(context.Background(), code)
-	case "github":
-		return s.githubConfig.Exchange(context.Background(), code)
-	default:
+func (s *oauthService) Exchange(provider, code string, flow *domain.OAuthFlow) (*domain.OAuthUserInfo, error) {
+	p, ok := s.providers[provider]
+	if !ok {
 		return nil, errors.New("unsupported oauth provider")
 	}
+	return p.exchange(context.Background(), code, flow)
+}
+
+// PKCE parameters for the authorization request and the code exchange
+func challengeOptions(flow *domain.OAuthFlow) []oauth2.AuthCodeOption {
+	if flow.CodeVerifier == "" {
+		return nil
+	}
+	return []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(flow.CodeVerifier)}
 }
 
-func (s *oauthService) GetUserInfo(provider string, token *oauth2.Token) (oauthID, email, username string, err error) {
-	switch provider {
-	case "google":
-		return s.getGoogleUserInfo(token)
-	case "github":
-		return s.getGitHubUserInfo(token)
-	default:
-		return "", "", "", errors.New("unsupported oauth provider")
+func verifierOptions(flow *domain.OAuthFlow) []oauth2.AuthCodeOption {
+	if flow.CodeVerifier == "" {
+		return nil
 	}
+	return []oauth2.AuthCodeOption{oauth2.VerifierOption(flow.CodeVerifier)}
 }
 
-func (s *oauthService) getGoogleUserInfo(token *oauth2.Token) (string, string, string, error) {
-	service, err := googleAPI.NewService(context.Background(), option.WithTokenSource(s.googleConfig.TokenSource(context.Background(), token)))
+type googleProvider struct {
+	config *oauth2.Config
+}
+
+func (p *googleProvider) authURL(flow *domain.OAuthFlow) (string, error) {
+	return p.config.AuthCodeURL(flow.State, challengeOptions(flow)...), nil
+}
+
+func (p *googleProvider) exchange(ctx context.Context, code string, flow *domain.OAuthFlow) (*domain.OAuthUserInfo, error) {
+	token, err := p.config.Exchange(ctx, code, verifierOptions(flow)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	service, err := googleAPI.NewService(ctx, option.WithTokenSource(p.config.TokenSource(ctx, token)))
 	if err != nil {
-		return "", "", "", err
+		return nil, err
 	}
 	userInfo, err := service.Userinfo.Get().Do()
 	if err != nil {
-		return "", "", "", err
+		return nil, fmt.Errorf("failed to get user info: %w", err)
 	}
-	return userInfo.Id, userInfo.Email, userInfo.Name, nil
+	verified := userInfo.VerifiedEmail != nil && *userInfo.VerifiedEmail
+	return &domain.OAuthUserInfo{Subject: userInfo.Id, Email: userInfo.Email, Username: userInfo.Name, EmailVerified: verified}, nil
 }
 
-func (s *oauthService) getGitHubUserInfo(token *oauth2.Token) (string, string, string, error) {
-	client := s.githubConfig.Client(context.Background(), token)
-	resp, err := client.Get("https://api.github.com/user")
+type githubProvider struct {
+	config *oauth2.Config
+	apiURL string
+}
+
+func (p *githubProvider) authURL(flow *domain.OAuthFlow) (string, error) {
+	return p.config.AuthCodeURL(flow.State, challengeOptions(flow)...), nil
+}
+
+func (p *githubProvider) exchange(ctx context.Context, code string, flow *domain.OAuthFlow) (*domain.OAuthUserInfo, error) {
+	token, err := p.config.Exchange(ctx, code, verifierOptions(flow)...)
 	if err != nil {
-		return "", "", "", err
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
 	}
-	defer resp.Body.Close()
+	client := p.config.Client(ctx, token)
 	var userInfo struct {
 		ID    int    `json:"id"`
 		Login string `json:"login"`
-		Email string `json:"email"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
-		return "", "", "", err
+	if err := getGitHubJSON(client, p.apiURL+"/user", &userInfo); err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
+	}
+	// the profile email is whatever the user typed in and may be unverified,
+	// so use the primary address from the email list, which says whether it is
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getGitHubJSON(client, p.apiURL+"/user/emails", &emails); err != nil {
+		return nil, fmt.Errorf("failed to get email addresses: %w", err)
 	}
-	if userInfo.Email == "" {
-		return "", "", "", errors.New("github email is private or not set")
+	for _, e := range emails {
+		if e.Primary {
+			return &domain.OAuthUserInfo{Subject: fmt.Sprint(userInfo.ID), Email: e.Email, Username: userInfo.Login, EmailVerified: e.Verified}, nil
+		}
+	}
+	return nil, errors.New("github account has no primary email address")
+}
+
+func getGitHubJSON(client *http.Client, url string, dest interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("github returned %s", resp.Status)
 	}
-	return fmt.Sprint(userInfo.ID), userInfo.Email, userInfo.Login, nil
+	return json.NewDecoder(resp.Body).Decode(dest)
 }
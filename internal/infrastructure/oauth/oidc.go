@@ -0,0 +1,392 @@
+package oauth
+
+import (
+	"Blog-API/internal/domain"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// an OpenID Connect provider such as a company SSO, found through discovery
+type OIDCConfig struct {
+	Name          string // used in the /auth/:provider routes
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string // dots reach into nested claims, e.g. "ext.login"
+	EmailClaim    string
+	// accept emails without email_verified, for providers that only hand out
+	// addresses they own and don't send the claim
+	TrustEmail bool
+}
+
+const (
+	// signing keys are fetched again for an unknown kid, but not more often than this
+	jwksRefreshInterval = time.Minute
+	// allowed clock difference with the provider
+	idTokenLeeway = time.Minute
+)
+
+// algorithms accepted on ID tokens; "none" and HMAC never are
+var idTokenAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+type oidcProvider struct {
+	cfg    OIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]crypto.PublicKey
+	keysAt    time.Time
+}
+
+// the parts of /.well-known/openid-configuration we use
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// client may be nil. Discovery happens on first use, so a provider that is
+// down doesn't stop the server from starting.
+func newOIDCProvider(cfg OIDCConfig, client *http.Client) (*oidcProvider, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, errors.New("issuer and client ID are required")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "preferred_username"
+	}
+	if cfg.EmailClaim == "" {
+		cfg.EmailClaim = "email"
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &oidcProvider{cfg: cfg, client: client}, nil
+}
+
+func (p *oidcProvider) authURL(flow *domain.OAuthFlow) (string, error) {
+	config, err := p.oauth2Config(context.Background())
+	if err != nil {
+		return "", err
+	}
+	opts := append(challengeOptions(flow), oauth2.SetAuthURLParam("nonce", flow.Nonce))
+	return config.AuthCodeURL(flow.State, opts...), nil
+}
+
+func (p *oidcProvider) exchange(ctx context.Context, code string, flow *domain.OAuthFlow) (*domain.OAuthUserInfo, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config, err := p.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := config.Exchange(ctx, code, verifierOptions(flow)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, errors.New("provider did not return an ID token")
+	}
+	claims, err := p.verifyIDToken(ctx, discovery, rawIDToken, flow.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// fall back to the userinfo endpoint for claims the ID token doesn't carry
+	if (lookupClaim(claims, p.cfg.EmailClaim) == "" || lookupClaim(claims, p.cfg.UsernameClaim) == "") && discovery.UserinfoEndpoint != "" {
+		if err := p.mergeUserInfo(ctx, discovery, token, claims); err != nil {
+			return nil, err
+		}
+	}
+	return p.userInfo(claims)
+}
+
+func (p *oidcProvider) oauth2Config(ctx context.Context) (*oauth2.Config, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		},
+	}, nil
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	url := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, url, "", &discovery); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	// the issuer must match exactly (OpenID Connect Discovery 1.0, section 4.3)
+	if discovery.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("OIDC discovery returned issuer %q, expected %q", discovery.Issuer, p.cfg.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing endpoints")
+	}
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *oidcProvider) verifyIDToken(ctx context.Context, discovery *oidcDiscovery, raw, nonce string) (jwt.MapClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(idTokenAlgorithms),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(idTokenLeeway),
+	)
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	if nonce == "" || lookupClaim(claims, "nonce") != nonce {
+		return nil, errors.New("invalid ID token: nonce does not match")
+	}
+	// with several audiences the token must name us as the authorized party
+	if aud, _ := claims.GetAudience(); len(aud) > 1 && lookupClaim(claims, "azp") != p.cfg.ClientID {
+		return nil, errors.New("invalid ID token: unexpected authorized party")
+	}
+	if sub, _ := claims.GetSubject(); sub == "" {
+		return nil, errors.New("invalid ID token: missing subject")
+	}
+	return claims, nil
+}
+
+// the provider's public key with the given kid, refetching the key set if it
+// isn't known yet (the provider may have rotated)
+func (p *oidcProvider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := p.fetchKeys(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// tokens without a kid are only accepted while the provider has a single key
+func (p *oidcProvider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *oidcProvider) fetchKeys(ctx context.Context) error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.discovery.JWKSURI, "", &set); err != nil {
+		return fmt.Errorf("failed to fetch OIDC signing keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// skip key types we don't support rather than failing on all of them
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	p.keys = keys
+	p.keysAt = time.Now()
+	return nil
+}
+
+func (p *oidcProvider) mergeUserInfo(ctx context.Context, discovery *oidcDiscovery, token *oauth2.Token, claims jwt.MapClaims) error {
+	info := map[string]interface{}{}
+	if err := p.getJSON(ctx, discovery.UserinfoEndpoint, token.AccessToken, &info); err != nil {
+		return fmt.Errorf("failed to get user info: %w", err)
+	}
+	// userinfo responses for another subject must be ignored (OIDC Core 5.3.2)
+	if sub, _ := info["sub"].(string); sub != claims["sub"] {
+		return errors.New("user info subject does not match the ID token")
+	}
+	for name, value := range info {
+		if _, ok := claims[name]; !ok {
+			claims[name] = value
+		}
+	}
+	return nil
+}
+
+func (p *oidcProvider) userInfo(claims jwt.MapClaims) (*domain.OAuthUserInfo, error) {
+	email := lookupClaim(claims, p.cfg.EmailClaim)
+	if email == "" {
+		return nil, fmt.Errorf("%s did not provide an email address", p.cfg.Name)
+	}
+	// new accounts are created with a verified email, so the provider has to
+	// vouch for it; a missing claim or a string "true" isn't enough
+	if verified, _ := claims["email_verified"].(bool); !verified && !p.cfg.TrustEmail {
+		return nil, fmt.Errorf("%s has not verified the email address", p.cfg.Name)
+	}
+	username := lookupClaim(claims, p.cfg.UsernameClaim)
+	if username == "" {
+		username = strings.SplitN(email, "@", 2)[0]
+	}
+	sub, _ := claims.GetSubject()
+	return &domain.OAuthUserInfo{Subject: sub, Email: email, Username: username, EmailVerified: true}, nil
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, url, accessToken string, dest interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
+
+// string claim at a dotted path
+func lookupClaim(claims map[string]interface{}, path string) string {
+	var value interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = m[part]
+	}
+	s, _ := value.(string)
+	return s
+}
+
+// a key from the provider's JWKS (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,364 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"Blog-API/internal/domain"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testClientID = "blog-api"
+
+// an OpenID provider serving discovery, a JWKS, a token endpoint and userinfo
+type mockOIDCProvider struct {
+	t        *testing.T
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	issuer   string // reported in discovery; defaults to the server URL
+	userinfo map[string]interface{}
+
+	idToken      string // returned by the token endpoint
+	gotVerifier  string // code_verifier of the last token request
+	gotUserinfos int
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOIDCProvider{t: t, key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := m.issuer
+		if issuer == "" {
+			issuer = m.server.URL
+		}
+		writeJSON(w, map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"userinfo_endpoint":      m.server.URL + "/userinfo",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		m.gotVerifier = r.PostForm.Get("code_verifier")
+		if r.PostForm.Get("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     m.idToken,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		m.gotUserinfos++
+		if r.Header.Get("Authorization") != "Bearer access-token" || m.userinfo == nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, m.userinfo)
+	})
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// ID token claims that pass every check; tests change what they need
+func (m *mockOIDCProvider) claims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":                m.server.URL,
+		"aud":                testClientID,
+		"sub":                "user-123",
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              nonce,
+		"email":              "jane@corp.example.com",
+		"email_verified":     true,
+		"preferred_username": "jane",
+	}
+}
+
+func (m *mockOIDCProvider) sign(claims jwt.MapClaims, key *rsa.PrivateKey) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return signed
+}
+
+func (m *mockOIDCProvider) provider(t *testing.T, cfg OIDCConfig) *oidcProvider {
+	t.Helper()
+	cfg.Name = "corp"
+	cfg.Issuer = m.server.URL
+	cfg.ClientID = testClientID
+	cfg.RedirectURL = "http://localhost:8080/api/v1/auth/corp/callback"
+	p, err := newOIDCProvider(cfg, m.server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+var testFlow = &domain.OAuthFlow{State: "state", Nonce: "nonce-abc", CodeVerifier: "verifier-0123456789-0123456789-0123456789"}
+
+func TestOIDCExchange(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		cfg      OIDCConfig
+		claims   func(c jwt.MapClaims)
+		userinfo map[string]interface{}
+		rawToken func(m *mockOIDCProvider, c jwt.MapClaims) string
+		want     *domain.OAuthUserInfo
+		wantErr  string
+	}{
+		{
+			name: "valid ID token",
+			want: &domain.OAuthUserInfo{Subject: "user-123", Email: "jane@corp.example.com", Username: "jane", EmailVerified: true},
+		},
+		{
+			name:   "nested username claim",
+			cfg:    OIDCConfig{UsernameClaim: "ext.login"},
+			claims: func(c jwt.MapClaims) { c["ext"] = map[string]interface{}{"login": "jdoe"} },
+			want:   &domain.OAuthUserInfo{Subject: "user-123", Email: "jane@corp.example.com", Username: "jdoe", EmailVerified: true},
+		},
+		{
+			name:   "username falls back to the email's local part",
+			claims: func(c jwt.MapClaims) { delete(c, "preferred_username") },
+			userinfo: map[string]interface{}{
+				"sub": "user-123",
+			},
+			want: &domain.OAuthUserInfo{Subject: "user-123", Email: "jane@corp.example.com", Username: "jane", EmailVerified: true},
+		},
+		{
+			name: "email from userinfo",
+			claims: func(c jwt.MapClaims) {
+				delete(c, "email")
+				delete(c, "email_verified")
+			},
+			userinfo: map[string]interface{}{
+				"sub":            "user-123",
+				"email":          "jane@corp.example.com",
+				"email_verified": true,
+			},
+			want: &domain.OAuthUserInfo{Subject: "user-123", Email: "jane@corp.example.com", Username: "jane", EmailVerified: true},
+		},
+		{
+			name:     "userinfo for another subject",
+			claims:   func(c jwt.MapClaims) { delete(c, "email") },
+			userinfo: map[string]interface{}{"sub": "someone-else", "email": "evil@example.com", "email_verified": true},
+			wantErr:  "subject does not match",
+		},
+		{
+			name:    "unverified email",
+			claims:  func(c jwt.MapClaims) { c["email_verified"] = false },
+			wantErr: "not verified",
+		},
+		{
+			name:    "missing email_verified",
+			claims:  func(c jwt.MapClaims) { delete(c, "email_verified") },
+			wantErr: "not verified",
+		},
+		{
+			name:    "email_verified as a string",
+			claims:  func(c jwt.MapClaims) { c["email_verified"] = "true" },
+			wantErr: "not verified",
+		},
+		{
+			name:   "missing email_verified with a trusted provider",
+			cfg:    OIDCConfig{TrustEmail: true},
+			claims: func(c jwt.MapClaims) { delete(c, "email_verified") },
+			want:   &domain.OAuthUserInfo{Subject: "user-123", Email: "jane@corp.example.com", Username: "jane", EmailVerified: true},
+		},
+		{
+			name:     "no email at all",
+			claims:   func(c jwt.MapClaims) { delete(c, "email") },
+			userinfo: map[string]interface{}{"sub": "user-123"},
+			wantErr:  "did not provide an email",
+		},
+		{
+			name:    "wrong nonce",
+			claims:  func(c jwt.MapClaims) { c["nonce"] = "replayed" },
+			wantErr: "nonce",
+		},
+		{
+			name:    "wrong audience",
+			claims:  func(c jwt.MapClaims) { c["aud"] = "another-client" },
+			wantErr: "invalid ID token",
+		},
+		{
+			name:    "several audiences without azp",
+			claims:  func(c jwt.MapClaims) { c["aud"] = []string{testClientID, "another-client"} },
+			wantErr: "authorized party",
+		},
+		{
+			name:    "wrong issuer",
+			claims:  func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+			wantErr: "invalid ID token",
+		},
+		{
+			name:    "expired",
+			claims:  func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+			wantErr: "invalid ID token",
+		},
+		{
+			name:    "missing subject",
+			claims:  func(c jwt.MapClaims) { delete(c, "sub") },
+			wantErr: "missing subject",
+		},
+		{
+			name:     "signed with an unknown key",
+			rawToken: func(m *mockOIDCProvider, c jwt.MapClaims) string { return m.sign(c, otherKey) },
+			wantErr:  "invalid ID token",
+		},
+		{
+			name: "unsigned token",
+			rawToken: func(_ *mockOIDCProvider, c jwt.MapClaims) string {
+				s, _ := jwt.NewWithClaims(jwt.SigningMethodNone, c).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				return s
+			},
+			wantErr: "invalid ID token",
+		},
+		{
+			name:     "no ID token",
+			rawToken: func(*mockOIDCProvider, jwt.MapClaims) string { return "" },
+			wantErr:  "did not return an ID token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockOIDCProvider(t)
+			m.userinfo = tt.userinfo
+			claims := m.claims(testFlow.Nonce)
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			if tt.rawToken != nil {
+				m.idToken = tt.rawToken(m, claims)
+			} else {
+				m.idToken = m.sign(claims, m.key)
+			}
+
+			got, err := m.provider(t, tt.cfg).exchange(context.Background(), "good-code", testFlow)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("exchange() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("exchange() error = %v", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("exchange() = %+v, want %+v", got, tt.want)
+			}
+			if m.gotVerifier != testFlow.CodeVerifier {
+				t.Errorf("token request sent code_verifier %q, want %q", m.gotVerifier, testFlow.CodeVerifier)
+			}
+		})
+	}
+}
+
+func TestOIDCSkipsUserinfoWhenClaimsArePresent(t *testing.T) {
+	m := newMockOIDCProvider(t)
+	m.idToken = m.sign(m.claims(testFlow.Nonce), m.key)
+	if _, err := m.provider(t, OIDCConfig{}).exchange(context.Background(), "good-code", testFlow); err != nil {
+		t.Fatal(err)
+	}
+	if m.gotUserinfos != 0 {
+		t.Errorf("userinfo was called %d times, want 0", m.gotUserinfos)
+	}
+}
+
+func TestOIDCRejectsBadCode(t *testing.T) {
+	m := newMockOIDCProvider(t)
+	if _, err := m.provider(t, OIDCConfig{}).exchange(context.Background(), "bad-code", testFlow); err == nil {
+		t.Error("exchange() accepted a code the provider refused")
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockOIDCProvider(t)
+	m.issuer = "https://evil.example.com"
+	_, err := m.provider(t, OIDCConfig{}).authURL(testFlow)
+	if err == nil || !strings.Contains(err.Error(), "issuer") {
+		t.Errorf("authURL() error = %v, want an issuer mismatch", err)
+	}
+}
+
+func TestOIDCAuthURL(t *testing.T) {
+	m := newMockOIDCProvider(t)
+	raw, err := m.provider(t, OIDCConfig{}).authURL(testFlow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(raw, m.server.URL+"/authorize?") {
+		t.Errorf("authURL() = %q, want the discovered authorization endpoint", raw)
+	}
+
+	sum := sha256.Sum256([]byte(testFlow.CodeVerifier))
+	want := map[string]string{
+		"client_id":             testClientID,
+		"response_type":         "code",
+		"state":                 testFlow.State,
+		"nonce":                 testFlow.Nonce,
+		"scope":                 "openid email profile",
+		"code_challenge_method": "S256",
+		"code_challenge":        base64.RawURLEncoding.EncodeToString(sum[:]),
+	}
+	query := u.Query()
+	for name, value := range want {
+		if got := query.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package oauth
+
+import (
+	"Blog-API/internal/domain"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var errInvalidState = errors.New("invalid oauth state")
+
+type stateService struct {
+	secret           []byte
+	ttl              time.Duration
+	allowedRedirects []*url.URL
+	defaultRedirect  string
+}
+
+// allowedRedirects are URLs the browser may be sent back to after logging in;
+// a return-to URL must have the same scheme and host and sit under one of
+// their paths. defaultRedirect is used when the login doesn't ask for one.
+func NewStateService(secret string, ttl time.Duration, allowedRedirects []string, defaultRedirect string) (domain.OAuthStateService, error) {
+	if secret == "" {
+		return nil, errors.New("state secret is required")
+	}
+	s := &stateService{secret: []byte(secret), ttl: ttl, defaultRedirect: defaultRedirect}
+	for _, raw := range allowedRedirects {
+		u, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid allowed redirect %q", raw)
+		}
+		s.allowedRedirects = append(s.allowedRedirects, u)
+	}
+	if defaultRedirect != "" && !s.allowed(defaultRedirect) {
+		return nil, fmt.Errorf("default redirect %q is not in the allowed redirects", defaultRedirect)
+	}
+	return s, nil
+}
+
+func (s *stateService) Issue(provider, returnTo, linkUserID string) (*domain.OAuthState, *domain.OAuthFlow, error) {
+	if returnTo == "" {
+		returnTo = s.defaultRedirect
+	} else if !s.allowed(returnTo) {
+		return nil, nil, errors.New("return_to is not an allowed redirect URL")
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	state := &domain.OAuthState{
+		Provider:   provider,
+		Nonce:      base64.RawURLEncoding.EncodeToString(nonce),
+		ReturnTo:   returnTo,
+		LinkUserID: linkUserID,
+		ExpiresAt:  time.Now().Add(s.ttl).Unix(),
+	}
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return nil, nil, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return state, s.flow(encoded+"."+s.sign(encoded), state), nil
+}
+
+func (s *stateService) Verify(value string) (*domain.OAuthState, *domain.OAuthFlow, error) {
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(encoded))) {
+		return nil, nil, errInvalidState
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, errInvalidState
+	}
+	var state domain.OAuthState
+	if err := json.Unmarshal(payload, &state); err != nil || state.Nonce == "" {
+		return nil, nil, errInvalidState
+	}
+	if time.Now().Unix() > state.ExpiresAt {
+		return nil, nil, errors.New("oauth login took too long, please try again")
+	}
+	return &state, s.flow(value, &state), nil
+}
+
+// the PKCE verifier is derived from the nonce so it never has to leave the server
+func (s *stateService) flow(value string, state *domain.OAuthState) *domain.OAuthFlow {
+	return &domain.OAuthFlow{
+		State:        value,
+		Nonce:        state.Nonce,
+		CodeVerifier: s.sign("pkce:" + state.Nonce),
+	}
+}
+
+func (s *stateService) sign(data string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *stateService) allowed(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Fragment != "" || u.User != nil || strings.Contains(u.Path, "..") {
+		return false
+	}
+	for _, a := range s.allowedRedirects {
+		if u.Scheme != a.Scheme || u.Host != a.Host {
+			continue
+		}
+		prefix := strings.TrimSuffix(a.Path, "/")
+		if u.Path == a.Path || prefix == "" || u.Path == prefix || strings.HasPrefix(u.Path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package oauth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"Blog-API/internal/domain"
+)
+
+func newTestStateService(t *testing.T, ttl time.Duration) *stateService {
+	t.Helper()
+	svc, err := NewStateService("test-secret", ttl, []string{"https://app.example.com/auth", "http://localhost:3000"}, "https://app.example.com/auth/done")
+	if err != nil {
+		t.Fatalf("NewStateService() error = %v", err)
+	}
+	return svc.(*stateService)
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	svc := newTestStateService(t, 10*time.Minute)
+
+	issued, flow, err := svc.Issue("google", "", "64b000000000000000000001")
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if issued.ReturnTo != "https://app.example.com/auth/done" {
+		t.Errorf("ReturnTo = %q, want the default redirect", issued.ReturnTo)
+	}
+
+	verified, verifiedFlow, err := svc.Verify(flow.State)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if *verified != *issued {
+		t.Errorf("Verify() = %+v, want %+v", verified, issued)
+	}
+	if *verifiedFlow != *flow {
+		t.Errorf("Verify() flow = %+v, want %+v", verifiedFlow, flow)
+	}
+	if flow.CodeVerifier == "" || flow.CodeVerifier == flow.Nonce {
+		t.Errorf("CodeVerifier %q should be derived from, not equal to, the nonce", flow.CodeVerifier)
+	}
+}
+
+func TestStateVerifyRejectsTampering(t *testing.T) {
+	svc := newTestStateService(t, 10*time.Minute)
+	_, flow, err := svc.Issue("google", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, signature, _ := strings.Cut(flow.State, ".")
+
+	// same payload with a different provider, signed with the original signature
+	payload, _ := base64.RawURLEncoding.DecodeString(encoded)
+	var state domain.OAuthState
+	json.Unmarshal(payload, &state)
+	state.Provider = "github"
+	forgedPayload, _ := json.Marshal(state)
+	forged := base64.RawURLEncoding.EncodeToString(forgedPayload) + "." + signature
+
+	other, err := NewStateService("another-secret", 10*time.Minute, []string{"https://app.example.com/auth"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherFlow, _ := other.Issue("google", "", "")
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"no signature", encoded},
+		{"wrong signature", encoded + ".AAAA"},
+		{"modified payload", forged},
+		{"signed with another secret", otherFlow.State},
+		{"signature only", "." + signature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := svc.Verify(tt.value); err == nil {
+				t.Error("Verify() accepted a tampered state")
+			}
+		})
+	}
+}
+
+func TestStateExpires(t *testing.T) {
+	svc := newTestStateService(t, -time.Second)
+	_, flow, err := svc.Issue("google", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := svc.Verify(flow.State); err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Errorf("Verify() error = %v, want an expiry error", err)
+	}
+}
+
+func TestStateReturnTo(t *testing.T) {
+	svc := newTestStateService(t, 10*time.Minute)
+
+	tests := []struct {
+		returnTo string
+		want     bool
+	}{
+		{"https://app.example.com/auth", true},
+		{"https://app.example.com/auth/callback?x=1", true},
+		{"http://localhost:3000/anything", true},
+		{"https://app.example.com/authx", false},
+		{"https://app.example.com/other", false},
+		{"http://app.example.com/auth", false},
+		{"https://evil.example.com/auth", false},
+		{"https://app.example.com.evil.com/auth", false},
+		{"https://user@app.example.com/auth", false},
+		{"https://app.example.com/auth/../admin", false},
+		{"https://app.example.com/auth#fragment", false},
+		{"//evil.example.com/auth", false},
+	}
+	for _, tt := range tests {
+		_, _, err := svc.Issue("google", tt.returnTo, "")
+		if got := err == nil; got != tt.want {
+			t.Errorf("Issue(return_to=%q) allowed = %v, want %v (err %v)", tt.returnTo, got, tt.want, err)
+		}
+	}
+}
+
+func TestNewStateServiceValidatesConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		secret          string
+		allowed         []string
+		defaultRedirect string
+	}{
+		{"missing secret", "", nil, ""},
+		{"relative allowed redirect", "s", []string{"/auth"}, ""},
+		{"default outside the allowed list", "s", []string{"https://app.example.com/auth"}, "https://other.example.com/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewStateService(tt.secret, time.Minute, tt.allowed, tt.defaultRedirect); err == nil {
+				t.Error("NewStateService() accepted an invalid configuration")
+			}
+		})
+	}
+}
@@ -2,41 +2,126 @@ package password
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// argon2id has no length limit of its own; this only guards against absurd request bodies
+const MaxPasswordLength = 1024
+
 const (
-	MinPasswordLength = 6
-	MaxPasswordLength = 72
+	argon2idPrefix = "$argon2id$"
+	saltLength     = 16
+	keyLength      = 32
 )
 
-type PasswordService struct{}
+// argon2id cost parameters; changing them rehashes passwords as users log in
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// what ValidatePassword requires of new passwords
+type Policy struct {
+	MinLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireNumber  bool
+	RequireSpecial bool
+	MinScore       int // 0-4, see Score
+	HistorySize    int // previous passwords that can't be reused
+}
 
-func NewPasswordService() *PasswordService {
-	return &PasswordService{}
+type PasswordService struct {
+	params Argon2Params
+	policy Policy
 }
 
+func NewPasswordService(params Argon2Params, policy Policy) *PasswordService {
+	return &PasswordService{params: params, policy: policy}
+}
+
+// hashes with argon2id, encoded in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
 func (p *PasswordService) HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.params.Iterations, p.params.Memory, p.params.Parallelism, keyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, p.params.Memory, p.params.Iterations, p.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
 }
 
+// verifies argon2id hashes and the bcrypt hashes stored before them
 func (p *PasswordService) CheckPassword(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	if !strings.HasPrefix(hash, argon2idPrefix) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		return err == nil
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false
+	}
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(candidate, key) == 1
+}
+
+// reports whether the hash uses bcrypt or outdated argon2id parameters
+func (p *PasswordService) NeedsRehash(hash string) bool {
+	if !strings.HasPrefix(hash, argon2idPrefix) {
+		return true
+	}
+	params, _, _, err := decodeArgon2id(hash)
+	return err != nil || params != p.params
+}
+
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, errors.New("invalid argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, err
+	}
+	return params, salt, key, nil
 }
 
 func (p *PasswordService) ValidatePassword(password string) error {
-	if len(password) < MinPasswordLength {
-		return errors.New("password must be at least 6 characters long")
+	if utf8.RuneCountInString(password) < p.policy.MinLength {
+		return fmt.Errorf("password must be at least %d characters long", p.policy.MinLength)
 	}
 
 	if len(password) > MaxPasswordLength {
-		return errors.New("password must be less than 72 characters")
+		return fmt.Errorf("password must be less than %d characters", MaxPasswordLength)
 	}
 
 	var (
@@ -59,25 +144,38 @@ func (p *PasswordService) ValidatePassword(password string) error {
 		}
 	}
 
-	if !hasUpper {
+	if p.policy.RequireUpper && !hasUpper {
 		return errors.New("password must contain at least one uppercase letter")
 	}
 
-	if !hasLower {
+	if p.policy.RequireLower && !hasLower {
 		return errors.New("password must contain at least one lowercase letter")
 	}
 
-	if !hasNumber {
+	if p.policy.RequireNumber && !hasNumber {
 		return errors.New("password must contain at least one number")
 	}
 
-	if !hasSpecial {
+	if p.policy.RequireSpecial && !hasSpecial {
 		return errors.New("password must contain at least one special character")
 	}
 
+	if IsCommon(password) {
+		return errors.New("password is too common, please choose a less predictable one")
+	}
+
+	if Score(password) < p.policy.MinScore {
+		return errors.New("password is too easy to guess, try a longer one or mix in more kinds of characters")
+	}
+
 	return nil
 }
 
+// how many previous password hashes are kept to stop reuse
+func (p *PasswordService) HistorySize() int {
+	return p.policy.HistorySize
+}
+
 func (p *PasswordService) GenerateSecureToken(length int) string {
 	bytes := make([]byte, length/2)
 	rand.Read(bytes)
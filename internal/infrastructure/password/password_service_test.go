@@ -0,0 +1,121 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap parameters so the tests stay fast
+var testParams = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func TestValidatePassword(t *testing.T) {
+	policy := Policy{
+		MinLength:      10,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireNumber:  true,
+		RequireSpecial: true,
+		MinScore:       3,
+	}
+	svc := NewPasswordService(testParams, policy)
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  string
+	}{
+		{"valid", "Kqzm7vtp#w", ""},
+		{"too short", "Kq7#w", "at least 10 characters"},
+		{"too long", "Kq7#" + strings.Repeat("x", MaxPasswordLength), "less than"},
+		{"no uppercase", "kqzm7vtp#w", "uppercase"},
+		{"no lowercase", "KQZM7VTP#W", "lowercase"},
+		{"no number", "Kqzmxvtp#w", "number"},
+		{"no special", "Kqzm7vtpxw", "special"},
+		{"common", "Password123!", "too common"},
+		{"weak", "Abcdefgh1!", "too easy to guess"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.ValidatePassword(tt.password)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidatePassword() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidatePassword() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePasswordCountsRunes(t *testing.T) {
+	svc := NewPasswordService(testParams, Policy{MinLength: 8})
+	// eight characters, sixteen bytes
+	if err := svc.ValidatePassword("ñøßþçðæé"); err != nil {
+		t.Errorf("ValidatePassword() error = %v", err)
+	}
+	if err := svc.ValidatePassword("ñøßþçðæ"); err == nil {
+		t.Error("ValidatePassword() accepted seven characters")
+	}
+}
+
+func TestHashAndCheckPassword(t *testing.T) {
+	svc := NewPasswordService(testParams, Policy{})
+	hash, err := svc.HashPassword("Kqzm7vtp#w")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected hash format %q", hash)
+	}
+	if !svc.CheckPassword("Kqzm7vtp#w", hash) {
+		t.Error("CheckPassword() rejected the right password")
+	}
+	if svc.CheckPassword("Kqzm7vtp#W", hash) {
+		t.Error("CheckPassword() accepted the wrong password")
+	}
+	if other, _ := svc.HashPassword("Kqzm7vtp#w"); other == hash {
+		t.Error("HashPassword() reused a salt")
+	}
+}
+
+func TestCheckPasswordAcceptsBcrypt(t *testing.T) {
+	svc := NewPasswordService(testParams, Policy{})
+	legacy, err := bcrypt.GenerateFromPassword([]byte("Kqzm7vtp#w"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !svc.CheckPassword("Kqzm7vtp#w", string(legacy)) {
+		t.Error("CheckPassword() rejected a bcrypt hash")
+	}
+	if svc.CheckPassword("wrong", string(legacy)) {
+		t.Error("CheckPassword() accepted the wrong password for a bcrypt hash")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	svc := NewPasswordService(testParams, Policy{})
+	current, _ := svc.HashPassword("Kqzm7vtp#w")
+	older, _ := NewPasswordService(Argon2Params{Memory: 512, Iterations: 1, Parallelism: 1}, Policy{}).HashPassword("Kqzm7vtp#w")
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("Kqzm7vtp#w"), bcrypt.MinCost)
+
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{"current parameters", current, false},
+		{"older parameters", older, true},
+		{"bcrypt", string(legacy), true},
+		{"malformed", "$argon2id$v=19$garbage", true},
+	}
+	for _, tt := range tests {
+		if got := svc.NeedsRehash(tt.hash); got != tt.want {
+			t.Errorf("%s: NeedsRehash() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
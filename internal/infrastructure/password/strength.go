@@ -0,0 +1,116 @@
+package password
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// common_passwords.txt lists frequently used passwords, one per line, lowercase
+//
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = func() map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, line := range strings.Split(commonPasswordsFile, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			set[line] = struct{}{}
+		}
+	}
+	return set
+}()
+
+// undoes the usual character substitutions, so "p@ssw0rd" reads as "password"
+var unleet = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i")
+
+// IsCommon reports whether the password is, or is built on, a commonly used
+// password: "Password123!" is as guessable as "password".
+func IsCommon(password string) bool {
+	lower := strings.ToLower(password)
+	if _, ok := commonPasswords[lower]; ok {
+		return true
+	}
+	core := strings.TrimFunc(lower, func(r rune) bool {
+		return unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+	if core == "" {
+		return false
+	}
+	if _, ok := commonPasswords[core]; ok {
+		return true
+	}
+	_, ok := commonPasswords[unleet.Replace(core)]
+	return ok
+}
+
+// Score estimates how hard the password is to guess, from 0 (trivial) to 4
+// (very strong). It is a rough local estimate: entropy from the character
+// classes used, discounted for repeats and runs like "aaa" or "1234", and 0
+// for anything on the common-password list.
+func Score(password string) int {
+	if IsCommon(password) {
+		return 0
+	}
+	bits := entropyBits(password)
+	switch {
+	case bits < 28:
+		return 0
+	case bits < 36:
+		return 1
+	case bits < 60:
+		return 2
+	case bits < 80:
+		return 3
+	}
+	return 4
+}
+
+func entropyBits(password string) float64 {
+	var hasLower, hasUpper, hasDigit, hasSymbol, hasOther bool
+	var length float64
+	var prev rune = -1
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			hasLower = true
+		case r >= 'A' && r <= 'Z':
+			hasUpper = true
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case r < unicode.MaxASCII && (unicode.IsPunct(r) || unicode.IsSymbol(r) || r == ' '):
+			hasSymbol = true
+		default:
+			hasOther = true
+		}
+		// repeated characters and ascending/descending runs add little
+		if r == prev || r == prev+1 || r == prev-1 {
+			length += 0.25
+		} else {
+			length++
+		}
+		prev = r
+	}
+
+	pool := 0
+	if hasLower {
+		pool += 26
+	}
+	if hasUpper {
+		pool += 26
+	}
+	if hasDigit {
+		pool += 10
+	}
+	if hasSymbol {
+		pool += 33
+	}
+	if hasOther {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+	return length * math.Log2(float64(pool))
+}
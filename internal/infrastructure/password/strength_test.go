@@ -0,0 +1,52 @@
+package password
+
+import "testing"
+
+func TestIsCommon(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"PASSWORD", true},
+		{"Password123!", true},
+		{"p@ssw0rd", true},
+		{"!!dragon99", true},
+		{"123456", true},
+		{"correct horse battery staple", false},
+		{"Vq7#mZ2!xR", false},
+		{"1234!!!!", false},
+	}
+	for _, tt := range tests {
+		if got := IsCommon(tt.password); got != tt.want {
+			t.Errorf("IsCommon(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{"", 0},
+		{"password", 0},
+		{"aaaaaaaaaaaa", 0},
+		{"abcdefghijkl", 0},
+		{"kqzmvtp", 1},
+		{"kqzmvtpwrx", 2},
+		{"Kqzm7vtp#w", 3},
+		{"Kqzm7vtp#wXr2!Lf", 4},
+	}
+	for _, tt := range tests {
+		if got := Score(tt.password); got != tt.want {
+			t.Errorf("Score(%q) = %d, want %d", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestScoreDiscountsRuns(t *testing.T) {
+	if run, mixed := Score("abcdefgh12345678"), Score("kqzmvtpw83619475"); run >= mixed {
+		t.Errorf("a sequential run scored %d, not below a random string's %d", run, mixed)
+	}
+}
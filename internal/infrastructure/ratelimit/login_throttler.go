@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"Blog-API/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// failed attempts an account gets before each further one is delayed
+	freeFailures = 3
+	maxDelay     = time.Minute
+)
+
+// RedisLoginThrottler tracks failed logins per account and per client IP
+type RedisLoginThrottler struct {
+	client          *redis.Client
+	maxFailures     int
+	ipMaxFailures   int
+	failureWindow   time.Duration
+	lockoutDuration time.Duration
+}
+
+func NewRedisLoginThrottler(client *redis.Client, maxFailures, ipMaxFailures int, failureWindow, lockoutDuration time.Duration) domain.LoginThrottler {
+	return &RedisLoginThrottler{
+		client:          client,
+		maxFailures:     maxFailures,
+		ipMaxFailures:   ipMaxFailures,
+		failureWindow:   failureWindow,
+		lockoutDuration: lockoutDuration,
+	}
+}
+
+func accountKey(email string) string { return "login:failures:account:" + strings.ToLower(email) }
+func ipKey(ip string) string         { return "login:failures:ip:" + ip }
+func lockKey(email string) string    { return "login:locked:" + strings.ToLower(email) }
+func delayKey(email string) string   { return "login:delay:" + strings.ToLower(email) }
+
+func (t *RedisLoginThrottler) Check(ctx context.Context, email, ip string) (time.Duration, bool, error) {
+	pipe := t.client.Pipeline()
+	lockTTL := pipe.PTTL(ctx, lockKey(email))
+	delayTTL := pipe.PTTL(ctx, delayKey(email))
+	ipFailures := pipe.Get(ctx, ipKey(ip))
+	ipTTL := pipe.PTTL(ctx, ipKey(ip))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, false, err
+	}
+
+	if lockTTL.Val() > 0 {
+		return lockTTL.Val(), true, nil
+	}
+	if n, _ := ipFailures.Int(); n >= t.ipMaxFailures && ipTTL.Val() > 0 {
+		return ipTTL.Val(), false, nil
+	}
+	if delayTTL.Val() > 0 {
+		return delayTTL.Val(), false, nil
+	}
+	return 0, false, nil
+}
+
+func (t *RedisLoginThrottler) RecordFailure(ctx context.Context, email, ip string) (bool, error) {
+	pipe := t.client.TxPipeline()
+	pipe.SetNX(ctx, accountKey(email), 0, t.failureWindow)
+	accountFailures := pipe.Incr(ctx, accountKey(email))
+	pipe.SetNX(ctx, ipKey(ip), 0, t.failureWindow)
+	pipe.Incr(ctx, ipKey(ip))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	n := int(accountFailures.Val())
+	if n >= t.maxFailures {
+		// the counter starts over once the lock runs out
+		pipe := t.client.TxPipeline()
+		pipe.Set(ctx, lockKey(email), 1, t.lockoutDuration)
+		pipe.Del(ctx, accountKey(email), delayKey(email))
+		_, err := pipe.Exec(ctx)
+		return err == nil, err
+	}
+	if n >= freeFailures {
+		// 1s, 2s, 4s, ... capped at maxDelay
+		delay := time.Second << (n - freeFailures)
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+		return false, t.client.Set(ctx, delayKey(email), 1, delay).Err()
+	}
+	return false, nil
+}
+
+func (t *RedisLoginThrottler) Reset(ctx context.Context, email string) error {
+	return t.client.Del(ctx, accountKey(email), delayKey(email)).Err()
+}
+
+func (t *RedisLoginThrottler) Unlock(ctx context.Context, email string) error {
+	return t.client.Del(ctx, lockKey(email), accountKey(email), delayKey(email)).Err()
+}
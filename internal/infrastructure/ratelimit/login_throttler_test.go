@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestThrottler(t *testing.T, maxFailures, ipMaxFailures int) (*RedisLoginThrottler, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	throttler := NewRedisLoginThrottler(client, maxFailures, ipMaxFailures, 15*time.Minute, 30*time.Minute)
+	return throttler.(*RedisLoginThrottler), server
+}
+
+func TestLoginThrottlerDelays(t *testing.T) {
+	ctx := context.Background()
+	throttler, _ := newTestThrottler(t, 10, 100)
+
+	// failures 1-2 are free, then 1s, 2s, 4s, ... capped at a minute
+	tests := []struct {
+		failure   int
+		wantDelay time.Duration
+	}{
+		{1, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{6, 8 * time.Second},
+		{7, 16 * time.Second},
+		{8, 32 * time.Second},
+		{9, time.Minute},
+	}
+	for _, tt := range tests {
+		locked, err := throttler.RecordFailure(ctx, "User@Example.com", "203.0.113.7")
+		if err != nil {
+			t.Fatalf("failure %d: RecordFailure() error = %v", tt.failure, err)
+		}
+		if locked {
+			t.Fatalf("failure %d: account locked early", tt.failure)
+		}
+		wait, locked, err := throttler.Check(ctx, "user@example.com", "203.0.113.7")
+		if err != nil {
+			t.Fatalf("failure %d: Check() error = %v", tt.failure, err)
+		}
+		if locked || wait != tt.wantDelay {
+			t.Errorf("failure %d: Check() = %v, locked %v; want %v, not locked", tt.failure, wait, locked, tt.wantDelay)
+		}
+	}
+}
+
+func TestLoginThrottlerDelayExpires(t *testing.T) {
+	ctx := context.Background()
+	throttler, server := newTestThrottler(t, 10, 100)
+	for i := 0; i < 3; i++ {
+		if _, err := throttler.RecordFailure(ctx, "user@example.com", "203.0.113.7"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if wait, _, _ := throttler.Check(ctx, "user@example.com", "203.0.113.7"); wait == 0 {
+		t.Fatal("expected a delay after the third failure")
+	}
+	server.FastForward(time.Second)
+	if wait, _, _ := throttler.Check(ctx, "user@example.com", "203.0.113.7"); wait != 0 {
+		t.Errorf("Check() = %v after the delay ran out, want 0", wait)
+	}
+}
+
+func TestLoginThrottlerLockout(t *testing.T) {
+	ctx := context.Background()
+	throttler, server := newTestThrottler(t, 5, 100)
+
+	for i := 1; i <= 5; i++ {
+		locked, err := throttler.RecordFailure(ctx, "user@example.com", "203.0.113.7")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked != (i == 5) {
+			t.Fatalf("failure %d: RecordFailure() locked = %v", i, locked)
+		}
+	}
+
+	wait, locked, err := throttler.Check(ctx, "user@example.com", "198.51.100.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !locked || wait != 30*time.Minute {
+		t.Fatalf("Check() = %v, locked %v; want 30m, locked", wait, locked)
+	}
+
+	// the lock runs out and the count starts over
+	server.FastForward(30 * time.Minute)
+	if wait, locked, _ := throttler.Check(ctx, "user@example.com", "198.51.100.1"); locked || wait != 0 {
+		t.Fatalf("Check() = %v, locked %v after the lockout; want 0, not locked", wait, locked)
+	}
+	if locked, _ := throttler.RecordFailure(ctx, "user@example.com", "198.51.100.1"); locked {
+		t.Error("the first failure after a lockout locked the account again")
+	}
+}
+
+func TestLoginThrottlerIPLimit(t *testing.T) {
+	ctx := context.Background()
+	throttler, _ := newTestThrottler(t, 100, 4)
+
+	// spread over accounts so no single account is delayed
+	for i := 0; i < 4; i++ {
+		if _, err := throttler.RecordFailure(ctx, fmt.Sprintf("user%d@example.com", i), "203.0.113.7"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wait, locked, err := throttler.Check(ctx, "someone-else@example.com", "203.0.113.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked || wait <= 0 || wait > 15*time.Minute {
+		t.Errorf("Check() from the noisy IP = %v, locked %v; want a wait up to the window, not locked", wait, locked)
+	}
+	if wait, _, _ := throttler.Check(ctx, "someone-else@example.com", "198.51.100.1"); wait != 0 {
+		t.Errorf("Check() from another IP = %v, want 0", wait)
+	}
+}
+
+func TestLoginThrottlerResetAndUnlock(t *testing.T) {
+	ctx := context.Background()
+	throttler, _ := newTestThrottler(t, 5, 100)
+
+	for i := 0; i < 4; i++ {
+		throttler.RecordFailure(ctx, "user@example.com", "203.0.113.7")
+	}
+	if err := throttler.Reset(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _, _ := throttler.Check(ctx, "user@example.com", "203.0.113.7"); wait != 0 {
+		t.Errorf("Check() = %v after Reset, want 0", wait)
+	}
+	// the count started over, so this is failure one of five again
+	if locked, _ := throttler.RecordFailure(ctx, "user@example.com", "203.0.113.7"); locked {
+		t.Error("RecordFailure() locked the account right after Reset")
+	}
+
+	for i := 0; i < 5; i++ {
+		throttler.RecordFailure(ctx, "user@example.com", "203.0.113.7")
+	}
+	if _, locked, _ := throttler.Check(ctx, "user@example.com", "203.0.113.7"); !locked {
+		t.Fatal("expected the account to be locked")
+	}
+	if err := throttler.Unlock(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, locked, _ := throttler.Check(ctx, "user@example.com", "203.0.113.7"); locked || wait != 0 {
+		t.Errorf("Check() = %v, locked %v after Unlock; want 0, not locked", wait, locked)
+	}
+}
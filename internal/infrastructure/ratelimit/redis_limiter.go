@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"Blog-API/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "ratelimit:"
+
+// RedisRateLimiter counts hits in fixed windows shared by every API instance
+type RedisRateLimiter struct {
+	client *redis.Client
+}
+
+func NewRedisRateLimiter(client *redis.Client) domain.RateLimiter {
+	return &RedisRateLimiter{client: client}
+}
+
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	key = keyPrefix + key
+	pipe := l.client.TxPipeline()
+	// starts the window on the first hit; the counter and its expiry are created together
+	pipe.SetNX(ctx, key, 0, window)
+	count := pipe.Incr(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, 0, err
+	}
+
+	if count.Val() <= int64(limit) {
+		return true, 0, nil
+	}
+	retryAfter := ttl.Val()
+	if retryAfter < 0 {
+		retryAfter = window
+	}
+	return false, retryAfter, nil
+}
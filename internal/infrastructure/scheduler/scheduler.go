@@ -0,0 +1,270 @@
+package scheduler
+
+import (
+	"Blog-API/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	ScheduledRunJobName = "scheduler.run"
+	runsKey             = "scheduler:runs"
+	lockKeyPrefix       = "scheduler:lock:"
+	minLockTTL          = time.Minute
+)
+
+type entry struct {
+	name     string
+	spec     string
+	schedule cron.Schedule
+	factory  domain.JobFactory
+	next     time.Time
+}
+
+// what is stored in Redis about an entry's most recent run
+type runRecord struct {
+	Status      string    `json:"status"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	StartedAt   time.Time `json:"started_at,omitempty"`
+	Duration    string    `json:"duration,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Instance    string    `json:"instance"`
+}
+
+// Scheduler fires cron entries on every instance, but only the instance that wins
+// the Redis lock for a given fire time submits the run to the worker pool.
+type Scheduler struct {
+	client     *redis.Client
+	workerPool domain.WorkerPool
+	instance   string
+
+	mu      sync.RWMutex
+	entries map[string]*entry
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(client *redis.Client, workerPool domain.WorkerPool, policy domain.RetryPolicy) domain.Scheduler {
+	hostname, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		client:     client,
+		workerPool: workerPool,
+		instance:   fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		entries:    make(map[string]*entry),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	workerPool.Register(ScheduledRunJobName, func() domain.Job {
+		return &ScheduledRun{scheduler: s}
+	}, policy)
+	return s
+}
+
+// registers a job factory under a standard 5-field cron expression (or a descriptor such as @hourly)
+func (s *Scheduler) Register(name, spec string, factory domain.JobFactory) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("invalid cron expression %q for %s: %w", spec, name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.entries[name]; exists {
+		return fmt.Errorf("schedule %s is already registered", name)
+	}
+	s.entries[name] = &entry{
+		name:     name,
+		spec:     spec,
+		schedule: schedule,
+		factory:  factory,
+		next:     schedule.Next(time.Now().UTC()),
+	}
+	return nil
+}
+
+func (s *Scheduler) Start() {
+	s.wg.Add(1)
+	go s.loop()
+	log.Printf("Scheduler started on instance %s", s.instance)
+}
+
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	log.Printf("Scheduler stopped")
+}
+
+func (s *Scheduler) loop() {
+	defer s.wg.Done()
+	for {
+		timer := time.NewTimer(time.Until(s.nextFire()))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.fireDue(time.Now().UTC())
+		}
+	}
+}
+
+func (s *Scheduler) nextFire() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	next := time.Now().Add(time.Minute)
+	for _, e := range s.entries {
+		if e.next.Before(next) {
+			next = e.next
+		}
+	}
+	return next
+}
+
+func (s *Scheduler) fireDue(now time.Time) {
+	s.mu.Lock()
+	var due []*entry
+	fireTimes := make(map[string]time.Time)
+	for _, e := range s.entries {
+		if e.next.After(now) {
+			continue
+		}
+		due = append(due, e)
+		fireTimes[e.name] = e.next
+		e.next = e.schedule.Next(now)
+	}
+	s.mu.Unlock()
+
+	for _, e := range due {
+		s.dispatch(e, fireTimes[e.name])
+	}
+}
+
+// every instance computes the same fire time, so the lock key identifies a single run
+func (s *Scheduler) dispatch(e *entry, fireTime time.Time) {
+	ttl := e.schedule.Next(fireTime).Sub(fireTime)
+	if ttl < minLockTTL {
+		ttl = minLockTTL
+	}
+	lockKey := fmt.Sprintf("%s%s:%d", lockKeyPrefix, e.name, fireTime.Unix())
+	acquired, err := s.client.SetNX(s.ctx, lockKey, s.instance, ttl).Result()
+	if err != nil {
+		log.Printf("Scheduler: failed to acquire lock for %s: %v", e.name, err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	s.record(e.name, &runRecord{Status: domain.ScheduleStatusQueued, ScheduledAt: fireTime, Instance: s.instance})
+	if err := s.workerPool.SubmitContext(s.ctx, &ScheduledRun{Schedule: e.name, ScheduledAt: fireTime, scheduler: s}); err != nil {
+		log.Printf("Scheduler: failed to queue %s: %v", e.name, err)
+		s.record(e.name, &runRecord{Status: domain.ScheduleStatusFailed, ScheduledAt: fireTime, Instance: s.instance, Error: err.Error()})
+	}
+}
+
+func (s *Scheduler) record(name string, rec *runRecord) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	if err := s.client.HSet(context.Background(), runsKey, name, data).Err(); err != nil {
+		log.Printf("Scheduler: failed to record run of %s: %v", name, err)
+	}
+}
+
+func (s *Scheduler) List(ctx context.Context) ([]*domain.Schedule, error) {
+	s.mu.RLock()
+	schedules := make([]*domain.Schedule, 0, len(s.entries))
+	for _, e := range s.entries {
+		schedules = append(schedules, &domain.Schedule{Name: e.name, Spec: e.spec, NextRun: e.next})
+	}
+	s.mu.RUnlock()
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].Name < schedules[j].Name })
+
+	runs, err := s.client.HGetAll(ctx, runsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, schedule := range schedules {
+		raw, ok := runs[schedule.Name]
+		if !ok {
+			continue
+		}
+		var rec runRecord
+		if err := json.Unmarshal([]byte(raw), &rec); err != nil {
+			continue
+		}
+		lastRun := rec.ScheduledAt
+		if !rec.StartedAt.IsZero() {
+			lastRun = rec.StartedAt
+		}
+		schedule.LastRun = &lastRun
+		schedule.LastStatus = rec.Status
+		schedule.LastError = rec.Error
+		schedule.LastDuration = rec.Duration
+		schedule.LastInstance = rec.Instance
+	}
+	return schedules, nil
+}
+
+func (s *Scheduler) lookup(name string) (*entry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.entries[name]
+	return e, ok
+}
+
+// ScheduledRun is the job submitted to the worker pool for each fire of a schedule.
+// It only carries the schedule name so it can be persisted by durable backends.
+type ScheduledRun struct {
+	Schedule    string    `json:"schedule"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	scheduler   *Scheduler
+}
+
+func (r *ScheduledRun) JobName() string {
+	return ScheduledRunJobName
+}
+
+// maintenance work should never hold up user-facing jobs
+func (r *ScheduledRun) Priority() domain.JobPriority {
+	return domain.PriorityLow
+}
+
+func (r *ScheduledRun) Run(ctx context.Context) error {
+	e, ok := r.scheduler.lookup(r.Schedule)
+	if !ok {
+		return errors.New("unknown schedule: " + r.Schedule)
+	}
+
+	rec := &runRecord{
+		Status:      domain.ScheduleStatusRunning,
+		ScheduledAt: r.ScheduledAt,
+		StartedAt:   time.Now().UTC(),
+		Instance:    r.scheduler.instance,
+	}
+	r.scheduler.record(r.Schedule, rec)
+
+	err := e.factory().Run(ctx)
+
+	rec.Duration = time.Since(rec.StartedAt).String()
+	rec.Status = domain.ScheduleStatusSucceeded
+	if err != nil {
+		rec.Status = domain.ScheduleStatusFailed
+		rec.Error = err.Error()
+	}
+	r.scheduler.record(r.Schedule, rec)
+	return err
+}
@@ -0,0 +1,118 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// the SHA-1 test vectors from RFC 6238 appendix B, cut down to six digits
+var rfc6238Secret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestValidateRFC6238Vectors(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	svc := &TOTPService{issuer: "Blog API"}
+	for _, tt := range tests {
+		at := time.Unix(tt.unix, 0)
+		step, ok := svc.Validate(rfc6238Secret, tt.code, at)
+		if !ok {
+			t.Errorf("Validate(%s) at %d = false, want true", tt.code, tt.unix)
+			continue
+		}
+		if want := tt.unix / 30; step != want {
+			t.Errorf("Validate(%s) at %d returned step %d, want %d", tt.code, tt.unix, step, want)
+		}
+	}
+}
+
+func TestValidateClockSkew(t *testing.T) {
+	key := []byte("12345678901234567890")
+	at := time.Unix(1111111111, 0)
+	current := at.Unix() / 30
+
+	tests := []struct {
+		name   string
+		offset int64
+		want   bool
+	}{
+		{"current step", 0, true},
+		{"previous step", -1, true},
+		{"next step", 1, true},
+		{"two steps behind", -2, false},
+		{"two steps ahead", 2, false},
+	}
+
+	svc := &TOTPService{issuer: "Blog API"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := generate(key, current+tt.offset)
+			step, ok := svc.Validate(rfc6238Secret, code, at)
+			if ok != tt.want {
+				t.Fatalf("Validate() = %v, want %v", ok, tt.want)
+			}
+			if ok && step != current+tt.offset {
+				t.Errorf("Validate() returned step %d, want %d", step, current+tt.offset)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsMalformedInput(t *testing.T) {
+	at := time.Unix(59, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"short code", rfc6238Secret, "28708"},
+		{"long code", rfc6238Secret, "2870820"},
+		{"wrong code", rfc6238Secret, "287083"},
+		{"bad secret", "not base32!", "287082"},
+	}
+
+	svc := &TOTPService{issuer: "Blog API"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := svc.Validate(tt.secret, tt.code, at); ok {
+				t.Errorf("Validate(%q, %q) = true, want false", tt.secret, tt.code)
+			}
+		})
+	}
+}
+
+func TestValidateAcceptsLowercaseSecretAndSpaces(t *testing.T) {
+	svc := &TOTPService{issuer: "Blog API"}
+	if _, ok := svc.Validate(strings.ToLower(rfc6238Secret), " 287082 ", time.Unix(59, 0)); !ok {
+		t.Error("Validate() rejected a lowercase secret with a padded code")
+	}
+}
+
+func TestGenerateSecretRoundTrips(t *testing.T) {
+	svc := &TOTPService{issuer: "Blog API"}
+	secret, err := svc.GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Fatalf("secret is %d bytes, want 20", len(key))
+	}
+	now := time.Now()
+	if _, ok := svc.Validate(secret, generate(key, now.Unix()/30), now); !ok {
+		t.Error("Validate() rejected a code for a freshly generated secret")
+	}
+}
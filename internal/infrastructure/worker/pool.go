@@ -3,50 +3,241 @@ package worker
 import (
 	"Blog-API/internal/domain"
 	"context"
+	"fmt"
 	"log"
 	"sync"
+	"time"
 )
 
+// how long in-flight jobs get to react to cancellation once the drain deadline passes
+const cancelGracePeriod = 5 * time.Second
+
 type Pool struct {
-	jobs    chan domain.Job
-	wg      sync.WaitGroup
-	workers int
+	lanes    map[domain.JobPriority]chan *envelope
+	wg       sync.WaitGroup
+	workers  int
+	mu       sync.RWMutex
+	policies map[string]domain.RetryPolicy
+	closed   bool
+	quit     chan struct{}
+	stats    jobStats
+
+	// parent of every job context, cancelled when a drain runs out of time
+	jobCtx     context.Context
+	cancelJobs context.CancelFunc
+}
+
+// a job plus the number of times it has already failed
+type envelope struct {
+	job      domain.Job
+	attempts int
+	queuedAt time.Time
 }
 
 func NewPool(workers int, queueSize int) domain.WorkerPool {
+	lanes := make(map[domain.JobPriority]chan *envelope, len(domain.JobPriorities))
+	for _, priority := range domain.JobPriorities {
+		lanes[priority] = make(chan *envelope, queueSize)
+	}
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
 	return &Pool{
-		jobs:    make(chan domain.Job, queueSize),
-		workers: workers,
+		lanes:      lanes,
+		workers:    workers,
+		policies:   make(map[string]domain.RetryPolicy),
+		quit:       make(chan struct{}),
+		jobCtx:     jobCtx,
+		cancelJobs: cancelJobs,
 	}
 }
+
+// sets the retry policy for a job type; the factory is only needed by durable backends
+func (p *Pool) Register(name string, factory domain.JobFactory, policy domain.RetryPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policies[name] = policy
+}
+
 func (p *Pool) Start() {
 	for i := 0; i < p.workers; i++ {
 		p.wg.Add(1)
 		go func(workerID int) {
 			defer p.wg.Done()
 			log.Printf("Worker %d starting", workerID)
-			for job := range p.jobs {
-				func() {
-					defer func() {
-						if r := recover(); r != nil {
-							log.Printf("Worker %d recovered from panic: %v", workerID, r)
-						}
-					}()
-					if err := job.Run(context.Background()); err != nil {
-						log.Printf("Worker %d failed to run job: %v", workerID, err)
-					}
-				}()
+			for {
+				env, ok := p.next()
+				if !ok {
+					break
+				}
+				p.run(workerID, env)
 			}
 			log.Printf("Worker %d stopping", workerID)
 		}(i)
 	}
 }
+
+// picks the next job, preferring higher lanes; returns false once shut down and drained
+func (p *Pool) next() (*envelope, bool) {
+	for {
+		if p.jobCtx.Err() != nil {
+			return nil, false
+		}
+		for _, priority := range domain.JobPriorities {
+			select {
+			case env := <-p.lanes[priority]:
+				return env, true
+			default:
+			}
+		}
+		select {
+		case env := <-p.lanes[domain.PriorityHigh]:
+			return env, true
+		case env := <-p.lanes[domain.PriorityNormal]:
+			return env, true
+		case env := <-p.lanes[domain.PriorityLow]:
+			return env, true
+		case <-p.quit:
+			if p.depth() == 0 {
+				return nil, false
+			}
+		}
+	}
+}
+
+func (p *Pool) run(workerID int, env *envelope) {
+	p.stats.started(time.Since(env.queuedAt))
+	start := time.Now()
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Worker %d recovered from panic: %v", workerID, r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+		p.stats.finished(time.Since(start), err)
+	}()
+	err = env.job.Run(p.jobCtx)
+	if err == nil {
+		return
+	}
+	env.attempts++
+	log.Printf("Worker %d failed to run job (attempt %d): %v", workerID, env.attempts, err)
+
+	policy, ok := p.policyFor(env.job)
+	if !ok || env.attempts >= policy.MaxAttempts {
+		log.Printf("Worker %d giving up on job %T after %d attempts", workerID, env.job, env.attempts)
+		return
+	}
+	time.AfterFunc(policy.Backoff(env.attempts), func() {
+		if err := p.enqueue(context.Background(), env, false); err != nil {
+			log.Printf("Worker pool dropped retry of job %T: %v", env.job, err)
+		}
+	})
+}
+
+func (p *Pool) policyFor(job domain.Job) (domain.RetryPolicy, bool) {
+	named, ok := job.(domain.NamedJob)
+	if !ok {
+		return domain.RetryPolicy{}, false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	policy, ok := p.policies[named.JobName()]
+	return policy, ok
+}
+
 func (p *Pool) Submit(job domain.Job) {
-	p.jobs <- job
+	if err := p.SubmitContext(context.Background(), job); err != nil {
+		log.Printf("Worker pool rejected job %T: %v", job, err)
+	}
+}
+
+func (p *Pool) TrySubmit(job domain.Job) error {
+	return p.enqueue(context.Background(), &envelope{job: job}, true)
+}
+
+func (p *Pool) SubmitContext(ctx context.Context, job domain.Job) error {
+	return p.enqueue(ctx, &envelope{job: job}, false)
+}
+
+func (p *Pool) enqueue(ctx context.Context, env *envelope, nonBlocking bool) error {
+	env.queuedAt = time.Now()
+	lane := p.lanes[domain.PriorityOf(env.job)]
+	if err := p.tryEnqueue(lane, env); err != domain.ErrQueueFull || nonBlocking {
+		return err
+	}
+	// the lock isn't held while waiting for room, or Shutdown could never take it
+	select {
+	case lane <- env:
+		return nil
+	case <-p.quit:
+		return domain.ErrPoolClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) tryEnqueue(lane chan *envelope, env *envelope) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return domain.ErrPoolClosed
+	}
+	select {
+	case lane <- env:
+		return nil
+	default:
+		return domain.ErrQueueFull
+	}
+}
+
+func (p *Pool) depth() int {
+	total := 0
+	for _, lane := range p.lanes {
+		total += len(lane)
+	}
+	return total
 }
-func (p *Pool) Shutdown() {
+
+// stops accepting jobs and lets the workers drain the queue. If ctx expires first,
+// running jobs have their context cancelled and anything still queued is dropped.
+func (p *Pool) Shutdown(ctx context.Context) error {
 	log.Printf("Worker pool shutting down...")
-	close(p.jobs)
-	p.wg.Wait()
-	log.Printf("Worker pool stopped")
+	p.mu.Lock()
+	p.closed = true
+	close(p.quit)
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Printf("Worker pool stopped")
+		return nil
+	case <-ctx.Done():
+	}
+
+	log.Printf("Worker pool drain deadline exceeded, cancelling in-flight jobs (%d still queued)", p.depth())
+	p.cancelJobs()
+	select {
+	case <-done:
+	case <-time.After(cancelGracePeriod):
+		log.Printf("Worker pool gave up waiting for in-flight jobs")
+	}
+	return ctx.Err()
+}
+
+func (p *Pool) Stats(ctx context.Context) (*domain.PoolStats, error) {
+	stats := &domain.PoolStats{
+		Backend:    "memory",
+		Workers:    p.workers,
+		QueueDepth: make(map[string]int64, len(p.lanes)),
+	}
+	for priority, lane := range p.lanes {
+		stats.QueueDepth[priority.String()] = int64(len(lane))
+	}
+	p.stats.fill(stats)
+	return stats, nil
 }
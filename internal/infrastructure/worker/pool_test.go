@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"Blog-API/internal/domain"
+)
+
+type funcJob func(ctx context.Context) error
+
+func (f funcJob) Run(ctx context.Context) error { return f(ctx) }
+
+func TestPoolCountsOutcomes(t *testing.T) {
+	tests := []struct {
+		name          string
+		job           funcJob
+		wantProcessed int64
+		wantFailed    int64
+	}{
+		{"success", func(context.Context) error { return nil }, 1, 0},
+		{"error", func(context.Context) error { return errors.New("boom") }, 0, 1},
+		{"panic", func(context.Context) error { panic("boom") }, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewPool(1, 1)
+			pool.Start()
+			if err := pool.TrySubmit(tt.job); err != nil {
+				t.Fatalf("TrySubmit() error = %v", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := pool.Shutdown(ctx); err != nil {
+				t.Fatalf("Shutdown() error = %v", err)
+			}
+
+			stats, err := pool.Stats(context.Background())
+			if err != nil {
+				t.Fatalf("Stats() error = %v", err)
+			}
+			if stats.Processed != tt.wantProcessed || stats.Failed != tt.wantFailed {
+				t.Errorf("processed/failed = %d/%d, want %d/%d", stats.Processed, stats.Failed, tt.wantProcessed, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestPoolTrySubmitFailsFastWhenFull(t *testing.T) {
+	// not started, so nothing drains the lane
+	pool := NewPool(1, 1)
+	noop := funcJob(func(context.Context) error { return nil })
+	if err := pool.TrySubmit(noop); err != nil {
+		t.Fatalf("first TrySubmit() error = %v", err)
+	}
+	if err := pool.TrySubmit(noop); !errors.Is(err, domain.ErrQueueFull) {
+		t.Errorf("second TrySubmit() error = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestPoolShutdownReleasesBlockedSubmitter(t *testing.T) {
+	// not started, so the second submit waits for room that never comes
+	pool := NewPool(1, 1)
+	noop := funcJob(func(context.Context) error { return nil })
+	if err := pool.TrySubmit(noop); err != nil {
+		t.Fatalf("TrySubmit() error = %v", err)
+	}
+	submitted := make(chan error, 1)
+	go func() { submitted <- pool.SubmitContext(context.Background(), noop) }()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	returned := make(chan struct{})
+	go func() {
+		pool.Shutdown(ctx)
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Shutdown() did not return while a submitter was blocked")
+	}
+
+	select {
+	case err := <-submitted:
+		if !errors.Is(err, domain.ErrPoolClosed) {
+			t.Errorf("SubmitContext() error = %v, want ErrPoolClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("SubmitContext() still blocked after Shutdown()")
+	}
+}
@@ -0,0 +1,617 @@
+package worker
+
+import (
+	"Blog-API/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	streamKey        = "jobs:stream"
+	delayedKey       = "jobs:delayed"
+	deadLetterKey    = "jobs:dead"
+	deadLetterIndex  = "jobs:dead:index"
+	consumerGroup    = "workers"
+	readBlockTimeout = 2 * time.Second
+	promoteInterval  = time.Second
+	promoteBatchSize = 100
+	submitRetryDelay = 100 * time.Millisecond
+)
+
+// the normal lane keeps the original key so jobs queued before lanes existed are still read
+func laneKey(base string, priority domain.JobPriority) string {
+	if priority == domain.PriorityNormal {
+		return base
+	}
+	return base + ":" + priority.String()
+}
+
+// moves every due job from the delayed set back onto the stream in one step
+var promoteDelayedScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, job in ipairs(due) do
+	redis.call('ZREM', KEYS[1], job)
+	redis.call('XADD', KEYS[2], '*', 'job', job)
+end
+return #due
+`)
+
+// the persisted form of a job
+type jobEnvelope struct {
+	ID         string             `json:"id"`
+	Name       string             `json:"name"`
+	Payload    json.RawMessage    `json:"payload"`
+	Priority   domain.JobPriority `json:"priority"`
+	Attempts   int                `json:"attempts"`
+	LastError  string             `json:"last_error,omitempty"`
+	EnqueuedAt time.Time          `json:"enqueued_at"`
+	QueuedAt   time.Time          `json:"queued_at"`
+	FailedAt   time.Time          `json:"failed_at,omitempty"`
+}
+
+type registeredJob struct {
+	factory domain.JobFactory
+	policy  domain.RetryPolicy
+}
+
+// RedisQueue is a durable worker pool backed by one Redis stream per priority lane,
+// all read through the same consumer group. Messages that are read but never
+// acknowledged (e.g. the process crashed) are reclaimed by another worker once the
+// visibility timeout has passed.
+type RedisQueue struct {
+	client            *redis.Client
+	workers           int
+	maxLen            int64
+	consumer          string
+	visibilityTimeout time.Duration
+	stats             jobStats
+	closed            atomic.Bool
+
+	mu       sync.RWMutex
+	registry map[string]registeredJob
+
+	// ctx stops the fetch loops; jobCtx is the parent of running jobs and is only
+	// cancelled when a drain runs out of time
+	ctx        context.Context
+	cancel     context.CancelFunc
+	jobCtx     context.Context
+	cancelJobs context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// maxLen caps the number of messages per lane; 0 means unbounded
+func NewRedisQueue(client *redis.Client, workers int, maxLen int, visibilityTimeout time.Duration) domain.JobQueue {
+	hostname, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	return &RedisQueue{
+		client:            client,
+		workers:           workers,
+		maxLen:            int64(maxLen),
+		consumer:          fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		visibilityTimeout: visibilityTimeout,
+		registry:          make(map[string]registeredJob),
+		ctx:               ctx,
+		cancel:            cancel,
+		jobCtx:            jobCtx,
+		cancelJobs:        cancelJobs,
+	}
+}
+
+func (q *RedisQueue) Register(name string, factory domain.JobFactory, policy domain.RetryPolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.registry[name] = registeredJob{factory: factory, policy: policy}
+}
+
+func (q *RedisQueue) Start() {
+	for _, priority := range domain.JobPriorities {
+		err := q.client.XGroupCreateMkStream(q.ctx, laneKey(streamKey, priority), consumerGroup, "0").Err()
+		if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+			log.Printf("Job queue: failed to create consumer group for %s lane: %v", priority, err)
+		}
+	}
+
+	q.wg.Add(1)
+	go q.promoteDelayed()
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work(i)
+	}
+}
+
+func (q *RedisQueue) Submit(job domain.Job) {
+	if err := q.SubmitContext(context.Background(), job); err != nil {
+		log.Printf("Job queue: failed to enqueue job %T: %v", job, err)
+	}
+}
+
+func (q *RedisQueue) TrySubmit(job domain.Job) error {
+	env, err := q.newEnvelope(job)
+	if err != nil {
+		return err
+	}
+	return q.enqueue(context.Background(), env)
+}
+
+// polls until the lane has room, since Redis has no blocking bounded append
+func (q *RedisQueue) SubmitContext(ctx context.Context, job domain.Job) error {
+	env, err := q.newEnvelope(job)
+	if err != nil {
+		return err
+	}
+	for {
+		err := q.enqueue(ctx, env)
+		if !errors.Is(err, domain.ErrQueueFull) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(submitRetryDelay):
+		}
+	}
+}
+
+func (q *RedisQueue) enqueue(ctx context.Context, env *jobEnvelope) error {
+	if q.closed.Load() {
+		return domain.ErrPoolClosed
+	}
+	key := laneKey(streamKey, env.Priority)
+	if q.maxLen > 0 {
+		length, err := q.client.XLen(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if length >= q.maxLen {
+			return domain.ErrQueueFull
+		}
+	}
+	env.QueuedAt = time.Now()
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return q.client.XAdd(ctx, &redis.XAddArgs{Stream: key, Values: map[string]interface{}{"job": data}}).Err()
+}
+
+func (q *RedisQueue) newEnvelope(job domain.Job) (*jobEnvelope, error) {
+	named, ok := job.(domain.NamedJob)
+	if !ok {
+		return nil, fmt.Errorf("job %T does not implement domain.NamedJob", job)
+	}
+	if _, ok := q.lookup(named.JobName()); !ok {
+		return nil, fmt.Errorf("job type %q is not registered", named.JobName())
+	}
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %w", err)
+	}
+	return &jobEnvelope{
+		ID:         primitive.NewObjectID().Hex(),
+		Name:       named.JobName(),
+		Payload:    payload,
+		Priority:   domain.PriorityOf(job),
+		EnqueuedAt: time.Now(),
+	}, nil
+}
+
+// stops fetching and waits for running jobs. Queued messages stay in Redis; if ctx
+// expires first, running jobs are cancelled and their messages are left unacknowledged
+// so another worker reclaims them after the visibility timeout.
+func (q *RedisQueue) Shutdown(ctx context.Context) error {
+	log.Printf("Job queue shutting down...")
+	q.closed.Store(true)
+	q.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Printf("Job queue stopped")
+		return nil
+	case <-ctx.Done():
+	}
+
+	log.Printf("Job queue drain deadline exceeded, cancelling in-flight jobs")
+	q.cancelJobs()
+	select {
+	case <-done:
+	case <-time.After(cancelGracePeriod):
+		log.Printf("Job queue gave up waiting for in-flight jobs")
+	}
+	return ctx.Err()
+}
+
+func (q *RedisQueue) lookup(name string) (registeredJob, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	reg, ok := q.registry[name]
+	return reg, ok
+}
+
+// worker loop: reclaim stale messages first, then take new ones lane by lane
+func (q *RedisQueue) work(workerID int) {
+	defer q.wg.Done()
+	log.Printf("Job worker %d starting", workerID)
+	defer log.Printf("Job worker %d stopping", workerID)
+
+	lastReclaim := time.Time{}
+	for q.ctx.Err() == nil {
+		if time.Since(lastReclaim) >= q.visibilityTimeout/2 {
+			lastReclaim = time.Now()
+			if q.reclaim(workerID) {
+				continue
+			}
+		}
+
+		streams, err := q.fetch()
+		if err != nil {
+			if err != redis.Nil && q.ctx.Err() == nil {
+				log.Printf("Job worker %d failed to read from stream: %v", workerID, err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				q.handle(workerID, stream.Stream, msg)
+			}
+		}
+	}
+}
+
+// checks each lane without blocking in priority order, then blocks on all of them
+func (q *RedisQueue) fetch() ([]redis.XStream, error) {
+	keys := make([]string, 0, len(domain.JobPriorities))
+	for _, priority := range domain.JobPriorities {
+		key := laneKey(streamKey, priority)
+		keys = append(keys, key)
+		streams, err := q.client.XReadGroup(q.ctx, &redis.XReadGroupArgs{
+			Group:    consumerGroup,
+			Consumer: q.consumer,
+			Streams:  []string{key, ">"},
+			Count:    1,
+			Block:    -1,
+		}).Result()
+		if err == nil && len(streams) > 0 && len(streams[0].Messages) > 0 {
+			return streams, nil
+		}
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+	}
+	for range domain.JobPriorities {
+		keys = append(keys, ">")
+	}
+	return q.client.XReadGroup(q.ctx, &redis.XReadGroupArgs{
+		Group:    consumerGroup,
+		Consumer: q.consumer,
+		Streams:  keys,
+		Count:    1,
+		Block:    readBlockTimeout,
+	}).Result()
+}
+
+// claims one message per lane whose previous consumer exceeded the visibility timeout
+func (q *RedisQueue) reclaim(workerID int) bool {
+	claimed := false
+	for _, priority := range domain.JobPriorities {
+		key := laneKey(streamKey, priority)
+		msgs, _, err := q.client.XAutoClaim(q.ctx, &redis.XAutoClaimArgs{
+			Stream:   key,
+			Group:    consumerGroup,
+			Consumer: q.consumer,
+			MinIdle:  q.visibilityTimeout,
+			Start:    "0-0",
+			Count:    1,
+		}).Result()
+		if err != nil {
+			if q.ctx.Err() == nil {
+				log.Printf("Job worker %d failed to reclaim messages: %v", workerID, err)
+			}
+			continue
+		}
+		for _, msg := range msgs {
+			log.Printf("Job worker %d reclaimed message %s", workerID, msg.ID)
+			q.handle(workerID, key, msg)
+			claimed = true
+		}
+	}
+	return claimed
+}
+
+func (q *RedisQueue) handle(workerID int, stream string, msg redis.XMessage) {
+	raw, _ := msg.Values["job"].(string)
+	var env jobEnvelope
+	if err := json.Unmarshal([]byte(raw), &env); err != nil {
+		log.Printf("Job worker %d dropping malformed message %s: %v", workerID, msg.ID, err)
+		q.ack(stream, msg.ID)
+		return
+	}
+
+	reg, ok := q.lookup(env.Name)
+	if !ok {
+		env.Attempts++
+		env.LastError = fmt.Sprintf("job type %q is not registered", env.Name)
+		q.deadLetter(stream, msg.ID, &env)
+		return
+	}
+
+	if env.QueuedAt.IsZero() {
+		env.QueuedAt = env.EnqueuedAt
+	}
+	q.stats.started(time.Since(env.QueuedAt))
+	start := time.Now()
+	err := q.runJob(reg, &env)
+	q.stats.finished(time.Since(start), err)
+	if err == nil {
+		q.ack(stream, msg.ID)
+		return
+	}
+	if q.jobCtx.Err() != nil {
+		log.Printf("Job worker %d: job %s cancelled by shutdown, leaving it for another worker", workerID, env.ID)
+		return
+	}
+
+	env.Attempts++
+	env.LastError = err.Error()
+	log.Printf("Job worker %d: job %s (%s) failed on attempt %d: %v", workerID, env.ID, env.Name, env.Attempts, err)
+	if env.Attempts >= reg.policy.MaxAttempts {
+		q.deadLetter(stream, msg.ID, &env)
+		return
+	}
+	q.retry(stream, msg.ID, &env, reg.policy.Backoff(env.Attempts))
+}
+
+func (q *RedisQueue) runJob(reg registeredJob, env *jobEnvelope) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	job := reg.factory()
+	if err := json.Unmarshal(env.Payload, job); err != nil {
+		return fmt.Errorf("failed to decode job payload: %w", err)
+	}
+	// a job must finish before its message becomes visible to other workers again
+	ctx, cancel := context.WithTimeout(q.jobCtx, q.visibilityTimeout)
+	defer cancel()
+	return job.Run(ctx)
+}
+
+func (q *RedisQueue) ack(stream, msgID string) {
+	ctx := context.Background()
+	pipe := q.client.TxPipeline()
+	pipe.XAck(ctx, stream, consumerGroup, msgID)
+	pipe.XDel(ctx, stream, msgID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Job queue: failed to acknowledge message %s: %v", msgID, err)
+	}
+}
+
+func (q *RedisQueue) retry(stream, msgID string, env *jobEnvelope, backoff time.Duration) {
+	due := time.Now().Add(backoff)
+	env.QueuedAt = due
+	data, err := json.Marshal(env)
+	if err != nil {
+		log.Printf("Job queue: failed to encode job %s for retry: %v", env.ID, err)
+		return
+	}
+	ctx := context.Background()
+	pipe := q.client.TxPipeline()
+	pipe.ZAdd(ctx, laneKey(delayedKey, env.Priority), redis.Z{Score: float64(due.Unix()), Member: data})
+	pipe.XAck(ctx, stream, consumerGroup, msgID)
+	pipe.XDel(ctx, stream, msgID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Job queue: failed to schedule retry for job %s: %v", env.ID, err)
+	}
+}
+
+func (q *RedisQueue) deadLetter(stream, msgID string, env *jobEnvelope) {
+	env.FailedAt = time.Now()
+	data, err := json.Marshal(env)
+	if err != nil {
+		log.Printf("Job queue: failed to encode job %s for dead-lettering: %v", env.ID, err)
+		return
+	}
+	ctx := context.Background()
+	pipe := q.client.TxPipeline()
+	pipe.HSet(ctx, deadLetterKey, env.ID, data)
+	pipe.ZAdd(ctx, deadLetterIndex, redis.Z{Score: float64(env.FailedAt.Unix()), Member: env.ID})
+	pipe.XAck(ctx, stream, consumerGroup, msgID)
+	pipe.XDel(ctx, stream, msgID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Job queue: failed to dead-letter job %s: %v", env.ID, err)
+		return
+	}
+	log.Printf("Job queue: job %s (%s) moved to dead-letter list after %d attempts: %s", env.ID, env.Name, env.Attempts, env.LastError)
+}
+
+// periodically pushes retries whose backoff has elapsed back onto the stream
+func (q *RedisQueue) promoteDelayed() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(promoteInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-ticker.C:
+			now := fmt.Sprint(time.Now().Unix())
+			for _, priority := range domain.JobPriorities {
+				keys := []string{laneKey(delayedKey, priority), laneKey(streamKey, priority)}
+				if err := promoteDelayedScript.Run(q.ctx, q.client, keys, now, promoteBatchSize).Err(); err != nil && q.ctx.Err() == nil {
+					log.Printf("Job queue: failed to promote delayed %s jobs: %v", priority, err)
+				}
+			}
+		}
+	}
+}
+
+func (q *RedisQueue) Stats(ctx context.Context) (*domain.PoolStats, error) {
+	pipe := q.client.Pipeline()
+	lengths := make(map[domain.JobPriority]*redis.IntCmd, len(domain.JobPriorities))
+	delayed := make([]*redis.IntCmd, 0, len(domain.JobPriorities))
+	for _, priority := range domain.JobPriorities {
+		lengths[priority] = pipe.XLen(ctx, laneKey(streamKey, priority))
+		delayed = append(delayed, pipe.ZCard(ctx, laneKey(delayedKey, priority)))
+	}
+	dead := pipe.ZCard(ctx, deadLetterIndex)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	stats := &domain.PoolStats{
+		Backend:     "redis",
+		Workers:     q.workers,
+		QueueDepth:  make(map[string]int64, len(lengths)),
+		DeadLetters: dead.Val(),
+	}
+	for priority, cmd := range lengths {
+		stats.QueueDepth[priority.String()] = cmd.Val()
+	}
+	for _, cmd := range delayed {
+		stats.Delayed += cmd.Val()
+	}
+	q.stats.fill(stats)
+	return stats, nil
+}
+
+func (q *RedisQueue) ListDeadLetters(ctx context.Context, page, limit int) ([]*domain.DeadLetterJob, int64, error) {
+	total, err := q.client.ZCard(ctx, deadLetterIndex).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	start := int64(page-1) * int64(limit)
+	ids, err := q.client.ZRevRange(ctx, deadLetterIndex, start, start+int64(limit)-1).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	jobs := make([]*domain.DeadLetterJob, 0, len(ids))
+	if len(ids) == 0 {
+		return jobs, total, nil
+	}
+	values, err := q.client.HMGet(ctx, deadLetterKey, ids...).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var env jobEnvelope
+		if err := json.Unmarshal([]byte(raw), &env); err != nil {
+			continue
+		}
+		jobs = append(jobs, toDeadLetter(&env))
+	}
+	return jobs, total, nil
+}
+
+func (q *RedisQueue) GetDeadLetter(ctx context.Context, id string) (*domain.DeadLetterJob, error) {
+	env, err := q.getDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toDeadLetter(env), nil
+}
+
+func (q *RedisQueue) RequeueDeadLetter(ctx context.Context, id string) error {
+	env, err := q.getDeadLetter(ctx, id)
+	if err != nil {
+		return err
+	}
+	env.Attempts = 0
+	env.LastError = ""
+	env.FailedAt = time.Time{}
+	env.EnqueuedAt = time.Now()
+	env.QueuedAt = env.EnqueuedAt
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	pipe := q.client.TxPipeline()
+	pipe.HDel(ctx, deadLetterKey, id)
+	pipe.ZRem(ctx, deadLetterIndex, id)
+	pipe.XAdd(ctx, &redis.XAddArgs{Stream: laneKey(streamKey, env.Priority), Values: map[string]interface{}{"job": data}})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (q *RedisQueue) PurgeDeadLetters(ctx context.Context, before time.Time) (int64, error) {
+	ids, err := q.client.ZRangeByScore(ctx, deadLetterIndex, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(before.Unix(), 10),
+	}).Result()
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	members := make([]interface{}, len(ids))
+	for i, id := range ids {
+		members[i] = id
+	}
+	pipe := q.client.TxPipeline()
+	pipe.HDel(ctx, deadLetterKey, ids...)
+	pipe.ZRem(ctx, deadLetterIndex, members...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+func (q *RedisQueue) DeleteDeadLetter(ctx context.Context, id string) error {
+	removed, err := q.client.HDel(ctx, deadLetterKey, id).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return domain.ErrDeadLetterNotFound
+	}
+	return q.client.ZRem(ctx, deadLetterIndex, id).Err()
+}
+
+func (q *RedisQueue) getDeadLetter(ctx context.Context, id string) (*jobEnvelope, error) {
+	raw, err := q.client.HGet(ctx, deadLetterKey, id).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, domain.ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var env jobEnvelope
+	if err := json.Unmarshal([]byte(raw), &env); err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+func toDeadLetter(env *jobEnvelope) *domain.DeadLetterJob {
+	return &domain.DeadLetterJob{
+		ID:         env.ID,
+		Name:       env.Name,
+		Payload:    env.Payload,
+		Attempts:   env.Attempts,
+		LastError:  env.LastError,
+		EnqueuedAt: env.EnqueuedAt,
+		FailedAt:   env.FailedAt,
+	}
+}
@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"Blog-API/internal/domain"
+	"sync/atomic"
+	"time"
+)
+
+// counters shared by both pool backends; all fields are updated atomically
+type jobStats struct {
+	inFlight  int64
+	processed int64
+	failed    int64
+	waitTotal int64
+	runTotal  int64
+	maxRun    int64
+}
+
+// records a job leaving the queue after waiting for the given duration
+func (s *jobStats) started(wait time.Duration) {
+	atomic.AddInt64(&s.inFlight, 1)
+	atomic.AddInt64(&s.waitTotal, int64(wait))
+}
+
+func (s *jobStats) finished(run time.Duration, err error) {
+	atomic.AddInt64(&s.inFlight, -1)
+	atomic.AddInt64(&s.runTotal, int64(run))
+	if err != nil {
+		atomic.AddInt64(&s.failed, 1)
+	} else {
+		atomic.AddInt64(&s.processed, 1)
+	}
+	for {
+		max := atomic.LoadInt64(&s.maxRun)
+		if int64(run) <= max || atomic.CompareAndSwapInt64(&s.maxRun, max, int64(run)) {
+			return
+		}
+	}
+}
+
+func (s *jobStats) fill(stats *domain.PoolStats) {
+	stats.InFlight = atomic.LoadInt64(&s.inFlight)
+	stats.Processed = atomic.LoadInt64(&s.processed)
+	stats.Failed = atomic.LoadInt64(&s.failed)
+	stats.MaxRunMs = toMillis(atomic.LoadInt64(&s.maxRun))
+	if done := stats.Processed + stats.Failed; done > 0 {
+		stats.AvgRunMs = toMillis(atomic.LoadInt64(&s.runTotal) / done)
+	}
+	if count := dequeued(stats); count > 0 {
+		stats.AvgWaitMs = toMillis(atomic.LoadInt64(&s.waitTotal) / count)
+	}
+}
+
+// jobs that have left the queue, finished or not
+func dequeued(stats *domain.PoolStats) int64 {
+	return stats.Processed + stats.Failed + stats.InFlight
+}
+
+func toMillis(nanos int64) float64 {
+	return float64(nanos) / float64(time.Millisecond)
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Blog-API/internal/domain"
+	"Blog-API/internal/infrastructure/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AccessTokenRepository struct {
+	db         *database.MongoDB
+	collection *mongo.Collection
+}
+
+func NewAccessTokenRepository(db *database.MongoDB) domain.PersonalAccessTokenRepository {
+	collection := db.GetCollection("access_tokens")
+
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			// Mongo deletes tokens once they expire; those without expires_at are kept
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+	if err != nil {
+		// Log error but don't fail - indexes might already exist
+	}
+
+	return &AccessTokenRepository{
+		db:         db,
+		collection: collection,
+	}
+}
+
+func (r *AccessTokenRepository) Create(token *domain.PersonalAccessToken) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	token.CreatedAt = time.Now()
+	result, err := r.collection.InsertOne(ctx, token)
+	if err != nil {
+		return err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		token.ID = oid
+	}
+	return nil
+}
+
+func (r *AccessTokenRepository) GetByHash(tokenHash string) (*domain.PersonalAccessToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var token domain.PersonalAccessToken
+	if err := r.collection.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&token); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("access token not found")
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *AccessTokenRepository) ListByUserID(userID primitive.ObjectID) ([]*domain.PersonalAccessToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tokens := []*domain.PersonalAccessToken{}
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (r *AccessTokenRepository) CountByUserID(userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return r.collection.CountDocuments(ctx, bson.M{"user_id": userID})
+}
+
+func (r *AccessTokenRepository) Delete(id, userID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return errors.New("access token not found")
+	}
+	return nil
+}
+
+func (r *AccessTokenRepository) DeleteByUserID(userID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+func (r *AccessTokenRepository) UpdateLastUsed(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": time.Now()}})
+	return err
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Blog-API/internal/domain"
+	"Blog-API/internal/infrastructure/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// append-only: there is deliberately no way to update or delete entries
+type AuditLogRepository struct {
+	db         *database.MongoDB
+	collection *mongo.Collection
+}
+
+func NewAuditLogRepository(db *database.MongoDB) domain.AuditLogRepository {
+	collection := db.GetCollection("audit_log")
+
+	indexModels := []mongo.IndexModel{
+		{
+			// also stops two writers from extending the chain from the same entry
+			Keys:    bson.D{{Key: "seq", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "seq", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "target_type", Value: 1}, {Key: "target_id", Value: 1}, {Key: "seq", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "action", Value: 1}, {Key: "seq", Value: -1}},
+		},
+	}
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+	if err != nil {
+		// Log error but don't fail - indexes might already exist
+	}
+
+	return &AuditLogRepository{
+		db:         db,
+		collection: collection,
+	}
+}
+
+func (r *AuditLogRepository) Append(entry *domain.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := r.collection.InsertOne(ctx, entry)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.New("audit entry already exists")
+		}
+		return err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		entry.ID = oid
+	}
+	return nil
+}
+
+func (r *AuditLogRepository) Last() (*domain.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var entry domain.AuditEntry
+	opts := options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}})
+	if err := r.collection.FindOne(ctx, bson.M{}, opts).Decode(&entry); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("audit entry not found")
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *AuditLogRepository) List(filter domain.AuditFilter, page, limit int) ([]*domain.AuditEntry, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := bson.M{}
+	if filter.ActorID != nil {
+		query["actor_id"] = *filter.ActorID
+	}
+	if filter.ImpersonatorID != nil {
+		query["impersonator_id"] = *filter.ImpersonatorID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.TargetType != "" {
+		query["target_type"] = filter.TargetType
+	}
+	if filter.TargetID != "" {
+		query["target_id"] = filter.TargetID
+	}
+	if filter.Result != "" {
+		query["result"] = filter.Result
+	}
+	if filter.IPAddress != "" {
+		query["ip_address"] = filter.IPAddress
+	}
+	if filter.From != nil || filter.To != nil {
+		created := bson.M{}
+		if filter.From != nil {
+			created["$gte"] = *filter.From
+		}
+		if filter.To != nil {
+			created["$lte"] = *filter.To
+		}
+		query["created_at"] = created
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "seq", Value: -1}}).
+		SetSkip(int64(page-1) * int64(limit)).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []*domain.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+func (r *AuditLogRepository) ListAfter(seq int64, limit int) ([]*domain.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "seq", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, bson.M{"seq": bson.M{"$gt": seq}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []*domain.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -69,8 +69,7 @@ func (br *BlogRepo) GetAll(page, limit int, sort string) ([]*domain.Blog, int64,
 	defer cancel()
 
 	var blogs []*domain.Blog
-	// CORRECTED: An empty BSON document matches all.
-	filter := bson.D{}
+	filter := visible(bson.M{})
 	opts := options.Find()
 	opts.SetLimit(int64(limit))
 	opts.SetSkip(int64(page-1) * int64(limit))
@@ -141,7 +140,7 @@ func (br *BlogRepo) SearchByTitle(title string, page, limit int) ([]*domain.Blog
 
 	var blogs []*domain.Blog
 	// Note: For best results, a text index should be created on this field in MongoDB.
-	filter := bson.M{"title": bson.M{"$regex": title, "$options": "i"}} // Case-insensitive substring search
+	filter := visible(bson.M{"title": bson.M{"$regex": title, "$options": "i"}}) // Case-insensitive substring search
 
 	// Re-using a helper for paginated queries would be ideal, but for now this is fine.
 	opts := options.Find()
@@ -172,7 +171,7 @@ func (br *BlogRepo) SearchByAuthor(author string, page, limit int) ([]*domain.Bl
 
 	var blogs []*domain.Blog
 	// CORRECTED: The field name must match the schema exactly.
-	filter := bson.M{"author_username": author}
+	filter := visible(bson.M{"author_username": author})
 
 	opts := options.Find()
 	opts.SetLimit(int64(limit))
@@ -203,7 +202,7 @@ func (br *BlogRepo) FilterByTags(tags []string, page, limit int) ([]*domain.Blog
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{"tags": bson.M{"$in": tags}}
+	filter := visible(bson.M{"tags": bson.M{"$in": tags}})
 	opts := options.Find()
 	opts.SetSkip(int64(page-1) * int64(limit))
 	opts.SetLimit(int64(limit))
@@ -230,12 +229,12 @@ func (br *BlogRepo) FilterByDate(startDate, endDate time.Time, page, limit int)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{
+	filter := visible(bson.M{
 		"created_at": bson.M{
 			"$gte": startDate,
 			"$lte": endDate,
 		},
-	}
+	})
 	opts := options.Find()
 	opts.SetSkip(int64(page-1) * int64(limit))
 	opts.SetLimit(int64(limit))
@@ -266,7 +265,7 @@ func (br *BlogRepo) GetPopular(limit int) ([]*domain.Blog, error) {
 	sort := bson.D{{Key: "view_count", Value: -1}, {Key: "like_count", Value: -1}}
 	opts := options.Find().SetSort(sort).SetLimit(int64(limit))
 
-	cursor, err := br.collection.Find(ctx, bson.D{}, opts)
+	cursor, err := br.collection.Find(ctx, visible(bson.M{}), opts)
 	if err != nil {
 		return nil, err
 	}
@@ -385,3 +384,54 @@ func (br *BlogRepo) RemoveDislike(blogID primitive.ObjectID, userID string) erro
 func (br *BlogRepo) GetTagIDByName(name string) (primitive.ObjectID, error) {
 	return primitive.NilObjectID, errors.New("not implemented")
 }
+
+// hides or shows every blog by the author in public listings
+func (br *BlogRepo) SetHiddenByAuthor(authorID primitive.ObjectID, hidden bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := br.collection.UpdateMany(ctx, bson.M{"author_id": authorID}, bson.M{"$set": bson.M{"hidden": hidden}})
+	return err
+}
+
+// excludes blogs hidden by moderation from a listing filter
+func visible(filter bson.M) bson.M {
+	filter["hidden"] = bson.M{"$ne": true}
+	return filter
+}
+
+func (br *BlogRepo) CountByAuthor(authorID primitive.ObjectID) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return br.collection.CountDocuments(ctx, bson.M{"author_id": authorID})
+}
+
+// counts comments written by the user across all blogs
+func (br *BlogRepo) CountCommentsByAuthor(authorID primitive.ObjectID) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"comments.author_id": authorID}}},
+		{{Key: "$unwind", Value: "$comments"}},
+		{{Key: "$match", Value: bson.M{"comments.author_id": authorID}}},
+		{{Key: "$count", Value: "total"}},
+	}
+	cursor, err := br.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count comments: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Total int64 `bson:"total"`
+	}
+	if err := cursor.All(ctx, &result); err != nil {
+		return 0, err
+	}
+	if len(result) == 0 {
+		return 0, nil
+	}
+	return result[0].Total, nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"Blog-API/internal/domain"
+	"Blog-API/internal/infrastructure/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// how long login events are kept
+const loginHistoryRetention = 180 * 24 * time.Hour
+
+type LoginHistoryRepository struct {
+	db         *database.MongoDB
+	collection *mongo.Collection
+}
+
+func NewLoginHistoryRepository(db *database.MongoDB) domain.LoginHistoryRepository {
+	collection := db.GetCollection("login_history")
+
+	indexModels := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "device_fingerprint", Value: 1}, {Key: "success", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(loginHistoryRetention.Seconds())),
+		},
+	}
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+	if err != nil {
+		// Log error but don't fail - indexes might already exist
+	}
+
+	return &LoginHistoryRepository{
+		db:         db,
+		collection: collection,
+	}
+}
+
+func (r *LoginHistoryRepository) Create(event *domain.LoginEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	event.CreatedAt = time.Now()
+	result, err := r.collection.InsertOne(ctx, event)
+	if err != nil {
+		return err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		event.ID = oid
+	}
+	return nil
+}
+
+func (r *LoginHistoryRepository) ListByUserID(userID primitive.ObjectID, page, limit int) ([]*domain.LoginEvent, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64(page-1) * int64(limit)).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []*domain.LoginEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+func (r *LoginHistoryRepository) HasSuccessfulLogin(userID primitive.ObjectID, fingerprint string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID, "success": true, "method": bson.M{"$ne": domain.LoginMethodRefresh}}
+	if fingerprint != "" {
+		filter["device_fingerprint"] = fingerprint
+	}
+	err := r.collection.FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return err == nil, err
+}
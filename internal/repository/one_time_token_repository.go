@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Blog-API/internal/domain"
+	"Blog-API/internal/infrastructure/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type OneTimeTokenRepository struct {
+	db         *database.MongoDB
+	collection *mongo.Collection
+}
+
+func NewOneTimeTokenRepository(db *database.MongoDB) domain.OneTimeTokenRepository {
+	collection := db.GetCollection("one_time_tokens")
+
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}},
+		},
+		{
+			// Mongo deletes tokens once they expire
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+	if err != nil {
+		// Log error but don't fail - indexes might already exist
+	}
+
+	return &OneTimeTokenRepository{
+		db:         db,
+		collection: collection,
+	}
+}
+
+func (r *OneTimeTokenRepository) Create(token *domain.OneTimeToken) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// only the most recently sent link works
+	_, err := r.collection.DeleteMany(ctx, bson.M{
+		"user_id": token.UserID,
+		"purpose": token.Purpose,
+		"used_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return err
+	}
+
+	token.CreatedAt = time.Now()
+	result, err := r.collection.InsertOne(ctx, token)
+	if err != nil {
+		return err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		token.ID = oid
+	}
+	return nil
+}
+
+func (r *OneTimeTokenRepository) GetValid(purpose, tokenHash string) (*domain.OneTimeToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"token_hash": tokenHash,
+		"purpose":    purpose,
+		"used_at":    bson.M{"$exists": false},
+		// the TTL monitor only runs once a minute
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+	var token domain.OneTimeToken
+	if err := r.collection.FindOne(ctx, filter).Decode(&token); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("token not found")
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *OneTimeTokenRepository) IncrementAttempts(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"attempts": 1}})
+	return err
+}
+
+func (r *OneTimeTokenRepository) MarkUsed(id primitive.ObjectID) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "used_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+func (r *OneTimeTokenRepository) DeleteByUserID(userID primitive.ObjectID, purposes ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	if len(purposes) > 0 {
+		filter["purpose"] = bson.M{"$in": purposes}
+	}
+	_, err := r.collection.DeleteMany(ctx, filter)
+	return err
+}
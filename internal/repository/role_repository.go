@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Blog-API/internal/domain"
+	"Blog-API/internal/infrastructure/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// custom roles; the built-in ones aren't stored
+type RoleRepository struct {
+	db         *database.MongoDB
+	collection *mongo.Collection
+}
+
+func NewRoleRepository(db *database.MongoDB) domain.RoleRepository {
+	collection := db.GetCollection("roles")
+
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+	if err != nil {
+		// Log error but don't fail - indexes might already exist
+	}
+
+	return &RoleRepository{
+		db:         db,
+		collection: collection,
+	}
+}
+
+func (r *RoleRepository) Create(role *domain.Role) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	role.CreatedAt = now
+	role.UpdatedAt = now
+	result, err := r.collection.InsertOne(ctx, role)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.New("role already exists")
+		}
+		return err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		role.ID = oid
+	}
+	return nil
+}
+
+func (r *RoleRepository) GetByName(name string) (*domain.Role, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var role domain.Role
+	if err := r.collection.FindOne(ctx, bson.M{"name": name}).Decode(&role); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("role not found")
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *RoleRepository) List() ([]*domain.Role, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	roles := []*domain.Role{}
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) Update(role *domain.Role) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	role.UpdatedAt = time.Now()
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"name": role.Name},
+		bson.M{"$set": bson.M{
+			"description": role.Description,
+			"permissions": role.Permissions,
+			"updated_at":  role.UpdatedAt,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("role not found")
+	}
+	return nil
+}
+
+func (r *RoleRepository) Delete(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.collection.DeleteOne(ctx, bson.M{"name": name})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return errors.New("role not found")
+	}
+	return nil
+}
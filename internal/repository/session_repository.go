@@ -23,10 +23,13 @@ type SessionRepository struct {
 func NewSessionRepository(db *database.MongoDB) domain.SessionRepository {
 	collection := db.GetCollection("sessions")
 
+	// sessions used to be one per user; drop the old unique index so each device gets its own
+	dropUniqueUserIndex(collection)
+	unsetPlaintextRefreshTokens(collection)
+
 	indexModels := []mongo.IndexModel{
 		{
-			Keys:    bson.D{{Key: "user_id", Value: 1}},
-			Options: options.Index().SetUnique(true),
+			Keys: bson.D{{Key: "user_id", Value: 1}},
 		},
 		{
 			Keys: bson.D{{Key: "username", Value: 1}},
@@ -57,25 +60,13 @@ func (r *SessionRepository) Create(session *domain.Session) error {
 	now := time.Now()
 	session.CreatedAt = now
 	session.LastActivity = now
+	session.AuthenticatedAt = now
 
-	fmt.Printf("DEBUG: Inserting session into database: %+v\n", session)
 	result, err := r.collection.InsertOne(ctx, session)
 	if err != nil {
-		fmt.Printf("DEBUG: Session insertion failed: %v\n", err)
-		// check if user already has a session
-		var mongoErr mongo.WriteException
-		if errors.As(err, &mongoErr) {
-			for _, writeErr := range mongoErr.WriteErrors {
-				if writeErr.Code == 11000 { // duplicate key error
-					fmt.Printf("DEBUG: Duplicate key error, updating session\n")
-					return r.Update(session)
-				}
-			}
-		}
 		return err
 	}
 
-	fmt.Printf("DEBUG: Session inserted successfully with ID: %v\n", result.InsertedID)
 	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
 		session.ID = oid
 	}
@@ -138,12 +129,33 @@ func (r *SessionRepository) Update(session *domain.Session) error {
 
 	_, err := r.collection.UpdateOne(
 		ctx,
-		bson.M{"user_id": session.UserID},
+		bson.M{"_id": session.ID},
 		bson.M{"$set": session},
 	)
 	return err
 }
 
+func (r *SessionRepository) RotateRefreshToken(id primitive.ObjectID, oldHash, newHash string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "refresh_token_hash": oldHash},
+		bson.M{
+			"$set": bson.M{
+				"refresh_token_hash": newHash,
+				"last_activity":      time.Now(),
+			},
+			"$unset": bson.M{"token": ""},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount == 1, nil
+}
+
 func (r *SessionRepository) Delete(id primitive.ObjectID) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -152,14 +164,47 @@ func (r *SessionRepository) Delete(id primitive.ObjectID) error {
 	return err
 }
 
+// deletes every session of the user, signing them out on all devices
 func (r *SessionRepository) DeleteByUserID(userID primitive.ObjectID) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID})
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
 	return err
 }
 
+func (r *SessionRepository) DeleteByUserIDExcept(userID, keepSessionID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID, "_id": bson.M{"$ne": keepSessionID}})
+	return err
+}
+
+// login sessions that have not expired, most recently used first
+func (r *SessionRepository) ListActiveByUserID(userID primitive.ObjectID) ([]*domain.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id":    userID,
+		"is_active":  true,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "last_activity", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []*domain.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
 func (r *SessionRepository) DeleteExpired() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -180,32 +225,51 @@ func (r *SessionRepository) UpdateLastActivity(id primitive.ObjectID) error {
 	return err
 }
 
-func (r *SessionRepository) GetByVerificationToken(token string) (*domain.Session, error) {
+func (r *SessionRepository) MarkAuthenticated(id primitive.ObjectID) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var session domain.Session
-	err := r.collection.FindOne(ctx, bson.M{"verification_token": token}).Decode(&session)
+	now := time.Now()
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"authenticated_at": now, "last_activity": now}},
+	)
+	return err
+}
+
+func dropUniqueUserIndex(collection *mongo.Collection) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	specs, err := collection.Indexes().ListSpecifications(ctx)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("session not found")
+		return
+	}
+	for _, spec := range specs {
+		if spec.Name == "user_id_1" && spec.Unique != nil && *spec.Unique {
+			if _, err := collection.Indexes().DropOne(ctx, spec.Name); err != nil {
+				fmt.Printf("Failed to drop unique session index: %v\n", err)
+			}
 		}
-		return nil, err
 	}
-	return &session, nil
 }
 
-func (r *SessionRepository) GetByResetToken(token string) (*domain.Session, error) {
+// sessions used to store the refresh token itself in "token"; only its hash is
+// kept now, so strip the plaintext from documents written before the change
+func unsetPlaintextRefreshTokens(collection *mongo.Collection) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var session domain.Session
-	err := r.collection.FindOne(ctx, bson.M{"password_reset_token": token}).Decode(&session)
+	res, err := collection.UpdateMany(ctx,
+		bson.M{"token": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"token": ""}},
+	)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("session not found")
-		}
-		return nil, err
+		fmt.Printf("Failed to remove plaintext refresh tokens from sessions: %v\n", err)
+		return
+	}
+	if res.ModifiedCount > 0 {
+		fmt.Printf("Removed plaintext refresh tokens from %d sessions\n", res.ModifiedCount)
 	}
-	return &session, nil
 }
@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"regexp"
 	"time"
 
 	"Blog-API/internal/domain"
@@ -22,6 +24,9 @@ type UserRepository struct {
 func NewUserRepository(db *database.MongoDB) domain.UserRepository {
 	collection := db.GetCollection("users")
 
+	// users used to hold a single oauth_provider/oauth_id pair
+	migrateOAuthIdentities(collection)
+
 	// Create indexes for better performance
 	indexModels := []mongo.IndexModel{
 		{
@@ -32,6 +37,12 @@ func NewUserRepository(db *database.MongoDB) domain.UserRepository {
 			Keys:    bson.D{{Key: "username", Value: 1}},
 			Options: options.Index().SetUnique(true),
 		},
+		{
+			// a provider account can only be linked to one user
+			Keys: bson.D{{Key: "linked_identities.provider", Value: 1}, {Key: "linked_identities.subject", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"linked_identities.subject": bson.M{"$exists": true}}),
+		},
 	}
 
 	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
@@ -179,23 +190,28 @@ func (r *UserRepository) UpdateProfile(id primitive.ObjectID, updates map[string
 	return err
 }
 
-// updates user password
-func (r *UserRepository) UpdatePassword(id primitive.ObjectID, password string) error {
+// updates user password and lifts a required reset; tokens issued before the change stop working
+func (r *UserRepository) UpdatePassword(id primitive.ObjectID, password string, history []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	_, err := r.collection.UpdateOne(
 		ctx,
 		bson.M{"_id": id},
-		bson.M{"$set": bson.M{
-			"password":   password,
-			"updated_at": time.Now(),
-		}},
+		bson.M{
+			"$set": bson.M{
+				"password":         password,
+				"password_history": history,
+				"updated_at":       time.Now(),
+			},
+			"$unset": bson.M{"password_reset_required": ""},
+			"$inc":   bson.M{"token_version": 1},
+		},
 	)
 	return err
 }
 
-// updates user role
+// updates user role; tokens carrying the old role stop working
 func (r *UserRepository) UpdateRole(id primitive.ObjectID, role string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -203,10 +219,13 @@ func (r *UserRepository) UpdateRole(id primitive.ObjectID, role string) error {
 	_, err := r.collection.UpdateOne(
 		ctx,
 		bson.M{"_id": id},
-		bson.M{"$set": bson.M{
-			"role":       role,
-			"updated_at": time.Now(),
-		}},
+		bson.M{
+			"$set": bson.M{
+				"role":       role,
+				"updated_at": time.Now(),
+			},
+			"$inc": bson.M{"token_version": 1},
+		},
 	)
 	return err
 }
@@ -295,8 +314,7 @@ func (r *UserRepository) GetByOAuth(provider, oauthID string) (*domain.User, err
 	defer cancel()
 	var user domain.User
 	filter := bson.M{
-		"oauth_provider": provider,
-		"oauth_id":       oauthID,
+		"linked_identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": oauthID}},
 	}
 	err := r.collection.FindOne(ctx, filter).Decode(&user)
 	if err != nil {
@@ -307,3 +325,235 @@ func (r *UserRepository) GetByOAuth(provider, oauthID string) (*domain.User, err
 	}
 	return &user, nil
 }
+
+// lists users matching the filter, newest first
+func (r *UserRepository) List(filter domain.UserFilter, page, limit int) ([]*domain.User, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := bson.M{}
+	if filter.Search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(filter.Search), Options: "i"}
+		query["$or"] = bson.A{
+			bson.M{"username": pattern},
+			bson.M{"email": pattern},
+		}
+	}
+	if filter.Role != "" {
+		query["role"] = filter.Role
+	}
+	if filter.EmailVerified != nil {
+		query["email_verified"] = *filter.EmailVerified
+	}
+	switch filter.OAuthProvider {
+	case "":
+	case "none":
+		query["linked_identities.0"] = bson.M{"$exists": false}
+	default:
+		query["linked_identities.provider"] = filter.OAuthProvider
+	}
+	if filter.CreatedAfter != nil || filter.CreatedBefore != nil {
+		created := bson.M{}
+		if filter.CreatedAfter != nil {
+			created["$gte"] = *filter.CreatedAfter
+		}
+		if filter.CreatedBefore != nil {
+			created["$lte"] = *filter.CreatedBefore
+		}
+		query["created_at"] = created
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64(page-1) * int64(limit)).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []*domain.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *UserRepository) SetRestriction(id primitive.ObjectID, restriction *domain.AccountRestriction) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"restriction": restriction,
+		"updated_at":  time.Now(),
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+func (r *UserRepository) ClearRestriction(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$unset": bson.M{"restriction": ""},
+		"$set":   bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// invalidates every token issued to the user so far
+func (r *UserRepository) IncrementTokenVersion(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$inc": bson.M{"token_version": 1},
+		"$set": bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+func (r *UserRepository) SetTwoFactor(id primitive.ObjectID, twoFactor *domain.TwoFactor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"two_factor": twoFactor,
+		"updated_at": time.Now(),
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+func (r *UserRepository) ClearTwoFactor(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$unset": bson.M{"two_factor": ""},
+		"$set":   bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// only moves last_used_step forward, so two requests can't both use one code
+func (r *UserRepository) ConsumeTOTPStep(id primitive.ObjectID, step int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "two_factor.last_used_step": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"two_factor.last_used_step": step}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+func (r *UserRepository) ConsumeRecoveryCode(id primitive.ObjectID, codeHash string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "two_factor.recovery_codes": codeHash},
+		bson.M{"$pull": bson.M{"two_factor.recovery_codes": codeHash}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+func (r *UserRepository) AddLinkedIdentity(id primitive.ObjectID, identity *domain.LinkedIdentity) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "linked_identities.provider": bson.M{"$ne": identity.Provider}},
+		bson.M{
+			"$push": bson.M{"linked_identities": identity},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.New("this " + identity.Provider + " account is already linked to another user")
+		}
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("a " + identity.Provider + " account is already linked")
+	}
+	return nil
+}
+
+func (r *UserRepository) RemoveLinkedIdentity(id primitive.ObjectID, provider string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{
+			"$pull": bson.M{"linked_identities": bson.M{"provider": provider}},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	return err
+}
+
+// moves the old oauth_provider/oauth_id fields into linked_identities
+func migrateOAuthIdentities(collection *mongo.Collection) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"oauth_provider": bson.M{"$nin": bson.A{nil, ""}}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"linked_identities": bson.A{bson.M{
+			"provider":  "$oauth_provider",
+			"subject":   "$oauth_id",
+			"email":     "$email",
+			"linked_at": "$created_at",
+		}}}}},
+		{{Key: "$unset", Value: bson.A{"oauth_provider", "oauth_id"}}},
+	}
+	if _, err := collection.UpdateMany(ctx, filter, update); err != nil {
+		fmt.Printf("Failed to migrate OAuth identities: %v\n", err)
+	}
+}
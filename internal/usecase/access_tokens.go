@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"Blog-API/internal/domain"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxAccessTokensPerUser = 50
+
+// creates a personal access token. The token itself is only returned here;
+// afterwards just its hash is kept.
+func (u *UserUseCase) CreateAccessToken(claims *domain.JWTClaims, req *domain.CreateAccessTokenRequest, client domain.ClientInfo) (resp *domain.CreateAccessTokenResponse, err error) {
+	entry := auditEntry(domain.Actor{UserID: claims.UserID, Role: claims.Role, Client: client}, domain.AuditAccessTokenCreate, domain.AuditTargetAccessToken, "")
+	entry.After = map[string]string{"name": req.Name, "scopes": strings.Join(uniqueScopes(req.Scopes), ",")}
+	defer func() { u.audit.Record(entry, err) }()
+
+	if err := u.requireRecentAuth(claims); err != nil {
+		return nil, err
+	}
+	count, err := u.accessTokens.CountByUserID(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxAccessTokensPerUser {
+		return nil, fmt.Errorf("cannot have more than %d access tokens, revoke one first", maxAccessTokensPerUser)
+	}
+
+	token := domain.AccessTokenPrefix + u.passwordService.GenerateSecureToken(64)
+	accessToken := &domain.PersonalAccessToken{
+		UserID:    claims.UserID,
+		Name:      req.Name,
+		TokenHash: hashToken(token),
+		Hint:      token[:len(domain.AccessTokenPrefix)+4],
+		Scopes:    uniqueScopes(req.Scopes),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		accessToken.ExpiresAt = &expiresAt
+	}
+	if err := u.accessTokens.Create(accessToken); err != nil {
+		return nil, err
+	}
+	entry.TargetID = accessToken.ID.Hex()
+	return &domain.CreateAccessTokenResponse{Token: token, AccessToken: accessToken}, nil
+}
+
+func (u *UserUseCase) ListAccessTokens(userID primitive.ObjectID) ([]*domain.PersonalAccessToken, error) {
+	return u.accessTokens.ListByUserID(userID)
+}
+
+func (u *UserUseCase) RevokeAccessToken(userID, tokenID primitive.ObjectID, client domain.ClientInfo) (err error) {
+	entry := auditEntry(domain.Actor{UserID: userID, Client: client}, domain.AuditAccessTokenRevoke, domain.AuditTargetAccessToken, tokenID.Hex())
+	defer func() { u.audit.Record(entry, err) }()
+
+	if tokenID.IsZero() {
+		return errors.New("access token not found")
+	}
+	return u.accessTokens.Delete(tokenID, userID)
+}
+
+func uniqueScopes(scopes []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,403 @@
+package usecase
+
+import (
+	"Blog-API/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// how long an impersonation token lasts when the request doesn't say
+const defaultImpersonationExpiry = 15 * time.Minute
+
+type AdminUseCase struct {
+	userRepo       domain.UserRepository
+	blogRepo       domain.BlogRepository
+	sessionRepo    domain.SessionRepository
+	securityEvents domain.SecurityEventRepository
+	cache          domain.Cache
+	loginThrottler domain.LoginThrottler
+	accessTokens   domain.PersonalAccessTokenRepository
+	permissions    domain.PermissionChecker
+	audit          domain.AuditLog
+	jwtService     domain.JWTService
+}
+
+func NewAdminUseCase(
+	userRepo domain.UserRepository,
+	blogRepo domain.BlogRepository,
+	sessionRepo domain.SessionRepository,
+	securityEvents domain.SecurityEventRepository,
+	cache domain.Cache,
+	loginThrottler domain.LoginThrottler,
+	accessTokens domain.PersonalAccessTokenRepository,
+	permissions domain.PermissionChecker,
+	audit domain.AuditLog,
+	jwtService domain.JWTService,
+) domain.AdminUseCase {
+	return &AdminUseCase{
+		userRepo:       userRepo,
+		blogRepo:       blogRepo,
+		sessionRepo:    sessionRepo,
+		securityEvents: securityEvents,
+		cache:          cache,
+		loginThrottler: loginThrottler,
+		accessTokens:   accessTokens,
+		permissions:    permissions,
+		audit:          audit,
+		jwtService:     jwtService,
+	}
+}
+
+func (a *AdminUseCase) ListUsers(filter domain.UserFilter, page, limit int) ([]*domain.User, int64, error) {
+	return a.userRepo.List(filter, page, limit)
+}
+
+func (a *AdminUseCase) GetUserDetails(id primitive.ObjectID) (*domain.AdminUserDetails, error) {
+	user, err := a.userRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	blogCount, err := a.blogRepo.CountByAuthor(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count blogs: %w", err)
+	}
+	commentCount, err := a.blogRepo.CountCommentsByAuthor(id)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.AdminUserDetails{
+		User:         user,
+		BlogCount:    blogCount,
+		CommentCount: commentCount,
+	}, nil
+}
+
+func (a *AdminUseCase) UpdateUser(actor domain.Actor, targetUserID primitive.ObjectID, req *domain.AdminUpdateUserRequest) (updated *domain.User, err error) {
+	entry := auditEntry(actor, domain.AuditUserUpdate, domain.AuditTargetUser, targetUserID.Hex())
+	defer func() { a.audit.Record(entry, err) }()
+
+	user, err := a.managedUser(actor, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// only fields that actually change are updated and audited
+	updates := make(map[string]interface{})
+	entry.Before, entry.After = map[string]string{}, map[string]string{}
+	if req.Username != nil && *req.Username != user.Username {
+		if existingUser, _ := a.userRepo.GetByUsername(*req.Username); existingUser != nil && existingUser.ID != targetUserID {
+			return nil, errors.New("username already exists")
+		}
+		updates["username"] = *req.Username
+		entry.Before["username"], entry.After["username"] = user.Username, *req.Username
+	}
+	if req.Email != nil && *req.Email != user.Email {
+		if existingUser, _ := a.userRepo.GetByEmail(*req.Email); existingUser != nil && existingUser.ID != targetUserID {
+			return nil, errors.New("email already exists")
+		}
+		updates["email"] = *req.Email
+		entry.Before["email"], entry.After["email"] = user.Email, *req.Email
+		// the new address hasn't been proven yet, unless the request says otherwise
+		if user.EmailVerified && req.EmailVerified == nil {
+			updates["email_verified"] = false
+			entry.Before["email_verified"], entry.After["email_verified"] = "true", "false"
+		}
+	}
+	if req.Bio != nil && *req.Bio != user.Bio {
+		updates["bio"] = *req.Bio
+		entry.Before["bio"], entry.After["bio"] = user.Bio, *req.Bio
+	}
+	if req.Role != nil && *req.Role != user.Role {
+		if actor.UserID == targetUserID {
+			return nil, errors.New("admins cannot change their own role")
+		}
+		adminUser, err := a.userRepo.GetByID(actor.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.permissions.CanAssign(adminUser.Role, user.Role, *req.Role); err != nil {
+			return nil, err
+		}
+		updates["role"] = *req.Role
+		entry.Before["role"], entry.After["role"] = user.Role, *req.Role
+	}
+	if req.EmailVerified != nil && *req.EmailVerified != user.EmailVerified {
+		updates["email_verified"] = *req.EmailVerified
+		entry.Before["email_verified"], entry.After["email_verified"] = fmt.Sprint(user.EmailVerified), fmt.Sprint(*req.EmailVerified)
+	}
+
+	if len(updates) > 0 {
+		if err := a.userRepo.UpdateProfile(targetUserID, updates); err != nil {
+			return nil, err
+		}
+	}
+	// tokens still carry the old role, make the user pick up the new one
+	if _, roleChanged := updates["role"]; roleChanged {
+		if err := a.userRepo.IncrementTokenVersion(targetUserID); err != nil {
+			return nil, err
+		}
+	}
+	return a.userRepo.GetByID(targetUserID)
+}
+
+func (a *AdminUseCase) VerifyUserEmail(actor domain.Actor, targetUserID primitive.ObjectID) (err error) {
+	entry := auditEntry(actor, domain.AuditUserVerifyEmail, domain.AuditTargetUser, targetUserID.Hex())
+	defer func() { a.audit.Record(entry, err) }()
+
+	if _, err := a.managedUser(actor, targetUserID); err != nil {
+		return err
+	}
+	return a.userRepo.VerifyEmail(targetUserID)
+}
+
+// signs the user out everywhere and revokes their access tokens; they have to log in again
+func (a *AdminUseCase) ResetUserSessions(actor domain.Actor, targetUserID primitive.ObjectID) (err error) {
+	entry := auditEntry(actor, domain.AuditUserSessionsReset, domain.AuditTargetUser, targetUserID.Hex())
+	defer func() { a.audit.Record(entry, err) }()
+
+	if _, err := a.managedUser(actor, targetUserID); err != nil {
+		return err
+	}
+	return a.revokeAllAccess(targetUserID)
+}
+
+// removes the account, its sessions and access tokens; blogs and comments stay under the old username
+func (a *AdminUseCase) DeleteUser(actor domain.Actor, targetUserID primitive.ObjectID) (err error) {
+	entry := auditEntry(actor, domain.AuditUserDelete, domain.AuditTargetUser, targetUserID.Hex())
+	defer func() { a.audit.Record(entry, err) }()
+
+	if actor.UserID == targetUserID {
+		return errors.New("admins cannot delete their own account")
+	}
+	user, err := a.managedUser(actor, targetUserID)
+	if err != nil {
+		return err
+	}
+	entry.Before = map[string]string{"username": user.Username, "email": user.Email, "role": user.Role}
+	if err := a.sessionRepo.DeleteByUserID(targetUserID); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	if err := a.accessTokens.DeleteByUserID(targetUserID); err != nil {
+		return fmt.Errorf("failed to delete access tokens: %w", err)
+	}
+	return a.userRepo.Delete(targetUserID)
+}
+
+// suspends or bans the user, signs them out everywhere and revokes their access tokens
+func (a *AdminUseCase) RestrictUser(actor domain.Actor, targetUserID primitive.ObjectID, req *domain.RestrictUserRequest) (restricted *domain.User, err error) {
+	entry := auditEntry(actor, domain.AuditUserRestrict, domain.AuditTargetUser, targetUserID.Hex())
+	entry.After = restrictionSummary(&domain.AccountRestriction{Type: req.Type, Reason: req.Reason, ExpiresAt: req.ExpiresAt, HideContent: req.HideContent})
+	defer func() { a.audit.Record(entry, err) }()
+
+	if actor.UserID == targetUserID {
+		return nil, errors.New("admins cannot restrict their own account")
+	}
+	if req.Type == domain.RestrictionBanned && req.ExpiresAt != nil {
+		return nil, errors.New("bans are permanent, use a suspension for a time-limited restriction")
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+
+	user, err := a.userRepo.GetByID(targetUserID)
+	if err != nil {
+		return nil, err
+	}
+	privileged, err := a.permissions.IsPrivileged(user.Role)
+	if err != nil {
+		return nil, err
+	}
+	if privileged {
+		return nil, fmt.Errorf("users with the %s role cannot be restricted, change their role first", user.Role)
+	}
+	if user.Restriction != nil {
+		entry.Before = restrictionSummary(user.Restriction)
+	}
+
+	restriction := &domain.AccountRestriction{
+		Type:        req.Type,
+		Reason:      req.Reason,
+		IssuedBy:    actor.UserID,
+		IssuedAt:    time.Now(),
+		ExpiresAt:   req.ExpiresAt,
+		HideContent: req.HideContent,
+	}
+	if err := a.userRepo.SetRestriction(targetUserID, restriction); err != nil {
+		return nil, err
+	}
+	if err := a.revokeAllAccess(targetUserID); err != nil {
+		return nil, err
+	}
+	// an earlier restriction may have hidden content this one doesn't
+	wasHidden := user.Restriction != nil && user.Restriction.HideContent
+	if req.HideContent != wasHidden {
+		if err := a.setContentHidden(targetUserID, req.HideContent); err != nil {
+			return nil, err
+		}
+	}
+	return a.userRepo.GetByID(targetUserID)
+}
+
+// lifts any suspension or ban and shows hidden content again
+func (a *AdminUseCase) ReinstateUser(actor domain.Actor, targetUserID primitive.ObjectID) (reinstated *domain.User, err error) {
+	entry := auditEntry(actor, domain.AuditUserReinstate, domain.AuditTargetUser, targetUserID.Hex())
+	defer func() { a.audit.Record(entry, err) }()
+
+	user, err := a.userRepo.GetByID(targetUserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Restriction == nil {
+		return nil, errors.New("user is not suspended or banned")
+	}
+	entry.Before = restrictionSummary(user.Restriction)
+	if err := a.userRepo.ClearRestriction(targetUserID); err != nil {
+		return nil, err
+	}
+	if user.Restriction.HideContent {
+		if err := a.setContentHidden(targetUserID, false); err != nil {
+			return nil, err
+		}
+	}
+	return a.userRepo.GetByID(targetUserID)
+}
+
+// for users who lost their authenticator and recovery codes; they are signed
+// out everywhere and can set two-factor authentication up again after logging in
+func (a *AdminUseCase) ResetTwoFactor(actor domain.Actor, targetUserID primitive.ObjectID) (err error) {
+	entry := auditEntry(actor, domain.AuditUserTwoFactorReset, domain.AuditTargetUser, targetUserID.Hex())
+	defer func() { a.audit.Record(entry, err) }()
+
+	user, err := a.managedUser(actor, targetUserID)
+	if err != nil {
+		return err
+	}
+	if user.TwoFactor == nil {
+		return errors.New("two-factor authentication is not enabled for this user")
+	}
+	if err := a.userRepo.ClearTwoFactor(targetUserID); err != nil {
+		return err
+	}
+	return a.revokeAllAccess(targetUserID)
+}
+
+// deletes the user's sessions and personal access tokens
+func (a *AdminUseCase) revokeAllAccess(userID primitive.ObjectID) error {
+	if err := a.sessionRepo.DeleteByUserID(userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	if err := a.accessTokens.DeleteByUserID(userID); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+	return nil
+}
+
+// lifts a lockout from failed logins before it runs out
+func (a *AdminUseCase) UnlockUser(actor domain.Actor, targetUserID primitive.ObjectID) (err error) {
+	entry := auditEntry(actor, domain.AuditUserUnlock, domain.AuditTargetUser, targetUserID.Hex())
+	defer func() { a.audit.Record(entry, err) }()
+
+	user, err := a.managedUser(actor, targetUserID)
+	if err != nil {
+		return err
+	}
+	return a.loginThrottler.Unlock(context.Background(), user.Email)
+}
+
+// lets support see the API as the user does. Tokens are read-only unless the
+// request allows writes, and staff accounts can't be impersonated.
+func (a *AdminUseCase) ImpersonateUser(actor domain.Actor, sessionID, targetUserID primitive.ObjectID, req *domain.ImpersonateRequest) (resp *domain.ImpersonationResponse, err error) {
+	expiry := defaultImpersonationExpiry
+	if req.ExpiresInMinutes > 0 {
+		expiry = time.Duration(req.ExpiresInMinutes) * time.Minute
+	}
+	entry := auditEntry(actor, domain.AuditUserImpersonate, domain.AuditTargetUser, targetUserID.Hex())
+	entry.After = map[string]string{
+		"reason":     req.Reason,
+		"read_only":  fmt.Sprint(!req.AllowWrite),
+		"expires_in": expiry.String(),
+	}
+	defer func() { a.audit.Record(entry, err) }()
+
+	if !actor.Client.ImpersonatorID.IsZero() {
+		return nil, errors.New("impersonation tokens cannot start another impersonation")
+	}
+	if actor.UserID == targetUserID {
+		return nil, errors.New("admins cannot impersonate themselves")
+	}
+
+	user, err := a.userRepo.GetByID(targetUserID)
+	if err != nil {
+		return nil, err
+	}
+	privileged, err := a.permissions.IsPrivileged(user.Role)
+	if err != nil {
+		return nil, err
+	}
+	if privileged {
+		return nil, fmt.Errorf("users with the %s role cannot be impersonated", user.Role)
+	}
+	if restriction := user.ActiveRestriction(); restriction != nil {
+		return nil, fmt.Errorf("%s accounts cannot be impersonated, reinstate the user first", restriction.Type)
+	}
+	admin, err := a.userRepo.GetByID(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := a.jwtService.GenerateImpersonationToken(user, admin, sessionID, !req.AllowWrite, expiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate impersonation token: %w", err)
+	}
+	return &domain.ImpersonationResponse{
+		AccessToken: token,
+		ExpiresAt:   time.Now().Add(expiry),
+		ReadOnly:    !req.AllowWrite,
+		User:        user,
+	}, nil
+}
+
+// the target user, if the actor's role holds every permission of theirs
+func (a *AdminUseCase) managedUser(actor domain.Actor, targetUserID primitive.ObjectID) (*domain.User, error) {
+	user, err := a.userRepo.GetByID(targetUserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.permissions.CanManage(actor.Role, user.Role); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (a *AdminUseCase) setContentHidden(authorID primitive.ObjectID, hidden bool) error {
+	if err := a.blogRepo.SetHiddenByAuthor(authorID, hidden); err != nil {
+		return fmt.Errorf("failed to update blog visibility: %w", err)
+	}
+	// cached listings may still contain (or be missing) the author's blogs
+	if err := a.cache.DeleteByPattern(context.Background(), "blogs:*"); err != nil {
+		log.Printf("Failed to invalidate blog listings cache: %v", err)
+	}
+	return nil
+}
+
+// the audit log's view of a suspension or ban
+func restrictionSummary(r *domain.AccountRestriction) map[string]string {
+	summary := map[string]string{
+		"type":         r.Type,
+		"reason":       r.Reason,
+		"hide_content": fmt.Sprint(r.HideContent),
+	}
+	if r.ExpiresAt != nil {
+		summary["expires_at"] = r.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return summary
+}
+
+func (a *AdminUseCase) ListSecurityEvents(targetUserID primitive.ObjectID, page, limit int) ([]*domain.SecurityEvent, int64, error) {
+	return a.securityEvents.ListByUserID(targetUserID, page, limit)
+}
@@ -0,0 +1,172 @@
+package usecase
+
+import (
+	"Blog-API/internal/domain"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// retries when another instance appended between reading the last entry and writing
+	maxAuditAppendAttempts = 5
+	auditVerifyBatchSize   = 1000
+)
+
+type auditLog struct {
+	repo domain.AuditLogRepository
+	// serialises appends from this instance; the unique seq index covers the others
+	mu sync.Mutex
+}
+
+func NewAuditLog(repo domain.AuditLogRepository) domain.AuditLog {
+	return &auditLog{repo: repo}
+}
+
+// an entry for something actor did to the target; callers add Before and After
+func auditEntry(actor domain.Actor, action, targetType, targetID string) *domain.AuditEntry {
+	return &domain.AuditEntry{
+		ActorID:    actor.UserID,
+		ActorRole:  actor.Role,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IPAddress:  actor.Client.IPAddress,
+		UserAgent:  actor.Client.UserAgent,
+		// set when an admin did this while impersonating the actor
+		ImpersonatorID: actor.Client.ImpersonatorID,
+	}
+}
+
+func (l *auditLog) Record(entry *domain.AuditEntry, err error) {
+	entry.Result = domain.AuditResultSuccess
+	if err != nil {
+		entry.Result = domain.AuditResultFailure
+		entry.Error = err.Error()
+	}
+	// Mongo keeps milliseconds; hash what will be read back
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for attempt := 0; attempt < maxAuditAppendAttempts; attempt++ {
+		var last *domain.AuditEntry
+		last, err = l.repo.Last()
+		if err != nil && err.Error() != "audit entry not found" {
+			break
+		}
+		entry.Seq, entry.PrevHash = 1, ""
+		if last != nil {
+			entry.Seq, entry.PrevHash = last.Seq+1, last.Hash
+		}
+		entry.Hash = auditHash(entry)
+
+		err = l.repo.Append(entry)
+		if err == nil || err.Error() != "audit entry already exists" {
+			break
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to write audit entry %s on %s %s: %v", entry.Action, entry.TargetType, entry.TargetID, err)
+	}
+}
+
+func (l *auditLog) List(filter domain.AuditFilter, page, limit int) ([]*domain.AuditEntry, int64, error) {
+	return l.repo.List(filter, page, limit)
+}
+
+func (l *auditLog) Verify() (*domain.AuditVerification, error) {
+	result := &domain.AuditVerification{Valid: true}
+	var seq int64
+	prevHash := ""
+	for {
+		entries, err := l.repo.ListAfter(seq, auditVerifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			switch {
+			case entry.Seq != seq+1:
+				result.BrokenAt = seq + 1
+				result.Problem = fmt.Sprintf("entries %d to %d are missing", seq+1, entry.Seq-1)
+				if entry.Seq == seq+2 {
+					result.Problem = fmt.Sprintf("entry %d is missing", seq+1)
+				}
+			case entry.PrevHash != prevHash:
+				result.BrokenAt = entry.Seq
+				result.Problem = "previous hash doesn't match the entry before it"
+			case auditHash(entry) != entry.Hash:
+				result.BrokenAt = entry.Seq
+				result.Problem = "hash doesn't match the entry's contents"
+			}
+			if result.Problem != "" {
+				result.Valid = false
+				result.LastSeq, result.LastHash = seq, prevHash
+				return result, nil
+			}
+			seq, prevHash = entry.Seq, entry.Hash
+			result.Entries++
+		}
+		if len(entries) < auditVerifyBatchSize {
+			break
+		}
+	}
+	result.LastSeq, result.LastHash = seq, prevHash
+	return result, nil
+}
+
+// SHA-256 over every field but the ID and the hash itself. json.Marshal sorts
+// map keys, so the same entry always hashes the same.
+func auditHash(entry *domain.AuditEntry) string {
+	fields := struct {
+		Seq        int64             `json:"seq"`
+		ActorID    string            `json:"actor_id"`
+		ActorRole  string            `json:"actor_role"`
+		Action     string            `json:"action"`
+		TargetType string            `json:"target_type"`
+		TargetID   string            `json:"target_id"`
+		IPAddress  string            `json:"ip_address"`
+		UserAgent  string            `json:"user_agent"`
+		Before     map[string]string `json:"before"`
+		After      map[string]string `json:"after"`
+		Result     string            `json:"result"`
+		Error      string            `json:"error"`
+		CreatedAt  int64             `json:"created_at"`
+		PrevHash   string            `json:"prev_hash"`
+
+		// omitted when empty so entries written before impersonation existed still verify
+		ImpersonatorID string `json:"impersonator_id,omitempty"`
+	}{
+		Seq:        entry.Seq,
+		ActorRole:  entry.ActorRole,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		IPAddress:  entry.IPAddress,
+		UserAgent:  entry.UserAgent,
+		Result:     entry.Result,
+		Error:      entry.Error,
+		CreatedAt:  entry.CreatedAt.UnixMilli(),
+		PrevHash:   entry.PrevHash,
+	}
+	if !entry.ActorID.IsZero() {
+		fields.ActorID = entry.ActorID.Hex()
+	}
+	if !entry.ImpersonatorID.IsZero() {
+		fields.ImpersonatorID = entry.ImpersonatorID.Hex()
+	}
+	// empty maps aren't stored, so they read back as nil
+	if len(entry.Before) > 0 {
+		fields.Before = entry.Before
+	}
+	if len(entry.After) > 0 {
+		fields.After = entry.After
+	}
+	data, _ := json.Marshal(fields)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
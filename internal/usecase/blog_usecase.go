@@ -13,23 +13,38 @@ import (
 )
 
 type blogUseCase struct {
-	blogRepo domain.BlogRepository
-	userRepo domain.UserRepository
-	cache    domain.Cache
+	blogRepo    domain.BlogRepository
+	userRepo    domain.UserRepository
+	cache       domain.Cache
+	permissions domain.PermissionChecker
+	audit       domain.AuditLog
 }
 
 func NewBlogUseCase(
 	blogRepo domain.BlogRepository,
 	userRepo domain.UserRepository,
 	cache domain.Cache,
+	permissions domain.PermissionChecker,
+	audit domain.AuditLog,
 ) domain.BlogUseCase {
 	return &blogUseCase{
-		blogRepo: blogRepo,
-		userRepo: userRepo,
-		cache:    cache,
+		blogRepo:    blogRepo,
+		userRepo:    userRepo,
+		cache:       cache,
+		permissions: permissions,
+		audit:       audit,
 	}
 }
 
+// whether the role grants the permission; a failed lookup denies
+func (uc *blogUseCase) can(role, permission string) bool {
+	ok, err := uc.permissions.HasPermission(role, permission)
+	if err != nil {
+		log.Printf("Failed to check permission %s for role %s: %v", permission, role, err)
+	}
+	return ok
+}
+
 func (uc *blogUseCase) CreateBlog(blog *domain.Blog, authorID primitive.ObjectID) error {
 	author, err := uc.userRepo.GetByID(authorID)
 	if err != nil {
@@ -106,7 +121,7 @@ func (uc *blogUseCase) UpdateBlog(id primitive.ObjectID, blogUpdate *domain.Blog
 	if err != nil {
 		return nil, errors.New("blog not found")
 	}
-	if originalBlog.AuthorID != userID && userRole != domain.RoleAdmin {
+	if originalBlog.AuthorID != userID && !uc.can(userRole, domain.PermissionBlogUpdateAny) {
 		return nil, errors.New("forbidden: you are not authorized to update this post")
 	}
 
@@ -125,12 +140,16 @@ func (uc *blogUseCase) UpdateBlog(id primitive.ObjectID, blogUpdate *domain.Blog
 	return originalBlog, nil
 }
 
-func (uc *blogUseCase) DeleteBlog(id primitive.ObjectID, userID primitive.ObjectID, userRole string) error {
+func (uc *blogUseCase) DeleteBlog(id primitive.ObjectID, userID primitive.ObjectID, userRole string, client domain.ClientInfo) (err error) {
+	entry := auditEntry(domain.Actor{UserID: userID, Role: userRole, Client: client}, domain.AuditBlogDelete, domain.AuditTargetBlog, id.Hex())
+	defer func() { uc.audit.Record(entry, err) }()
+
 	blog, err := uc.blogRepo.GetByID(id)
 	if err != nil {
 		return errors.New("blog not found")
 	}
-	if blog.AuthorID != userID && userRole != domain.RoleAdmin {
+	entry.Before = map[string]string{"title": blog.Title, "author_id": blog.AuthorID.Hex()}
+	if blog.AuthorID != userID && !uc.can(userRole, domain.PermissionBlogDeleteAny) {
 		return errors.New("forbidden: you are not authorized to delete this post")
 	}
 	if err := uc.blogRepo.Delete(id); err != nil {
@@ -306,7 +325,10 @@ func (uc *blogUseCase) GetPopularBlogs(limit int) ([]*domain.Blog, error) {
 	return dbBlogs, nil
 }
 
-func (uc *blogUseCase) DeleteComment(blogID, commentID primitive.ObjectID, userID primitive.ObjectID) error {
+func (uc *blogUseCase) DeleteComment(blogID, commentID primitive.ObjectID, userID primitive.ObjectID, client domain.ClientInfo) (err error) {
+	entry := auditEntry(domain.Actor{UserID: userID, Client: client}, domain.AuditCommentDelete, domain.AuditTargetComment, commentID.Hex())
+	defer func() { uc.audit.Record(entry, err) }()
+
 	blog, err := uc.blogRepo.GetByID(blogID)
 	if err != nil {
 		return errors.New("blog not found")
@@ -315,12 +337,14 @@ func (uc *blogUseCase) DeleteComment(blogID, commentID primitive.ObjectID, userI
 	if err != nil {
 		return errors.New("user not found")
 	}
+	entry.ActorRole = user.Role
 	var commentAuthorID primitive.ObjectID
 	found := false
 	for _, c := range blog.Comments {
 		if c.ID == commentID {
 			commentAuthorID = c.AuthorID
 			found = true
+			entry.Before = map[string]string{"blog_id": blogID.Hex(), "author_id": c.AuthorID.Hex(), "content": c.Content}
 			break
 		}
 	}
@@ -331,9 +355,9 @@ func (uc *blogUseCase) DeleteComment(blogID, commentID primitive.ObjectID, userI
 
 	isCommentAuthor := commentAuthorID == userID
 	isBlogAuthor := blog.AuthorID == userID
-	isAdmin := user.Role == domain.RoleAdmin
+	canDeleteAny := uc.can(user.Role, domain.PermissionCommentDeleteAny)
 
-	if !isCommentAuthor && !isBlogAuthor && !isAdmin {
+	if !isCommentAuthor && !isBlogAuthor && !canDeleteAny {
 		return errors.New("forbideen: you are not authorized to delete this comment")
 	}
 
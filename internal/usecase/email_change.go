@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	"Blog-API/internal/domain"
+	"errors"
+	"log"
+)
+
+var errInvalidEmailChangeLink = errors.New("invalid or expired email change link")
+
+// records newEmail as pending and emails a confirmation link to it, plus a
+// notice with a cancel link to the current address. Email itself only changes
+// in ConfirmEmailChange, so a stolen session can't quietly take over the account.
+func (u *UserUseCase) requestEmailChange(user *domain.User, newEmail string) error {
+	confirmToken, err := u.issueToken(&domain.OneTimeToken{
+		UserID:  user.ID,
+		Purpose: domain.TokenPurposeEmailChange,
+		Email:   newEmail,
+	}, emailChangeTokenExpiry)
+	if err != nil {
+		return err
+	}
+	cancelToken, err := u.issueToken(&domain.OneTimeToken{
+		UserID:  user.ID,
+		Purpose: domain.TokenPurposeEmailChangeCancel,
+		Email:   newEmail,
+	}, emailChangeTokenExpiry)
+	if err != nil {
+		return err
+	}
+
+	if err := u.userRepo.UpdateProfile(user.ID, map[string]interface{}{"pending_email": newEmail}); err != nil {
+		return err
+	}
+
+	// the old address hears about it first: without the notice the owner has
+	// no way to stop the change, so no confirmation goes out either
+	if err := u.workerPool.TrySubmit(&EmailJob{
+		EmailService: u.emailService,
+		Secrets:      u.secrets,
+		Type:         "email_change_notice",
+		Email:        user.Email,
+		Username:     user.Username,
+		Token:        cancelToken,
+		NewEmail:     newEmail,
+	}); err != nil {
+		u.abandonEmailChange(user)
+		return err
+	}
+	if err := u.workerPool.TrySubmit(&EmailJob{
+		EmailService: u.emailService,
+		Secrets:      u.secrets,
+		Type:         "email_change",
+		Email:        newEmail,
+		Username:     user.Username,
+		Token:        confirmToken,
+	}); err != nil {
+		u.abandonEmailChange(user)
+		return err
+	}
+	return nil
+}
+
+// undoes requestEmailChange when its emails couldn't be queued
+func (u *UserUseCase) abandonEmailChange(user *domain.User) {
+	if err := u.tokenRepo.DeleteByUserID(user.ID, domain.TokenPurposeEmailChange, domain.TokenPurposeEmailChangeCancel); err != nil {
+		log.Printf("Failed to delete email change tokens for user %s: %v", user.ID.Hex(), err)
+	}
+	if err := u.userRepo.UpdateProfile(user.ID, map[string]interface{}{"pending_email": ""}); err != nil {
+		log.Printf("Failed to clear pending email for user %s: %v", user.ID.Hex(), err)
+	}
+}
+
+// applies a pending email change. Following the link proves the user owns the
+// new address, so it is marked verified.
+func (u *UserUseCase) ConfirmEmailChange(token string) (*domain.User, error) {
+	record, err := u.tokenRepo.GetValid(domain.TokenPurposeEmailChange, hashToken(token))
+	if err != nil {
+		return nil, errInvalidEmailChangeLink
+	}
+	user, err := u.userRepo.GetByID(record.UserID)
+	if err != nil {
+		return nil, err
+	}
+	// a newer request or a cancellation replaced this one
+	if user.PendingEmail != record.Email {
+		return nil, errInvalidEmailChangeLink
+	}
+	if existingUser, _ := u.userRepo.GetByEmail(record.Email); existingUser != nil && existingUser.ID != user.ID {
+		return nil, errors.New("email already exists")
+	}
+
+	used, err := u.tokenRepo.MarkUsed(record.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, errInvalidEmailChangeLink
+	}
+
+	updates := map[string]interface{}{
+		"email":          record.Email,
+		"email_verified": true,
+		"pending_email":  "",
+	}
+	if err := u.userRepo.UpdateProfile(user.ID, updates); err != nil {
+		return nil, err
+	}
+	if err := u.tokenRepo.DeleteByUserID(user.ID, domain.TokenPurposeEmailChangeCancel); err != nil {
+		log.Printf("Failed to delete email change cancel token for user %s: %v", user.ID.Hex(), err)
+	}
+	return u.userRepo.GetByID(user.ID)
+}
+
+// stops a pending email change from the link sent to the old address
+func (u *UserUseCase) CancelEmailChange(token string) error {
+	record, err := u.tokenRepo.GetValid(domain.TokenPurposeEmailChangeCancel, hashToken(token))
+	if err != nil {
+		return errInvalidEmailChangeLink
+	}
+	used, err := u.tokenRepo.MarkUsed(record.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return errInvalidEmailChangeLink
+	}
+
+	if err := u.tokenRepo.DeleteByUserID(record.UserID, domain.TokenPurposeEmailChange); err != nil {
+		return err
+	}
+	if err := u.userRepo.UpdateProfile(record.UserID, map[string]interface{}{"pending_email": ""}); err != nil {
+		return err
+	}
+
+	// the owner didn't ask for it, so someone else may have access to the account
+	event := &domain.SecurityEvent{
+		UserID:  record.UserID,
+		Type:    domain.SecurityEventEmailChangeCancel,
+		Details: "change to " + record.Email + " cancelled from the old address",
+	}
+	if err := u.securityEvents.Create(event); err != nil {
+		log.Printf("Failed to record security event: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"Blog-API/internal/domain"
+	"Blog-API/internal/infrastructure/password"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRequestEmailChange(t *testing.T) {
+	tests := []struct {
+		name        string
+		failAt      int // which submit finds the queue full; 0 for none
+		wantErr     error
+		wantQueued  []string // email job types, in order
+		wantPending string
+		wantTokens  int
+	}{
+		{"both emails queued", 0, nil, []string{"email_change_notice", "email_change"}, "new@example.com", 2},
+		{"notice can't be queued", 1, domain.ErrQueueFull, nil, "", 0},
+		{"confirmation can't be queued", 2, domain.ErrQueueFull, []string{"email_change_notice"}, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &domain.User{ID: primitive.NewObjectID(), Username: "jane", Email: "old@example.com"}
+			users := newFakeUserRepo(user)
+			tokens := &fakeTokenRepo{}
+			pool := &fakeWorkerPool{failAt: tt.failAt}
+			u := &UserUseCase{
+				userRepo:        users,
+				tokenRepo:       tokens,
+				workerPool:      pool,
+				passwordService: password.NewPasswordService(password.Argon2Params{}, password.Policy{}),
+			}
+
+			err := u.requestEmailChange(user, "new@example.com")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("requestEmailChange() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var queued []string
+			for _, job := range pool.queued {
+				queued = append(queued, job.(*EmailJob).Type)
+			}
+			if len(queued) != len(tt.wantQueued) {
+				t.Fatalf("queued %v, want %v", queued, tt.wantQueued)
+			}
+			for i := range queued {
+				if queued[i] != tt.wantQueued[i] {
+					t.Fatalf("queued %v, want %v", queued, tt.wantQueued)
+				}
+			}
+
+			stored, _ := users.GetByID(user.ID)
+			if stored.PendingEmail != tt.wantPending {
+				t.Errorf("pending email = %q, want %q", stored.PendingEmail, tt.wantPending)
+			}
+			if len(tokens.tokens) != tt.wantTokens {
+				t.Errorf("%d tokens left, want %d", len(tokens.tokens), tt.wantTokens)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package usecase
+
+import (
+	"errors"
+	"sync"
+
+	"Blog-API/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The fakes embed the interface they stand in for, so a call to a method a
+// test didn't expect panics instead of silently doing nothing.
+
+type fakeUserRepo struct {
+	domain.UserRepository
+	mu    sync.Mutex
+	users map[primitive.ObjectID]*domain.User
+}
+
+func newFakeUserRepo(users ...*domain.User) *fakeUserRepo {
+	r := &fakeUserRepo{users: make(map[primitive.ObjectID]*domain.User)}
+	for _, user := range users {
+		r.users[user.ID] = user
+	}
+	return r
+}
+
+func (r *fakeUserRepo) GetByID(id primitive.ObjectID) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user, ok := r.users[id]; ok {
+		copied := *user
+		return &copied, nil
+	}
+	return nil, errors.New("user not found")
+}
+
+func (r *fakeUserRepo) GetByEmail(email string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("user not found")
+}
+
+func (r *fakeUserRepo) GetByOAuth(provider, subject string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		for _, identity := range user.LinkedIdentities {
+			if identity.Provider == provider && identity.Subject == subject {
+				copied := *user
+				return &copied, nil
+			}
+		}
+	}
+	return nil, errors.New("user not found")
+}
+
+func (r *fakeUserRepo) Create(user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
+
+func (r *fakeUserRepo) UpdateProfile(id primitive.ObjectID, updates map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+	if pending, ok := updates["pending_email"].(string); ok {
+		user.PendingEmail = pending
+	}
+	return nil
+}
+
+type fakeTokenRepo struct {
+	domain.OneTimeTokenRepository
+	mu     sync.Mutex
+	tokens []*domain.OneTimeToken
+}
+
+func (r *fakeTokenRepo) Create(token *domain.OneTimeToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = primitive.NewObjectID()
+	r.tokens = append(r.tokens, token)
+	return nil
+}
+
+func (r *fakeTokenRepo) DeleteByUserID(userID primitive.ObjectID, purposes ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.tokens[:0]
+	for _, token := range r.tokens {
+		deleted := token.UserID == userID && len(purposes) == 0
+		for _, purpose := range purposes {
+			deleted = deleted || (token.UserID == userID && token.Purpose == purpose)
+		}
+		if !deleted {
+			kept = append(kept, token)
+		}
+	}
+	r.tokens = kept
+	return nil
+}
+
+// queues jobs in memory; the submit numbered failAt (1-based) gets ErrQueueFull
+type fakeWorkerPool struct {
+	domain.WorkerPool
+	failAt    int
+	submitted int
+	queued    []domain.Job
+}
+
+func (p *fakeWorkerPool) TrySubmit(job domain.Job) error {
+	p.submitted++
+	if p.submitted == p.failAt {
+		return domain.ErrQueueFull
+	}
+	p.queued = append(p.queued, job)
+	return nil
+}
+
+type fakeJWTService struct {
+	domain.JWTService
+	claims map[string]*domain.JWTClaims // token -> claims
+}
+
+func (s *fakeJWTService) ValidateToken(token string) (*domain.JWTClaims, error) {
+	if claims, ok := s.claims[token]; ok {
+		return claims, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+func (s *fakeJWTService) ConsumeToken(claims *domain.JWTClaims) error { return nil }
+
+type fakeOAuthService struct {
+	domain.OAuthService
+	info *domain.OAuthUserInfo
+}
+
+func (s *fakeOAuthService) Exchange(provider, code string, flow *domain.OAuthFlow) (*domain.OAuthUserInfo, error) {
+	return s.info, nil
+}
+
+type fakeLoginHistory struct {
+	domain.LoginHistoryRepository
+	mu     sync.Mutex
+	events []*domain.LoginEvent
+}
+
+func (r *fakeLoginHistory) Create(event *domain.LoginEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *fakeLoginHistory) HasSuccessfulLogin(userID primitive.ObjectID, fingerprint string) (bool, error) {
+	return false, nil
+}
+
+// records entries without a database
+type fakeAuditLog struct {
+	domain.AuditLog
+}
+
+func (fakeAuditLog) Record(entry *domain.AuditEntry, err error) {}
@@ -0,0 +1,156 @@
+package usecase
+
+import (
+	"Blog-API/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// how long after logging in or re-authenticating a session may change login methods
+const reauthWindow = 10 * time.Minute
+
+var errReauthRequired = errors.New("recent authentication required, please confirm your password or two-factor code")
+
+// checks the password, plus a two-factor code if the account has one, and
+// marks the session as freshly authenticated. Wrong passwords count towards
+// the login lockout.
+func (u *UserUseCase) Reauthenticate(claims *domain.JWTClaims, req *domain.ReauthenticateRequest, client domain.ClientInfo) error {
+	user, err := u.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		return err
+	}
+
+	if user.Password != "" {
+		if req.Password == "" {
+			return errors.New("password is required")
+		}
+		ctx := context.Background()
+		wait, _, err := u.loginThrottler.Check(ctx, user.Email, client.IPAddress)
+		if err != nil {
+			return err
+		}
+		if wait > 0 {
+			return &domain.RateLimitError{RetryAfter: wait, Reason: "too many failed login attempts"}
+		}
+		if !u.passwordService.CheckPassword(req.Password, user.Password) {
+			u.loginFailed(ctx, user, user.Email, domain.LoginMethodReauthenticate, client)
+			return errors.New("invalid password")
+		}
+		if err := u.loginThrottler.Reset(ctx, user.Email); err != nil {
+			log.Printf("Failed to reset login failures: %v", err)
+		}
+	} else if identity := user.Identity(ldapProvider); identity != nil && u.directory != nil {
+		if req.Password == "" {
+			return errors.New("password is required")
+		}
+		entry, err := u.directory.Authenticate(user.Username, req.Password)
+		if err == domain.ErrDirectoryCredentials || (err == nil && entry.Subject != identity.Subject) {
+			return errors.New("invalid password")
+		}
+		if err != nil {
+			return err
+		}
+	} else if !user.TwoFactorEnabled() {
+		// a fresh OAuth login counts as re-authenticating
+		return errors.New("this account has no password, please log in again with a linked account")
+	}
+
+	if user.TwoFactorEnabled() {
+		if req.Code == "" {
+			return errors.New("two-factor code is required")
+		}
+		if err := u.verifySecondFactor(user, req.Code); err != nil {
+			return err
+		}
+	}
+
+	return u.sessionRepo.MarkAuthenticated(claims.SessionID)
+}
+
+// fails unless the caller's session logged in or re-authenticated within reauthWindow
+func (u *UserUseCase) requireRecentAuth(claims *domain.JWTClaims) error {
+	session, err := u.sessionRepo.GetByID(claims.SessionID)
+	if err != nil || session.UserID != claims.UserID {
+		return errors.New("session not found")
+	}
+	if time.Since(session.AuthenticatedAt) > reauthWindow {
+		return errReauthRequired
+	}
+	return nil
+}
+
+func (u *UserUseCase) GetLoginMethods(userID primitive.ObjectID) (*domain.LoginMethods, error) {
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	identities := user.LinkedIdentities
+	if identities == nil {
+		identities = []domain.LinkedIdentity{}
+	}
+	return &domain.LoginMethods{Password: user.Password != "", Identities: identities}, nil
+}
+
+// starts an OAuth flow whose callback links the provider account to the
+// caller instead of logging in
+func (u *UserUseCase) BeginIdentityLink(claims *domain.JWTClaims, provider, returnTo string) (string, *domain.OAuthFlow, error) {
+	if err := u.requireRecentAuth(claims); err != nil {
+		return "", nil, err
+	}
+	user, err := u.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		return "", nil, err
+	}
+	if user.Identity(provider) != nil {
+		return "", nil, fmt.Errorf("a %s account is already linked", provider)
+	}
+	return u.startOAuthFlow(provider, returnTo, user.ID.Hex())
+}
+
+// completes the OAuth flow started by BeginIdentityLink
+func (u *UserUseCase) LinkIdentity(provider, code string, userID primitive.ObjectID, flow *domain.OAuthFlow) (*domain.User, error) {
+	info, err := u.oauthService.Exchange(provider, code, flow)
+	if err != nil {
+		return nil, err
+	}
+
+	if owner, _ := u.userRepo.GetByOAuth(provider, info.Subject); owner != nil {
+		if owner.ID == userID {
+			return nil, fmt.Errorf("a %s account is already linked", provider)
+		}
+		return nil, fmt.Errorf("this %s account is already linked to another user", provider)
+	}
+	identity := &domain.LinkedIdentity{
+		Provider: provider,
+		Subject:  info.Subject,
+		Email:    info.Email,
+		LinkedAt: time.Now(),
+	}
+	if err := u.userRepo.AddLinkedIdentity(userID, identity); err != nil {
+		return nil, err
+	}
+	return u.userRepo.GetByID(userID)
+}
+
+// removes a linked identity, as long as the user can still log in some other way
+func (u *UserUseCase) UnlinkIdentity(claims *domain.JWTClaims, provider string) error {
+	if err := u.requireRecentAuth(claims); err != nil {
+		return err
+	}
+	user, err := u.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		return err
+	}
+	if user.Identity(provider) == nil {
+		return fmt.Errorf("no %s account is linked", provider)
+	}
+	if user.LoginMethodCount() <= 1 {
+		return errors.New("cannot remove the only way to log in, set a password or link another account first")
+	}
+	return u.userRepo.RemoveLinkedIdentity(user.ID, provider)
+}
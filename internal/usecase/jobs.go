@@ -3,21 +3,109 @@ package usecase
 import (
 	"Blog-API/internal/domain"
 	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
 )
 
+const EmailJobName = "email"
+
+// the one-time token is only stored sealed, so it can't be read from the
+// queue or the dead-letter list
 type EmailJob struct {
-	EmailService           domain.EmailService
-	Type                   string
-	Email, Username, Token string
+	EmailService domain.EmailService `json:"-"`
+	Secrets      domain.SecretSealer `json:"-"`
+	Type         string              `json:"type"`
+	Email        string              `json:"email"`
+	Username     string              `json:"username"`
+	Token        string              `json:"-"`
+	SealedToken  string              `json:"sealed_token,omitempty"`
+	LockedUntil  time.Time           `json:"locked_until,omitempty"`
+	NewEmail     string              `json:"new_email,omitempty"`
+	Device       string              `json:"device,omitempty"`
+	IPAddress    string              `json:"ip_address,omitempty"`
+	LoginAt      time.Time           `json:"login_at,omitempty"`
+}
+
+func (j *EmailJob) JobName() string {
+	return EmailJobName
+}
+
+// someone is usually waiting on these emails to log in
+func (j *EmailJob) Priority() domain.JobPriority {
+	switch j.Type {
+	case "verification", "password_reset", "magic_link", "account_locked", "email_change", "email_change_notice", "new_device":
+		return domain.PriorityHigh
+	}
+	return domain.PriorityNormal
+}
+
+func (j *EmailJob) MarshalJSON() ([]byte, error) {
+	type plain EmailJob
+	if j.Token != "" && j.SealedToken == "" {
+		if j.Secrets == nil {
+			return nil, errors.New("email job has a token but nothing to seal it with")
+		}
+		sealed, err := j.Secrets.Seal(j.Token)
+		if err != nil {
+			return nil, err
+		}
+		j.SealedToken = sealed
+	}
+	return json.Marshal((*plain)(j))
 }
 
 func (j *EmailJob) Run(ctx context.Context) error {
+	if j.Token == "" && j.SealedToken != "" {
+		token, err := j.Secrets.Open(j.SealedToken)
+		if err != nil {
+			return err
+		}
+		j.Token = token
+	}
 	switch j.Type {
 	case "verification":
 		return j.EmailService.SendVerificationEmail(j.Email, j.Username, j.Token)
 	case "password_reset":
 		return j.EmailService.SendPasswordResetEmail(j.Email, j.Username, j.Token)
+	case "magic_link":
+		return j.EmailService.SendMagicLinkEmail(j.Email, j.Username, j.Token)
+	case "account_locked":
+		return j.EmailService.SendAccountLockedEmail(j.Email, j.Username, j.LockedUntil)
+	case "email_change":
+		return j.EmailService.SendEmailChangeConfirmation(j.Email, j.Username, j.Token)
+	case "email_change_notice":
+		return j.EmailService.SendEmailChangeNotice(j.Email, j.Username, j.NewEmail, j.Token)
+	case "new_device":
+		return j.EmailService.SendNewDeviceAlert(j.Email, j.Username, j.Device, j.IPAddress, j.LoginAt, j.Token)
 	}
 	return nil
 
 }
+
+// removes login sessions whose refresh token has expired
+type SessionCleanupJob struct {
+	SessionUseCase domain.SessionUseCase
+}
+
+func (j *SessionCleanupJob) Run(ctx context.Context) error {
+	return j.SessionUseCase.CleanupExpiredSessions()
+}
+
+// removes dead-letter jobs older than the retention period
+type DeadLetterCleanupJob struct {
+	Queue     domain.JobQueue
+	Retention time.Duration
+}
+
+func (j *DeadLetterCleanupJob) Run(ctx context.Context) error {
+	removed, err := j.Queue.PurgeDeadLetters(ctx, time.Now().Add(-j.Retention))
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		log.Printf("Removed %d dead-letter jobs older than %s", removed, j.Retention)
+	}
+	return nil
+}
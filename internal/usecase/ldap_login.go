@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"Blog-API/internal/domain"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// provider name of identities that log in through the directory
+const ldapProvider = "ldap"
+
+// logs in with directory credentials. The user is created on their first
+// login, and their role follows their directory groups on every login.
+func (u *UserUseCase) LDAPLogin(username, password string, client domain.ClientInfo) (*domain.LoginResponse, error) {
+	if u.directory == nil {
+		return nil, errors.New("directory login is not enabled")
+	}
+
+	// failures are counted per directory username, like emails for password logins
+	ctx := context.Background()
+	throttleKey := ldapProvider + ":" + strings.ToLower(username)
+	wait, locked, err := u.loginThrottler.Check(ctx, throttleKey, client.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		return nil, &domain.RateLimitError{RetryAfter: wait, Reason: "account is temporarily locked after too many failed login attempts"}
+	}
+	if wait > 0 {
+		return nil, &domain.RateLimitError{RetryAfter: wait, Reason: "too many failed login attempts"}
+	}
+
+	entry, err := u.directory.Authenticate(username, password)
+	if err == domain.ErrDirectoryCredentials {
+		u.loginFailed(ctx, nil, throttleKey, domain.LoginMethodLDAP, client)
+		return nil, errors.New("invalid username or password")
+	}
+	if err != nil {
+		log.Printf("Directory login failed for %s: %v", username, err)
+		return nil, err
+	}
+	if err := u.loginThrottler.Reset(ctx, throttleKey); err != nil {
+		log.Printf("Failed to reset login failures: %v", err)
+	}
+
+	user, err := u.directoryUser(entry)
+	if err != nil {
+		return nil, err
+	}
+	if restriction := user.ActiveRestriction(); restriction != nil {
+		u.recordLogin(user, domain.LoginMethodLDAP, client, restriction)
+		return nil, restriction
+	}
+	return u.completeLogin(user, domain.LoginMethodLDAP, client)
+}
+
+// finds the user linked to the directory entry, creating them on first login
+func (u *UserUseCase) directoryUser(entry *domain.DirectoryUser) (*domain.User, error) {
+	user, err := u.userRepo.GetByOAuth(ldapProvider, entry.Subject)
+	if err != nil && err.Error() != "user not found" {
+		return nil, err
+	}
+
+	if user == nil {
+		// same rule as OAuth: an existing account is never taken over by email
+		if existing, _ := u.userRepo.GetByEmail(entry.Email); existing != nil {
+			return nil, errors.New("user with this email already exists")
+		}
+		if existing, _ := u.userRepo.GetByUsername(entry.Username); existing != nil {
+			return nil, errors.New("user with this username already exists")
+		}
+		user = &domain.User{
+			Username:      entry.Username,
+			Email:         entry.Email,
+			EmailVerified: true,
+			Role:          entry.Role,
+			LinkedIdentities: []domain.LinkedIdentity{
+				{Provider: ldapProvider, Subject: entry.Subject, Email: entry.Email, LinkedAt: time.Now()},
+			},
+		}
+		if err := u.userRepo.Create(user); err != nil {
+			return nil, err
+		}
+		log.Printf("Provisioned user %s from the directory", user.ID.Hex())
+		return user, nil
+	}
+
+	if user.Role != entry.Role {
+		// also bumps the token version, so tokens with the old role stop working
+		if err := u.userRepo.UpdateRole(user.ID, entry.Role); err != nil {
+			return nil, err
+		}
+		log.Printf("Changed role of user %s to %s to match their directory groups", user.ID.Hex(), entry.Role)
+		return u.userRepo.GetByID(user.ID)
+	}
+	return user, nil
+}
@@ -0,0 +1,211 @@
+package usecase
+
+import (
+	"Blog-API/internal/domain"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// how long the "this wasn't me" link in a new-device alert works
+const secureAccountTokenExpiry = 7 * 24 * time.Hour
+
+var errInvalidSecureAccountLink = errors.New("invalid or expired link")
+
+// adds the attempt to the user's login history. A successful login from a
+// device and network the user hasn't logged in from before sends them an
+// alert; their very first login doesn't.
+func (u *UserUseCase) recordLogin(user *domain.User, method string, client domain.ClientInfo, loginErr error) {
+	device := describeDevice(client.UserAgent)
+	event := &domain.LoginEvent{
+		UserID:            user.ID,
+		Method:            method,
+		Success:           loginErr == nil,
+		IPAddress:         client.IPAddress,
+		UserAgent:         client.UserAgent,
+		Device:            device,
+		DeviceFingerprint: deviceFingerprint(device, client.IPAddress),
+	}
+	if loginErr != nil {
+		event.FailureReason = loginErr.Error()
+	}
+
+	if event.Success && method != domain.LoginMethodRefresh {
+		known, err := u.loginHistory.HasSuccessfulLogin(user.ID, event.DeviceFingerprint)
+		if err != nil {
+			log.Printf("Failed to check known devices of user %s: %v", user.ID.Hex(), err)
+		} else if !known {
+			seenBefore, err := u.loginHistory.HasSuccessfulLogin(user.ID, "")
+			if err != nil {
+				log.Printf("Failed to check login history of user %s: %v", user.ID.Hex(), err)
+			}
+			event.NewDevice = seenBefore
+		}
+	}
+
+	if err := u.loginHistory.Create(event); err != nil {
+		log.Printf("Failed to record login of user %s: %v", user.ID.Hex(), err)
+	}
+	if event.NewDevice {
+		u.alertNewDevice(user, event)
+	}
+}
+
+func (u *UserUseCase) alertNewDevice(user *domain.User, event *domain.LoginEvent) {
+	token, err := u.issueToken(&domain.OneTimeToken{UserID: user.ID, Purpose: domain.TokenPurposeSecureAccount}, secureAccountTokenExpiry)
+	if err != nil {
+		log.Printf("Failed to issue secure account token for user %s: %v", user.ID.Hex(), err)
+		return
+	}
+	if err := u.workerPool.TrySubmit(&EmailJob{
+		EmailService: u.emailService,
+		Secrets:      u.secrets,
+		Type:         "new_device",
+		Email:        user.Email,
+		Username:     user.Username,
+		Token:        token,
+		Device:       event.Device,
+		IPAddress:    event.IPAddress,
+		LoginAt:      event.CreatedAt,
+	}); err != nil {
+		log.Printf("Failed to queue new device alert for user %s: %v", user.ID.Hex(), err)
+	}
+}
+
+func (u *UserUseCase) ListLoginHistory(userID primitive.ObjectID, page, limit int) ([]*domain.LoginEvent, int64, error) {
+	return u.loginHistory.ListByUserID(userID, page, limit)
+}
+
+// the "this wasn't me" link from a new-device alert: signs the user out
+// everywhere, revokes their access tokens and makes them reset their password
+func (u *UserUseCase) SecureAccount(token string, client domain.ClientInfo) (err error) {
+	record, err := u.tokenRepo.GetValid(domain.TokenPurposeSecureAccount, hashToken(token))
+	if err != nil {
+		return errInvalidSecureAccountLink
+	}
+	used, err := u.tokenRepo.MarkUsed(record.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return errInvalidSecureAccountLink
+	}
+
+	entry := auditEntry(domain.Actor{UserID: record.UserID, Client: client}, domain.AuditAccountSecure, domain.AuditTargetUser, record.UserID.Hex())
+	defer func() { u.audit.Record(entry, err) }()
+
+	user, err := u.userRepo.GetByID(record.UserID)
+	if err != nil {
+		return err
+	}
+	entry.ActorRole = user.Role
+
+	if err := u.sessionRepo.DeleteByUserID(user.ID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	if err := u.accessTokens.DeleteByUserID(user.ID); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+	// access tokens already handed out stop working too
+	if err := u.userRepo.IncrementTokenVersion(user.ID); err != nil {
+		return err
+	}
+
+	event := &domain.SecurityEvent{
+		UserID:    user.ID,
+		Type:      domain.SecurityEventAccountSecured,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Details:   "a login was reported as not made by the owner",
+	}
+	if err := u.securityEvents.Create(event); err != nil {
+		log.Printf("Failed to record security event: %v", err)
+	}
+
+	// accounts without a password have nothing to reset
+	if user.Password == "" {
+		return nil
+	}
+	if err := u.userRepo.UpdateProfile(user.ID, map[string]interface{}{"password_reset_required": true}); err != nil {
+		return err
+	}
+	entry.After = map[string]string{"password_reset_required": "true"}
+	resetToken, err := u.issueToken(&domain.OneTimeToken{UserID: user.ID, Purpose: domain.TokenPurposePasswordReset}, passwordResetTokenExpiry)
+	if err != nil {
+		return err
+	}
+	return u.workerPool.TrySubmit(&EmailJob{
+		EmailService: u.emailService,
+		Secrets:      u.secrets,
+		Type:         "password_reset",
+		Email:        user.Email,
+		Username:     user.Username,
+		Token:        resetToken,
+	})
+}
+
+// "Firefox on Windows". Versions are left out on purpose, so a browser
+// update doesn't look like a new device.
+func describeDevice(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+	ua := strings.ToLower(userAgent)
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "edg/") || strings.Contains(ua, "edga/") || strings.Contains(ua, "edgios/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/") || strings.Contains(ua, "fxios/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	case strings.Contains(ua, "postman"):
+		browser = "Postman"
+	}
+
+	platform := "unknown OS"
+	switch {
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		platform = "iOS"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "mac os x") || strings.Contains(ua, "macintosh"):
+		platform = "macOS"
+	case strings.Contains(ua, "cros"):
+		platform = "ChromeOS"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+	return browser + " on " + platform
+}
+
+// hash of the device and its network (/16 for IPv4, /32 for IPv6), so a new
+// address from the same provider isn't reported
+func deviceFingerprint(device, ipAddress string) string {
+	network := ""
+	if ip := net.ParseIP(ipAddress); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			network = ip4.Mask(net.CIDRMask(16, 32)).String()
+		} else {
+			network = ip.Mask(net.CIDRMask(32, 128)).String()
+		}
+	}
+	sum := sha256.Sum256([]byte(device + "|" + network))
+	return hex.EncodeToString(sum[:8])
+}
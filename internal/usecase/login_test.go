@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"Blog-API/internal/domain"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// after "this wasn't me" no login method may hand out tokens until the
+// password has been reset
+func TestLoginRefusedWhilePasswordResetRequired(t *testing.T) {
+	user := &domain.User{
+		ID:                    primitive.NewObjectID(),
+		Username:              "jane",
+		Email:                 "jane@example.com",
+		EmailVerified:         true,
+		Role:                  domain.RoleUser,
+		TokenVersion:          3,
+		PasswordResetRequired: true,
+		LinkedIdentities: []domain.LinkedIdentity{
+			{Provider: "google", Subject: "google-123", Email: "jane@example.com"},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		login func(u *UserUseCase) (*domain.LoginResponse, error)
+	}{
+		{"magic link", func(u *UserUseCase) (*domain.LoginResponse, error) {
+			return u.MagicLinkLogin("magic-token", domain.ClientInfo{IPAddress: "203.0.113.7"})
+		}},
+		{"oauth", func(u *UserUseCase) (*domain.LoginResponse, error) {
+			return u.OAuthLogin("google", "code", &domain.OAuthFlow{}, domain.ClientInfo{IPAddress: "203.0.113.7"})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := &fakeLoginHistory{}
+			// no session repository or token generation: reaching either panics
+			u := &UserUseCase{
+				userRepo: newFakeUserRepo(user),
+				jwtService: &fakeJWTService{claims: map[string]*domain.JWTClaims{
+					"magic-token": {TokenType: domain.TokenTypeMagicLink, UserID: user.ID, Version: user.TokenVersion, Email: user.Email},
+				}},
+				oauthService: &fakeOAuthService{info: &domain.OAuthUserInfo{Subject: "google-123", Email: user.Email, Username: user.Username}},
+				loginHistory: history,
+				audit:        fakeAuditLog{},
+			}
+
+			resp, err := tt.login(u)
+			if !errors.Is(err, errPasswordResetRequired) {
+				t.Fatalf("login error = %v, want %v", err, errPasswordResetRequired)
+			}
+			if resp != nil {
+				t.Errorf("login returned tokens: %+v", resp)
+			}
+			if len(history.events) != 1 || history.events[0].Success {
+				t.Errorf("want one failed login recorded, got %+v", history.events)
+			}
+		})
+	}
+}
+
+func TestOAuthLoginRefusesUnverifiedEmailForNewAccounts(t *testing.T) {
+	users := newFakeUserRepo()
+	history := &fakeLoginHistory{}
+	u := &UserUseCase{
+		userRepo:     users,
+		oauthService: &fakeOAuthService{info: &domain.OAuthUserInfo{Subject: "42", Email: "jane@example.com", Username: "jane"}},
+		loginHistory: history,
+		audit:        fakeAuditLog{},
+	}
+
+	if _, err := u.OAuthLogin("github", "code", &domain.OAuthFlow{}, domain.ClientInfo{}); err == nil {
+		t.Fatal("OAuthLogin() accepted an unverified email")
+	}
+	if len(users.users) != 0 {
+		t.Errorf("OAuthLogin() created an account for an unverified email")
+	}
+}
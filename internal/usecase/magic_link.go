@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"Blog-API/internal/domain"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// how many login links may be requested, per address and per client IP
+const (
+	magicLinkEmailLimit = 3
+	magicLinkIPLimit    = 10
+	magicLinkWindow     = 15 * time.Minute
+)
+
+var errInvalidMagicLink = errors.New("invalid or expired login link")
+
+// emails a single-use login link. Like SendPasswordResetEmail it gives no hint
+// whether the address belongs to an account; limits apply either way.
+func (u *UserUseCase) SendMagicLink(email string, client domain.ClientInfo) error {
+	ctx := context.Background()
+	email = strings.TrimSpace(email)
+	if err := u.allow(ctx, "magic_link:email:"+strings.ToLower(email), magicLinkEmailLimit, magicLinkWindow); err != nil {
+		return err
+	}
+	if err := u.allow(ctx, "magic_link:ip:"+client.IPAddress, magicLinkIPLimit, magicLinkWindow); err != nil {
+		return err
+	}
+
+	user, err := u.userRepo.GetByEmail(email)
+	if err != nil {
+		return nil
+	}
+	if user.ActiveRestriction() != nil {
+		log.Printf("Not sending login link to restricted user %s", user.ID.Hex())
+		return nil
+	}
+
+	token, err := u.jwtService.GenerateMagicLinkToken(user)
+	if err != nil {
+		return err
+	}
+	// same answer as an unknown address when the queue is full
+	if err := u.workerPool.TrySubmit(&EmailJob{
+		EmailService: u.emailService,
+		Secrets:      u.secrets,
+		Type:         "magic_link",
+		Email:        user.Email,
+		Username:     user.Username,
+		Token:        token,
+	}); err != nil {
+		log.Printf("Failed to queue login link for user %s: %v", user.ID.Hex(), err)
+	}
+	return nil
+}
+
+// completes a passwordless login. Opening the link proves the user owns the
+// address, so an unverified email is marked verified.
+func (u *UserUseCase) MagicLinkLogin(token string, client domain.ClientInfo) (*domain.LoginResponse, error) {
+	claims, err := u.jwtService.ValidateToken(token)
+	if err != nil || claims.TokenType != domain.TokenTypeMagicLink {
+		return nil, errInvalidMagicLink
+	}
+
+	user, err := u.userRepo.GetByID(claims.UserID)
+	if err != nil || claims.Version != user.TokenVersion || claims.Email != user.Email {
+		return nil, errInvalidMagicLink
+	}
+	if restriction := user.ActiveRestriction(); restriction != nil {
+		u.recordLogin(user, domain.LoginMethodMagicLink, client, restriction)
+		return nil, restriction
+	}
+
+	if err := u.jwtService.ConsumeToken(claims); err != nil {
+		return nil, errInvalidMagicLink
+	}
+
+	if !user.EmailVerified {
+		if err := u.userRepo.UpdateEmailVerificationStatus(user.ID, true); err != nil {
+			log.Printf("Failed to mark email verified for user %s: %v", user.ID.Hex(), err)
+		} else {
+			user.EmailVerified = true
+		}
+	}
+	return u.completeLogin(user, domain.LoginMethodMagicLink, client)
+}
+
+// counts a request against key and returns a RateLimitError once it's over the limit
+func (u *UserUseCase) allow(ctx context.Context, key string, limit int, window time.Duration) error {
+	allowed, retryAfter, err := u.rateLimiter.Allow(ctx, key, limit, window)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &domain.RateLimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
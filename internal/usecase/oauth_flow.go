@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"Blog-API/internal/domain"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"time"
+)
+
+// how long a frontend has to redeem the code it was redirected with
+const loginHandoffExpiry = time.Minute
+
+var errInvalidOAuthState = errors.New("invalid oauth state")
+
+func (u *UserUseCase) StartOAuthLogin(provider, returnTo string) (string, *domain.OAuthFlow, error) {
+	return u.startOAuthFlow(provider, returnTo, "")
+}
+
+func (u *UserUseCase) startOAuthFlow(provider, returnTo, linkUserID string) (string, *domain.OAuthFlow, error) {
+	_, flow, err := u.oauthState.Issue(provider, returnTo, linkUserID)
+	if err != nil {
+		return "", nil, err
+	}
+	authURL, err := u.oauthService.GetAuthURL(provider, flow)
+	if err != nil {
+		return "", nil, err
+	}
+	return authURL, flow, nil
+}
+
+// checks the state the provider sent back. browserNonce comes from the cookie
+// set when the login started, so a callback URL can't be replayed in another
+// browser (login CSRF).
+func (u *UserUseCase) VerifyOAuthState(provider, state, browserNonce string) (*domain.OAuthState, *domain.OAuthFlow, error) {
+	oauthState, flow, err := u.oauthState.Verify(state)
+	if err != nil {
+		return nil, nil, err
+	}
+	if oauthState.Provider != provider || browserNonce == "" ||
+		subtle.ConstantTimeCompare([]byte(browserNonce), []byte(oauthState.Nonce)) != 1 {
+		return nil, nil, errInvalidOAuthState
+	}
+	return oauthState, flow, nil
+}
+
+// keeps the tokens server-side for a minute so the redirect only has to carry
+// a random code, in the URL fragment, instead of the tokens themselves
+func (u *UserUseCase) CreateLoginHandoff(resp *domain.LoginResponse) (string, error) {
+	code := u.passwordService.GenerateSecureToken(32)
+	if err := u.cache.Set(context.Background(), loginHandoffKey(code), resp, loginHandoffExpiry); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+func (u *UserUseCase) RedeemLoginHandoff(code string) (*domain.LoginResponse, error) {
+	var resp domain.LoginResponse
+	if err := u.cache.Take(context.Background(), loginHandoffKey(code), &resp); err != nil {
+		return nil, errors.New("invalid or expired login code")
+	}
+	return &resp, nil
+}
+
+func loginHandoffKey(code string) string {
+	return "oauth:handoff:" + hashToken(code)
+}
@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"fmt"
+	"testing"
+
+	"Blog-API/internal/domain"
+	"Blog-API/internal/infrastructure/password"
+)
+
+func newHistoryTestUseCase(t *testing.T, historySize int) *UserUseCase {
+	t.Helper()
+	svc := password.NewPasswordService(
+		password.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1},
+		password.Policy{HistorySize: historySize},
+	)
+	return &UserUseCase{passwordService: svc}
+}
+
+// a user whose current password is passwords[0], followed by older ones
+func userWithPasswords(t *testing.T, u *UserUseCase, passwords ...string) *domain.User {
+	t.Helper()
+	user := &domain.User{}
+	for i, p := range passwords {
+		hash, err := u.passwordService.HashPassword(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			user.Password = hash
+		} else {
+			user.PasswordHistory = append(user.PasswordHistory, hash)
+		}
+	}
+	return user
+}
+
+func TestPasswordRecentlyUsed(t *testing.T) {
+	u := newHistoryTestUseCase(t, 3)
+	user := userWithPasswords(t, u, "current-pass", "older-pass", "oldest-pass")
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"current-pass", true},
+		{"older-pass", true},
+		{"oldest-pass", true},
+		{"brand-new-pass", false},
+	}
+	for _, tt := range tests {
+		if got := u.passwordRecentlyUsed(user, tt.password); got != tt.want {
+			t.Errorf("passwordRecentlyUsed(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+
+	// OAuth-only accounts have no current password
+	if u.passwordRecentlyUsed(&domain.User{}, "") {
+		t.Error("passwordRecentlyUsed() matched an empty password against an account without one")
+	}
+}
+
+func TestPasswordHistory(t *testing.T) {
+	tests := []struct {
+		name        string
+		historySize int
+		previous    int // passwords already in the history
+		noCurrent   bool
+		wantLen     int
+	}{
+		{"history disabled", 0, 2, false, 0},
+		{"first change", 3, 0, false, 1},
+		{"room left", 3, 1, false, 2},
+		{"full history drops the oldest", 3, 3, false, 3},
+		{"shrunk policy trims", 2, 5, false, 2},
+		{"no current password", 3, 1, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := newHistoryTestUseCase(t, tt.historySize)
+			user := &domain.User{Password: "current"}
+			if tt.noCurrent {
+				user.Password = ""
+			}
+			for i := 0; i < tt.previous; i++ {
+				user.PasswordHistory = append(user.PasswordHistory, fmt.Sprintf("old-%d", i))
+			}
+
+			history := u.passwordHistory(user)
+			if len(history) != tt.wantLen {
+				t.Fatalf("passwordHistory() kept %d hashes, want %d: %v", len(history), tt.wantLen, history)
+			}
+			if tt.wantLen == 0 {
+				return
+			}
+			want := "current"
+			if tt.noCurrent {
+				want = "old-0"
+			}
+			if history[0] != want {
+				t.Errorf("passwordHistory()[0] = %q, want %q", history[0], want)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package usecase
+
+import (
+	"Blog-API/internal/domain"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+type roleUseCase struct {
+	roleRepo domain.RoleRepository
+	userRepo domain.UserRepository
+	audit    domain.AuditLog
+}
+
+func NewRoleUseCase(roleRepo domain.RoleRepository, userRepo domain.UserRepository, audit domain.AuditLog) domain.RoleUseCase {
+	return &roleUseCase{
+		roleRepo: roleRepo,
+		userRepo: userRepo,
+		audit:    audit,
+	}
+}
+
+// built-in roles first, then custom ones by name
+func (uc *roleUseCase) ListRoles() ([]*domain.Role, error) {
+	custom, err := uc.roleRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]*domain.Role{}, domain.BuiltInRoles...), custom...), nil
+}
+
+func (uc *roleUseCase) GetRole(name string) (*domain.Role, error) {
+	if role := domain.BuiltInRole(name); role != nil {
+		return role, nil
+	}
+	return uc.roleRepo.GetByName(name)
+}
+
+func (uc *roleUseCase) HasPermission(roleName, permission string) (bool, error) {
+	role, err := uc.GetRole(roleName)
+	if err != nil {
+		if err.Error() == "role not found" {
+			return false, nil
+		}
+		return false, err
+	}
+	return role.HasPermission(permission), nil
+}
+
+func (uc *roleUseCase) IsPrivileged(roleName string) (bool, error) {
+	role, err := uc.GetRole(roleName)
+	if err != nil {
+		if err.Error() == "role not found" {
+			return false, nil
+		}
+		return false, err
+	}
+	return len(role.Permissions) > 0, nil
+}
+
+func (uc *roleUseCase) CanAssign(assignerRole, fromRole, toRole string) error {
+	assigner, err := uc.GetRole(assignerRole)
+	if err != nil {
+		return err
+	}
+	if !assigner.HasPermission(domain.PermissionRoleAssign) {
+		return errors.New("forbidden: you cannot assign roles")
+	}
+	to, err := uc.GetRole(toRole)
+	if err != nil {
+		return err
+	}
+	if missing := missingPermission(assigner, to.Permissions); missing != "" {
+		return fmt.Errorf("forbidden: you cannot assign the %s role, it has %s which you don't", to.Name, missing)
+	}
+	// the current role may since have been deleted, then there's nothing to protect
+	if from, err := uc.GetRole(fromRole); err == nil {
+		if missing := missingPermission(assigner, from.Permissions); missing != "" {
+			return fmt.Errorf("forbidden: you cannot change the role of a %s, it has %s which you don't", from.Name, missing)
+		}
+	}
+	return nil
+}
+
+func (uc *roleUseCase) CanManage(actorRole, targetRole string) error {
+	actor, err := uc.GetRole(actorRole)
+	if err != nil {
+		return err
+	}
+	// users left with a deleted role have nothing to protect
+	target, err := uc.GetRole(targetRole)
+	if err != nil {
+		if err.Error() == "role not found" {
+			return nil
+		}
+		return err
+	}
+	if missing := missingPermission(actor, target.Permissions); missing != "" {
+		return fmt.Errorf("forbidden: you cannot manage a %s, it has %s which you don't", target.Name, missing)
+	}
+	return nil
+}
+
+func (uc *roleUseCase) CreateRole(actor domain.Actor, req *domain.CreateRoleRequest) (created *domain.Role, err error) {
+	entry := auditEntry(actor, domain.AuditRoleCreate, domain.AuditTargetRole, req.Name)
+	entry.After = roleSummary(req.Description, req.Permissions)
+	defer func() { uc.audit.Record(entry, err) }()
+
+	if !roleNamePattern.MatchString(req.Name) {
+		return nil, errors.New("invalid role name, use lowercase letters, digits, - and _")
+	}
+	if domain.BuiltInRole(req.Name) != nil {
+		return nil, errors.New("role already exists")
+	}
+	permissions, err := uc.checkPermissions(actor.Role, req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	role := &domain.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: permissions,
+	}
+	if err := uc.roleRepo.Create(role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func (uc *roleUseCase) UpdateRole(actor domain.Actor, name string, req *domain.UpdateRoleDefinitionRequest) (updated *domain.Role, err error) {
+	entry := auditEntry(actor, domain.AuditRoleUpdate, domain.AuditTargetRole, name)
+	defer func() { uc.audit.Record(entry, err) }()
+
+	if domain.BuiltInRole(name) != nil {
+		return nil, errors.New("built-in roles cannot be changed")
+	}
+	role, err := uc.roleRepo.GetByName(name)
+	if err != nil {
+		return nil, err
+	}
+	entry.Before = roleSummary(role.Description, role.Permissions)
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if req.Permissions != nil {
+		// taking permissions away is limited the same way as granting them
+		if _, err := uc.checkPermissions(actor.Role, role.Permissions); err != nil {
+			return nil, err
+		}
+		permissions, err := uc.checkPermissions(actor.Role, req.Permissions)
+		if err != nil {
+			return nil, err
+		}
+		role.Permissions = permissions
+	}
+	entry.After = roleSummary(role.Description, role.Permissions)
+	if err := uc.roleRepo.Update(role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// roles that are still assigned can't be deleted
+func (uc *roleUseCase) DeleteRole(actor domain.Actor, name string) (err error) {
+	entry := auditEntry(actor, domain.AuditRoleDelete, domain.AuditTargetRole, name)
+	defer func() { uc.audit.Record(entry, err) }()
+
+	if domain.BuiltInRole(name) != nil {
+		return errors.New("built-in roles cannot be deleted")
+	}
+	role, err := uc.roleRepo.GetByName(name)
+	if err != nil {
+		return err
+	}
+	entry.Before = roleSummary(role.Description, role.Permissions)
+	_, assigned, err := uc.userRepo.List(domain.UserFilter{Role: name}, 1, 1)
+	if err != nil {
+		return err
+	}
+	if assigned > 0 {
+		return fmt.Errorf("role is assigned to %d users, give them another role first", assigned)
+	}
+	return uc.roleRepo.Delete(name)
+}
+
+// drops duplicates and fails on unknown permissions or ones the actor doesn't have
+func (uc *roleUseCase) checkPermissions(actorRole string, permissions []string) ([]string, error) {
+	actor, err := uc.GetRole(actorRole)
+	if err != nil {
+		return nil, err
+	}
+	checked := []string{}
+	seen := map[string]bool{}
+	for _, p := range permissions {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if !knownPermission(p) {
+			return nil, fmt.Errorf("invalid permission %q", p)
+		}
+		if !actor.HasPermission(p) {
+			return nil, fmt.Errorf("forbidden: you cannot grant %s, you don't have it", p)
+		}
+		checked = append(checked, p)
+	}
+	return checked, nil
+}
+
+func roleSummary(description string, permissions []string) map[string]string {
+	return map[string]string{
+		"description": description,
+		"permissions": strings.Join(permissions, ","),
+	}
+}
+
+func knownPermission(permission string) bool {
+	for _, p := range domain.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// the first of permissions the role doesn't have, or ""
+func missingPermission(role *domain.Role, permissions []string) string {
+	for _, p := range permissions {
+		if !role.HasPermission(p) {
+			return p
+		}
+	}
+	return ""
+}
@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"Blog-API/internal/domain"
+	"errors"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -19,13 +20,13 @@ func NewSessionUseCase(sessionRepo domain.SessionRepository) domain.SessionUseCa
 
 func (s *SessionUseCase) CreateSession(userID primitive.ObjectID, username string, refreshToken string) (*domain.Session, error) {
 	session := &domain.Session{
-		UserID:       userID,
-		Username:     username,
-		Token:        refreshToken, // store the refreshToken
-		IsActive:     true,
-		CreatedAt:    time.Now(),
-		ExpiresAt:    time.Now().Add(7 * 24 * time.Hour), // exp for 7 days
-		LastActivity: time.Now(),
+		UserID:           userID,
+		Username:         username,
+		RefreshTokenHash: hashToken(refreshToken), // only the hash is stored
+		IsActive:         true,
+		CreatedAt:        time.Now(),
+		ExpiresAt:        time.Now().Add(7 * 24 * time.Hour), // exp for 7 days
+		LastActivity:     time.Now(),
 	}
 	if err := s.sessionRepo.Create(session); err != nil {
 		return nil, err
@@ -45,10 +46,31 @@ func (s *SessionUseCase) CleanupExpiredSessions() error {
 	return s.sessionRepo.DeleteExpired()
 }
 
-func (s *SessionUseCase) UpdateSessionActivity(userID primitive.ObjectID) error {
-	session, err := s.sessionRepo.GetByUserID(userID)
+func (s *SessionUseCase) UpdateSessionActivity(sessionID primitive.ObjectID) error {
+	return s.sessionRepo.UpdateLastActivity(sessionID)
+}
+
+// lists the user's signed-in devices, flagging the one making the request
+func (s *SessionUseCase) ListSessions(userID, currentSessionID primitive.ObjectID) ([]*domain.Session, error) {
+	sessions, err := s.sessionRepo.ListActiveByUserID(userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return s.sessionRepo.UpdateLastActivity(session.ID)
+	for _, session := range sessions {
+		session.Current = session.ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+func (s *SessionUseCase) RevokeSession(userID, sessionID primitive.ObjectID) error {
+	session, err := s.sessionRepo.GetByID(sessionID)
+	// someone else's session is reported as missing rather than forbidden
+	if err != nil || session.UserID != userID {
+		return errors.New("session not found")
+	}
+	return s.sessionRepo.Delete(sessionID)
+}
+
+func (s *SessionUseCase) RevokeOtherSessions(userID, currentSessionID primitive.ObjectID) error {
+	return s.sessionRepo.DeleteByUserIDExcept(userID, currentSessionID)
 }
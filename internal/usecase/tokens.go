@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"Blog-API/internal/domain"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+)
+
+// tokens are stored as SHA-256 hashes so a database leak doesn't hand out live credentials
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// lifetimes of the tokens sent by email
+const (
+	verificationTokenExpiry  = 24 * time.Hour
+	passwordResetTokenExpiry = time.Hour
+	emailChangeTokenExpiry   = 24 * time.Hour
+)
+
+// reset emails per address, so the endpoint can't be used to flood an inbox
+const (
+	passwordResetEmailLimit = 3
+	passwordResetWindow     = 15 * time.Minute
+)
+
+// failed redemptions (e.g. a rejected new password) before a token stops working
+const maxTokenAttempts = 5
+
+// stores a new single-use token and returns it in the clear for the email.
+// Any unused token of the user for the same purpose stops working.
+func (u *UserUseCase) issueToken(record *domain.OneTimeToken, expiry time.Duration) (string, error) {
+	token := u.passwordService.GenerateSecureToken(32)
+	record.TokenHash = hashToken(token)
+	record.ExpiresAt = time.Now().Add(expiry)
+	if err := u.tokenRepo.Create(record); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (u *UserUseCase) tokenAttemptFailed(token *domain.OneTimeToken) {
+	if err := u.tokenRepo.IncrementAttempts(token.ID); err != nil {
+		log.Printf("Failed to record attempt on token %s: %v", token.ID.Hex(), err)
+	}
+}
@@ -0,0 +1,296 @@
+package usecase
+
+import (
+	"Blog-API/internal/domain"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	recoveryCodeCount = 10
+	// codes that may be tried per login challenge before the user has to start over
+	maxTwoFactorAttempts = 5
+)
+
+var (
+	errInvalidTwoFactorCode  = errors.New("invalid two-factor code")
+	errPasswordResetRequired = errors.New("a password reset is required, please use the link we emailed you or request a new one")
+)
+
+// issues tokens, or a challenge when the account has a second factor. Failed
+// logins are only forgotten once every factor has been checked.
+func (u *UserUseCase) completeLogin(user *domain.User, method string, client domain.ClientInfo) (*domain.LoginResponse, error) {
+	// checked again in startSession; this one saves handing out a pointless challenge
+	if err := u.checkPasswordReset(user, method, client); err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled() {
+		challenge, err := u.jwtService.GenerateChallengeToken(user)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.LoginResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		}, nil
+	}
+
+	u.resetLoginFailures(user)
+	resp, err := u.startSession(user, method, client)
+	if err != nil {
+		return nil, err
+	}
+	resp.TwoFactorSetupRequired = u.privileged(user.Role)
+	return resp, nil
+}
+
+// second login step: exchanges the challenge token and a TOTP or recovery code for tokens
+func (u *UserUseCase) VerifyTwoFactorLogin(challengeToken, code string, client domain.ClientInfo) (*domain.LoginResponse, error) {
+	claims, err := u.jwtService.ValidateToken(challengeToken)
+	if err != nil || claims.TokenType != domain.TokenTypeTwoFactorChallenge {
+		return nil, errors.New("invalid or expired challenge token")
+	}
+
+	ctx := context.Background()
+	// counted before the code is checked, so parallel guesses can't slip past the limit
+	attempts, err := u.cache.Increment(ctx, "2fa:attempts:"+claims.ID, time.Until(time.Unix(claims.Exp, 0)))
+	if err != nil {
+		return nil, err
+	}
+	if attempts > maxTwoFactorAttempts {
+		return nil, errors.New("too many invalid codes, please log in again")
+	}
+
+	user, err := u.userRepo.GetByID(claims.UserID)
+	if err != nil || !user.TwoFactorEnabled() || claims.Version != user.TokenVersion {
+		return nil, errors.New("invalid or expired challenge token")
+	}
+	// new challenges don't bring new guesses; wrong codes count towards the account lockout
+	wait, locked, err := u.loginThrottler.Check(ctx, user.Email, client.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		return nil, &domain.RateLimitError{RetryAfter: wait, Reason: "account is temporarily locked after too many failed login attempts"}
+	}
+	if wait > 0 {
+		return nil, &domain.RateLimitError{RetryAfter: wait, Reason: "too many failed login attempts"}
+	}
+	if restriction := user.ActiveRestriction(); restriction != nil {
+		u.recordLogin(user, domain.LoginMethodTwoFactor, client, restriction)
+		return nil, restriction
+	}
+
+	if err := u.verifySecondFactor(user, code); err != nil {
+		u.audit.Record(auditEntry(domain.Actor{UserID: user.ID, Role: user.Role, Client: client}, domain.AuditLogin, domain.AuditTargetUser, user.ID.Hex()), err)
+		u.recordLogin(user, domain.LoginMethodTwoFactor, client, err)
+		if err == errInvalidTwoFactorCode {
+			u.countLoginFailure(ctx, user, user.Email, client)
+		}
+		return nil, err
+	}
+
+	// a challenge is good for one login
+	if err := u.jwtService.RevokeToken(claims); err != nil {
+		return nil, err
+	}
+	u.resetLoginFailures(user)
+	return u.startSession(user, domain.LoginMethodTwoFactor, client)
+}
+
+// set by the "this wasn't me" link: no login method gets tokens until the
+// owner has reset the password
+func (u *UserUseCase) checkPasswordReset(user *domain.User, method string, client domain.ClientInfo) error {
+	if !user.PasswordResetRequired {
+		return nil
+	}
+	u.recordLogin(user, method, client, errPasswordResetRequired)
+	return errPasswordResetRequired
+}
+
+func (u *UserUseCase) resetLoginFailures(user *domain.User) {
+	if err := u.loginThrottler.Reset(context.Background(), user.Email); err != nil {
+		log.Printf("Failed to reset login failures: %v", err)
+	}
+}
+
+func (u *UserUseCase) GetTwoFactorStatus(userID primitive.ObjectID) (*domain.TwoFactorStatus, error) {
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &domain.TwoFactorStatus{
+		Enabled:  user.TwoFactorEnabled(),
+		Required: u.privileged(user.Role),
+	}
+	if status.Enabled {
+		status.RecoveryCodesRemaining = len(user.TwoFactor.RecoveryCodes)
+	}
+	return status, nil
+}
+
+// generates a new secret; two-factor authentication is only switched on once
+// a code from it has been confirmed. Calling it again replaces an unconfirmed secret.
+func (u *UserUseCase) SetupTwoFactor(userID primitive.ObjectID) (*domain.TwoFactorSetupResponse, error) {
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled() {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := u.totpService.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.userRepo.SetTwoFactor(userID, &domain.TwoFactor{Secret: secret}); err != nil {
+		return nil, err
+	}
+	return &domain.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: u.totpService.ProvisioningURI(secret, user.Email),
+	}, nil
+}
+
+// enables two-factor authentication and returns the recovery codes, which are only shown this once
+func (u *UserUseCase) ConfirmTwoFactor(userID primitive.ObjectID, code string, client domain.ClientInfo) (codes []string, err error) {
+	entry := auditEntry(domain.Actor{UserID: userID, Client: client}, domain.AuditTwoFactorEnable, domain.AuditTargetUser, userID.Hex())
+	defer func() { u.audit.Record(entry, err) }()
+
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	entry.ActorRole = user.Role
+	if user.TwoFactor == nil {
+		return nil, errors.New("two-factor authentication has not been set up")
+	}
+	if user.TwoFactor.Enabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	step, ok := u.totpService.Validate(user.TwoFactor.Secret, code, time.Now())
+	if !ok {
+		return nil, errInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	twoFactor := &domain.TwoFactor{
+		Secret:        user.TwoFactor.Secret,
+		Enabled:       true,
+		EnabledAt:     &now,
+		RecoveryCodes: hashes,
+		LastUsedStep:  step,
+	}
+	if err := u.userRepo.SetTwoFactor(userID, twoFactor); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (u *UserUseCase) DisableTwoFactor(userID primitive.ObjectID, code string, client domain.ClientInfo) (err error) {
+	entry := auditEntry(domain.Actor{UserID: userID, Client: client}, domain.AuditTwoFactorDisable, domain.AuditTargetUser, userID.Hex())
+	defer func() { u.audit.Record(entry, err) }()
+
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	entry.ActorRole = user.Role
+	if u.privileged(user.Role) {
+		return errors.New("users with the " + user.Role + " role cannot disable two-factor authentication")
+	}
+	if !user.TwoFactorEnabled() {
+		return errors.New("two-factor authentication is not enabled")
+	}
+	if err := u.verifySecondFactor(user, code); err != nil {
+		return err
+	}
+	return u.userRepo.ClearTwoFactor(userID)
+}
+
+// replaces all recovery codes; the old ones stop working
+func (u *UserUseCase) RegenerateRecoveryCodes(userID primitive.ObjectID, code string) ([]string, error) {
+	user, err := u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled() {
+		return nil, errors.New("two-factor authentication is not enabled")
+	}
+	if err := u.verifySecondFactor(user, code); err != nil {
+		return nil, err
+	}
+
+	// re-read so the step just consumed isn't rolled back
+	user, err = u.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	user.TwoFactor.RecoveryCodes = hashes
+	if err := u.userRepo.SetTwoFactor(userID, user.TwoFactor); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// accepts a current TOTP code or an unused recovery code, each only once
+func (u *UserUseCase) verifySecondFactor(user *domain.User, code string) error {
+	code = strings.TrimSpace(code)
+	if step, ok := u.totpService.Validate(user.TwoFactor.Secret, code, time.Now()); ok {
+		fresh, err := u.userRepo.ConsumeTOTPStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return errInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	used, err := u.userRepo.ConsumeRecoveryCode(user.ID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return errInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// recovery codes look like "k7d2q-mx4pa"; case and the dash don't matter when entered
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
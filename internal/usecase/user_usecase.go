@@ -2,9 +2,12 @@ package usecase
 
 import (
 	"Blog-API/internal/domain"
+	"context"
 	"errors"
 	"fmt"
+	"log"
 	"mime/multipart"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -19,6 +22,35 @@ type UserUseCase struct {
 	fileService     domain.FileService
 	workerPool      domain.WorkerPool
 	oauthService    domain.OAuthService
+	securityEvents  domain.SecurityEventRepository
+	totpService     domain.TOTPService
+	cache           domain.Cache
+	rateLimiter     domain.RateLimiter
+	loginThrottler  domain.LoginThrottler
+	tokenRepo       domain.OneTimeTokenRepository
+	oauthState      domain.OAuthStateService
+	directory       domain.DirectoryService // nil when LDAP login is off
+	accessTokens    domain.PersonalAccessTokenRepository
+	permissions     domain.PermissionChecker
+	audit           domain.AuditLog
+	loginHistory    domain.LoginHistoryRepository
+	secrets         domain.SecretSealer
+}
+
+// collaborators for the login methods, auditing and account security
+type UserUseCaseDeps struct {
+	OAuth          domain.OAuthService
+	OAuthState     domain.OAuthStateService
+	TOTP           domain.TOTPService
+	Directory      domain.DirectoryService
+	RateLimiter    domain.RateLimiter
+	LoginThrottler domain.LoginThrottler
+	SecurityEvents domain.SecurityEventRepository
+	LoginHistory   domain.LoginHistoryRepository
+	AccessTokens   domain.PersonalAccessTokenRepository
+	Permissions    domain.PermissionChecker
+	Audit          domain.AuditLog
+	Secrets        domain.SecretSealer
 }
 
 func NewUserUseCase(
@@ -26,20 +58,35 @@ func NewUserUseCase(
 	passwordService domain.PasswordService,
 	jwtService domain.JWTService,
 	sessionRepo domain.SessionRepository,
+	tokenRepo domain.OneTimeTokenRepository,
 	emailService domain.EmailService,
 	fileService domain.FileService,
 	workerPool domain.WorkerPool,
-	oauthService domain.OAuthService,
+	cache domain.Cache,
+	deps UserUseCaseDeps,
 ) domain.UserUseCase {
 	return &UserUseCase{
 		userRepo:        userRepo,
 		passwordService: passwordService,
 		jwtService:      jwtService,
 		sessionRepo:     sessionRepo,
+		tokenRepo:       tokenRepo,
 		emailService:    emailService,
 		fileService:     fileService,
 		workerPool:      workerPool,
-		oauthService:    oauthService,
+		cache:           cache,
+		oauthService:    deps.OAuth,
+		oauthState:      deps.OAuthState,
+		totpService:     deps.TOTP,
+		directory:       deps.Directory,
+		rateLimiter:     deps.RateLimiter,
+		loginThrottler:  deps.LoginThrottler,
+		securityEvents:  deps.SecurityEvents,
+		loginHistory:    deps.LoginHistory,
+		accessTokens:    deps.AccessTokens,
+		permissions:     deps.Permissions,
+		audit:           deps.Audit,
+		secrets:         deps.Secrets,
 	}
 }
 
@@ -79,43 +126,143 @@ func (u *UserUseCase) Register(username, email, password string) (*domain.User,
 	return user, nil
 }
 
-func (u *UserUseCase) Login(email, password string) (*domain.LoginResponse, error) {
+func (u *UserUseCase) Login(email, password string, client domain.ClientInfo) (*domain.LoginResponse, error) {
+	ctx := context.Background()
+	wait, locked, err := u.loginThrottler.Check(ctx, email, client.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		return nil, &domain.RateLimitError{RetryAfter: wait, Reason: "account is temporarily locked after too many failed login attempts"}
+	}
+	if wait > 0 {
+		return nil, &domain.RateLimitError{RetryAfter: wait, Reason: "too many failed login attempts"}
+	}
+
 	user, err := u.userRepo.GetByEmail(email)
 	if err != nil {
+		// unknown addresses count too, so lockouts don't reveal which accounts exist
+		u.loginFailed(ctx, nil, email, domain.LoginMethodPassword, client)
 		return nil, errors.New("invalid email or password")
 	}
 
 	if !u.passwordService.CheckPassword(password, user.Password) {
+		u.loginFailed(ctx, user, email, domain.LoginMethodPassword, client)
 		return nil, errors.New("invalid email or password")
 	}
+	u.rehashPassword(user, password)
+	if restriction := user.ActiveRestriction(); restriction != nil {
+		u.recordLogin(user, domain.LoginMethodPassword, client, restriction)
+		return nil, restriction
+	}
+
+	return u.completeLogin(user, domain.LoginMethodPassword, client)
+}
+
+// upgrades bcrypt and outdated argon2id hashes now that we have the plaintext.
+// The password itself is unchanged, so this doesn't bump the token version.
+func (u *UserUseCase) rehashPassword(user *domain.User, password string) {
+	if !u.passwordService.NeedsRehash(user.Password) {
+		return
+	}
+	hash, err := u.passwordService.HashPassword(password)
+	if err != nil {
+		log.Printf("Failed to rehash password for user %s: %v", user.ID.Hex(), err)
+		return
+	}
+	if err := u.userRepo.UpdateProfile(user.ID, map[string]interface{}{"password": hash}); err != nil {
+		log.Printf("Failed to store rehashed password for user %s: %v", user.ID.Hex(), err)
+		return
+	}
+	user.Password = hash
+}
+
+// counts a failed password login and tells the owner if it locked their account
+func (u *UserUseCase) loginFailed(ctx context.Context, user *domain.User, email, method string, client domain.ClientInfo) {
+	entry := auditEntry(domain.Actor{Client: client}, domain.AuditLogin, domain.AuditTargetUser, "")
+	if user != nil {
+		entry.TargetID = user.ID.Hex()
+		u.recordLogin(user, method, client, errors.New("invalid credentials"))
+	}
+	u.audit.Record(entry, errors.New("invalid credentials for "+email))
+	u.countLoginFailure(ctx, user, email, client)
+}
+
+// counts a failed password or second factor towards the account's lockout,
+// and tells the owner when it locks
+func (u *UserUseCase) countLoginFailure(ctx context.Context, user *domain.User, email string, client domain.ClientInfo) {
+	locked, err := u.loginThrottler.RecordFailure(ctx, email, client.IPAddress)
+	if err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+		return
+	}
+	if !locked || user == nil {
+		return
+	}
+
+	lockedFor, _, err := u.loginThrottler.Check(ctx, email, client.IPAddress)
+	if err != nil {
+		log.Printf("Failed to read lockout: %v", err)
+	}
+	lockedUntil := time.Now().Add(lockedFor)
+	log.Printf("Account %s locked after repeated failed logins", user.ID.Hex())
+
+	event := &domain.SecurityEvent{
+		UserID:    user.ID,
+		Type:      domain.SecurityEventAccountLocked,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Details:   "too many failed login attempts; locked until " + lockedUntil.UTC().Format(time.RFC3339),
+	}
+	if err := u.securityEvents.Create(event); err != nil {
+		log.Printf("Failed to record security event: %v", err)
+	}
+	if err := u.workerPool.TrySubmit(&EmailJob{
+		EmailService: u.emailService,
+		Secrets:      u.secrets,
+		Type:         "account_locked",
+		Email:        user.Email,
+		Username:     user.Username,
+		LockedUntil:  lockedUntil,
+	}); err != nil {
+		log.Printf("Failed to queue account locked email: %v", err)
+	}
+}
+
+// creates a new device session and issues tokens bound to it
+func (u *UserUseCase) startSession(user *domain.User, method string, client domain.ClientInfo) (*domain.LoginResponse, error) {
+	if err := u.checkPasswordReset(user, method, client); err != nil {
+		return nil, err
+	}
+	session := &domain.Session{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID,
+		Username:  user.Username,
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+		IsActive:  true,
+		ExpiresAt: time.Now().Add(time.Hour * 24 * 7), // exp in 7 days
+	}
 
 	// Generate access token
-	accessToken, err := u.jwtService.GenerateAccessToken(user.ID, user.Email, user.Role)
+	accessToken, err := u.jwtService.GenerateAccessToken(user, session.ID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Generate refresh token
-	refreshToken, err := u.jwtService.GenerateRefreshToken(user.ID, user.Email, user.Role)
+	refreshToken, err := u.jwtService.GenerateRefreshToken(user, session.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create session with refresh token
-	session := &domain.Session{
-		UserID:       user.ID,
-		Username:     user.Username,
-		Token:        refreshToken,
-		IsActive:     true,
-		CreatedAt:    time.Now(),
-		ExpiresAt:    time.Now().Add(time.Hour * 24 * 7), // exp in 7 days
-		LastActivity: time.Now(),
-	}
+	session.RefreshTokenHash = hashToken(refreshToken)
 	if err := u.sessionRepo.Create(session); err != nil {
 		return nil, err
 	}
+	u.audit.Record(auditEntry(domain.Actor{UserID: user.ID, Role: user.Role, Client: client}, domain.AuditLogin, domain.AuditTargetUser, user.ID.Hex()), nil)
+	u.recordLogin(user, method, client, nil)
 
-	// Return login response
 	return &domain.LoginResponse{
 		User:         user,
 		AccessToken:  accessToken,
@@ -147,13 +294,16 @@ func (u *UserUseCase) UpdateProfile(id primitive.ObjectID, req *domain.UpdatePro
 		updates["username"] = *req.Username
 	}
 
-	// Handle email update with uniqueness check
+	// Handle email update with uniqueness check. The new address only takes
+	// effect once confirmed, see requestEmailChange.
 	if req.Email != nil && *req.Email != currentUser.Email {
 		// Check if email already exists (excluding current user)
 		if existingUser, _ := u.userRepo.GetByEmail(*req.Email); existingUser != nil && existingUser.ID != id {
 			return nil, errors.New("email already exists")
 		}
-		updates["email"] = *req.Email
+		if err := u.requestEmailChange(currentUser, *req.Email); err != nil {
+			return nil, err
+		}
 	}
 
 	// Handle bio update
@@ -187,16 +337,21 @@ func (u *UserUseCase) CheckPassword(password, hash string) bool {
 	return u.passwordService.CheckPassword(password, hash)
 }
 
-func (u *UserUseCase) RefreshToken(refreshToken string) (*domain.LoginResponse, error) {
-	// RefreshToken refreshes an access token using a refresh token
+// exchanges a refresh token for a new access and refresh token pair. Each refresh
+// token works once; presenting a retired one revokes the session it belongs to.
+func (u *UserUseCase) RefreshToken(refreshToken string, client domain.ClientInfo) (resp *domain.LoginResponse, err error) {
 	claims, err := u.jwtService.ValidateToken(refreshToken)
-	if err != nil {
+	if err != nil || claims.TokenType != domain.TokenTypeRefresh {
 		return nil, errors.New("invalid refresh token")
 	}
+	// the token is genuine, so the attempt goes in its owner's history
+	defer func() {
+		u.recordLogin(&domain.User{ID: claims.UserID}, domain.LoginMethodRefresh, client, err)
+	}()
 
-	// Get the corresponding session from the database
-	session, err := u.sessionRepo.GetByUserID(claims.UserID)
-	if err != nil {
+	// Get the session this refresh token was issued for
+	session, err := u.sessionRepo.GetByID(claims.SessionID)
+	if err != nil || session.UserID != claims.UserID {
 		return nil, errors.New("session not found")
 	}
 
@@ -205,52 +360,96 @@ func (u *UserUseCase) RefreshToken(refreshToken string) (*domain.LoginResponse,
 		return nil, errors.New("session is expired or inactive")
 	}
 
+	// a genuine token for this session that isn't the current one has been rotated out
+	presentedHash := hashToken(refreshToken)
+	if session.RefreshTokenHash != presentedHash {
+		return nil, u.revokeReusedSession(session, client)
+	}
+
 	// Get the full user details
 	user, err := u.userRepo.GetByID(claims.UserID)
 	if err != nil {
 		return nil, errors.New("user not found")
 	}
+	// issued before a password or role change
+	if claims.Version != user.TokenVersion {
+		return nil, errors.New("refresh token is no longer valid, please log in again")
+	}
+	if restriction := user.ActiveRestriction(); restriction != nil {
+		return nil, restriction
+	}
 
-	// Generate new access token
-	newAccessToken, err := u.jwtService.GenerateAccessToken(user.ID, user.Email, user.Role)
+	newAccessToken, err := u.jwtService.GenerateAccessToken(user, session.ID)
 	if err != nil {
 		return nil, err
 	}
-	// Update last active time on the session
-	err = u.sessionRepo.UpdateLastActivity(session.ID)
+	newRefreshToken, err := u.jwtService.GenerateRefreshToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := u.sessionRepo.RotateRefreshToken(session.ID, presentedHash, hashToken(newRefreshToken))
 	if err != nil {
 		return nil, err
 	}
+	if !rotated {
+		// another request rotated the same token first
+		return nil, u.revokeReusedSession(session, client)
+	}
 
-	// Return the response with the new access token and the original refresh token
 	return &domain.LoginResponse{
 		User:         user,
 		AccessToken:  newAccessToken,
-		RefreshToken: refreshToken,
+		RefreshToken: newRefreshToken,
 	}, nil
 }
 
-func (u *UserUseCase) Logout(userID primitive.ObjectID) error {
-	return u.sessionRepo.DeleteByUserID(userID)
+// treats a replayed refresh token as stolen: the session and every token issued for it are revoked
+func (u *UserUseCase) revokeReusedSession(session *domain.Session, client domain.ClientInfo) error {
+	log.Printf("Refresh token reuse detected for user %s, session %s; revoking session", session.UserID.Hex(), session.ID.Hex())
+	if err := u.sessionRepo.Delete(session.ID); err != nil {
+		log.Printf("Failed to revoke session %s: %v", session.ID.Hex(), err)
+	}
+	event := &domain.SecurityEvent{
+		UserID:    session.UserID,
+		SessionID: session.ID,
+		Type:      domain.SecurityEventRefreshTokenReuse,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Details:   "a refresh token was used after it had been rotated; the session was revoked",
+	}
+	if err := u.securityEvents.Create(event); err != nil {
+		log.Printf("Failed to record security event: %v", err)
+	}
+	return errors.New("refresh token has already been used, the session has been revoked")
+}
 
+// ends the current device's session only; the access token used for the
+// request is denylisted so it can't be replayed until it expires
+func (u *UserUseCase) Logout(claims *domain.JWTClaims) error {
+	session, err := u.sessionRepo.GetByID(claims.SessionID)
+	if err != nil || session.UserID != claims.UserID {
+		return errors.New("session not found")
+	}
+	if err := u.jwtService.RevokeToken(claims); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	return u.sessionRepo.Delete(session.ID)
 }
 
 func (u *UserUseCase) VerifyEmail(token string) error {
-	session, err := u.sessionRepo.GetByVerificationToken(token)
+	record, err := u.tokenRepo.GetValid(domain.TokenPurposeEmailVerification, hashToken(token))
 	if err != nil {
 		return errors.New("invalid or expired verification token")
 	}
-	
-	if time.Now().After(session.VerificationTokenExpiresAt) {
-		return errors.New("invalid or expired verification token")
-	}
-
-	if err := u.userRepo.UpdateEmailVerificationStatus(session.UserID, true); err != nil {
+	used, err := u.tokenRepo.MarkUsed(record.ID)
+	if err != nil {
 		return err
 	}
-
-	session.VerificationToken = ""
-	return u.sessionRepo.Update(session)
+	if !used {
+		return errors.New("invalid or expired verification token")
+	}
+	return u.userRepo.UpdateEmailVerificationStatus(record.UserID, true)
 }
 
 func (u *UserUseCase) SendVerificationEmail(email string) error {
@@ -263,114 +462,164 @@ func (u *UserUseCase) SendVerificationEmail(email string) error {
 		return errors.New("email is already verified")
 	}
 
-	verificationToken := u.passwordService.GenerateSecureToken(32)
-
-	session := &domain.Session{
-		UserID:                     user.ID,
-		Username:                   user.Username,
-		VerificationToken:          verificationToken,
-		VerificationTokenExpiresAt: time.Now().Add(24 * time.Hour),
-		IsActive:                   false, // this is not a login session
-	}
-	
-	existingSession, _ := u.sessionRepo.GetByUserID(user.ID)
-	if existingSession != nil {
-		existingSession.VerificationToken = session.VerificationToken
-		existingSession.VerificationTokenExpiresAt = session.VerificationTokenExpiresAt
-		if err := u.sessionRepo.Update(existingSession); err != nil {
-			return err
-		}
-	} else {
-		if err := u.sessionRepo.Create(session); err != nil {
-			return err
-		}
+	verificationToken, err := u.issueToken(&domain.OneTimeToken{UserID: user.ID, Purpose: domain.TokenPurposeEmailVerification}, verificationTokenExpiry)
+	if err != nil {
+		return err
 	}
-	// send the email in Background
-	u.workerPool.Submit(&EmailJob{
+	// send the email in Background. A full queue gets the same answer as an
+	// unknown address, otherwise a 503 would tell the two apart.
+	if err := u.workerPool.TrySubmit(&EmailJob{
 		EmailService: u.emailService,
+		Secrets:      u.secrets,
 		Type:         "verification",
 		Email:        user.Email,
 		Username:     user.Username,
 		Token:        verificationToken,
-	})
-	go u.emailService.SendVerificationEmail(user.Email, user.Username, verificationToken)
-
+	}); err != nil {
+		log.Printf("Failed to queue verification email for user %s: %v", user.ID.Hex(), err)
+		return errors.New("if a user with this email exists, a verification email has been sent")
+	}
 	return nil
 }
 
 func (u *UserUseCase) SendPasswordResetEmail(email string) error {
+	key := "password_reset:email:" + strings.ToLower(strings.TrimSpace(email))
+	if err := u.allow(context.Background(), key, passwordResetEmailLimit, passwordResetWindow); err != nil {
+		return err
+	}
+
 	user, err := u.userRepo.GetByEmail(email)
 	if err != nil {
 		return errors.New("if a user with this email exists, a password reset email has been sent")
 	}
 
-	resetToken := u.passwordService.GenerateSecureToken(32)
-
-	session, err := u.sessionRepo.GetByUserID(user.ID)
-	if err != nil || session == nil {
-		session = &domain.Session{UserID: user.ID, Username: user.Username}
-	}
-
-	session.PasswordResetToken = resetToken
-	session.ResetTokenExpiresAt = time.Now().Add(1 * time.Hour)
+	resetToken, err := u.issueToken(&domain.OneTimeToken{UserID: user.ID, Purpose: domain.TokenPurposePasswordReset}, passwordResetTokenExpiry)
 	if err != nil {
-		if err := u.sessionRepo.Create(session); err != nil {
-			return err
-		}
-	} else {
-		if err := u.sessionRepo.Update(session); err != nil {
-			return err
-		}
+		return err
 	}
-
-	//go u.emailService.SendPasswordResetEmail(user.Email, user.Username, resetToken)
-	u.workerPool.Submit(&EmailJob{
+	// same answer as an unknown address when the queue is full
+	if err := u.workerPool.TrySubmit(&EmailJob{
 		EmailService: u.emailService,
+		Secrets:      u.secrets,
 		Type:         "password_reset",
 		Email:        user.Email,
 		Username:     user.Username,
 		Token:        resetToken,
-	})
+	}); err != nil {
+		log.Printf("Failed to queue password reset email for user %s: %v", user.ID.Hex(), err)
+		return errors.New("if a user with this email exists, a password reset email has been sent")
+	}
 	return nil
 }
 
-func (u *UserUseCase) ResetPassword(token, newPassword string) error {
-	if err := u.passwordService.ValidatePassword(newPassword); err != nil {
-		return err
+func (u *UserUseCase) ResetPassword(token, newPassword string, client domain.ClientInfo) (err error) {
+	record, err := u.tokenRepo.GetValid(domain.TokenPurposePasswordReset, hashToken(token))
+	if err != nil || record.Attempts >= maxTokenAttempts {
+		return errors.New("invalid or expired password reset token")
 	}
 
-	session, err := u.sessionRepo.GetByResetToken(token)
+	// whoever holds the token acts as the user
+	entry := auditEntry(domain.Actor{UserID: record.UserID, Client: client}, domain.AuditPasswordReset, domain.AuditTargetUser, record.UserID.Hex())
+	defer func() { u.audit.Record(entry, err) }()
+
+	user, err := u.userRepo.GetByID(record.UserID)
 	if err != nil {
-		return errors.New("invalid or expired passoword reset token")
+		return err
 	}
-	if time.Now().After(session.ResetTokenExpiresAt) {
-		return errors.New("invalid or expired password reset token")
+	entry.ActorRole = user.Role
+
+	if err := u.passwordService.ValidatePassword(newPassword); err != nil {
+		u.tokenAttemptFailed(record)
+		return err
+	}
+	if u.passwordRecentlyUsed(user, newPassword) {
+		u.tokenAttemptFailed(record)
+		return errors.New("password was used recently, please choose a different one")
 	}
 
 	hashedPassword, err := u.passwordService.HashPassword(newPassword)
 	if err != nil {
 		return err
 	}
-	if err := u.userRepo.UpdatePassword(session.UserID, hashedPassword); err != nil {
+	used, err := u.tokenRepo.MarkUsed(record.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return errors.New("invalid or expired password reset token")
+	}
+	if err := u.userRepo.UpdatePassword(user.ID, hashedPassword, u.passwordHistory(user)); err != nil {
 		return err
 	}
-	session.PasswordResetToken = ""
-	return u.sessionRepo.Update(session)
+	// the new version retires JWTs; access tokens have to go as well
+	if err := u.accessTokens.DeleteByUserID(user.ID); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+	return nil
 }
 
-func (u *UserUseCase) UpdateRole(adminUserID, targetUserID primitive.ObjectID, role string) error {
-	adminUser, err := u.userRepo.GetByID(adminUserID)
+// checks the current password and the kept history
+func (u *UserUseCase) passwordRecentlyUsed(user *domain.User, password string) bool {
+	if user.Password != "" && u.passwordService.CheckPassword(password, user.Password) {
+		return true
+	}
+	for _, hash := range user.PasswordHistory {
+		if u.passwordService.CheckPassword(password, hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// the history to store once the current password is replaced
+func (u *UserUseCase) passwordHistory(user *domain.User) []string {
+	size := u.passwordService.HistorySize()
+	if size <= 0 {
+		return []string{}
+	}
+	history := user.PasswordHistory
+	if user.Password != "" {
+		history = append([]string{user.Password}, history...)
+	}
+	if len(history) > size {
+		history = history[:size]
+	}
+	return history
+}
+
+// gives the target any built-in or custom role the admin is allowed to assign
+func (u *UserUseCase) UpdateRole(actor domain.Actor, targetUserID primitive.ObjectID, role string) (err error) {
+	entry := auditEntry(actor, domain.AuditUserRoleChange, domain.AuditTargetUser, targetUserID.Hex())
+	entry.After = map[string]string{"role": role}
+	defer func() { u.audit.Record(entry, err) }()
+
+	adminUser, err := u.userRepo.GetByID(actor.UserID)
 	if err != nil {
 		return errors.New("admin user not found")
 	}
-	if adminUser.Role != domain.RoleAdmin {
+	if actor.UserID == targetUserID {
+		return errors.New("forbidden: you cannot change your own role")
+	}
+	targetUser, err := u.userRepo.GetByID(targetUserID)
+	if err != nil {
 		return errors.New("target user not found")
 	}
-	if adminUserID == targetUserID && role == domain.RoleUser {
-		return errors.New("admins cannot demote themselves")
+	entry.Before = map[string]string{"role": targetUser.Role}
+	if err := u.permissions.CanAssign(adminUser.Role, targetUser.Role, role); err != nil {
+		return err
 	}
 	return u.userRepo.UpdateRole(targetUserID, role)
 }
+
+// roles with any permission need two-factor authentication; a failed lookup counts as privileged
+func (u *UserUseCase) privileged(role string) bool {
+	ok, err := u.permissions.IsPrivileged(role)
+	if err != nil {
+		log.Printf("Failed to look up role %s: %v", role, err)
+		return true
+	}
+	return ok
+}
 func (u *UserUseCase) UploadProfilePicture(userID primitive.ObjectID, file multipart.File, handler *multipart.FileHeader) (*domain.User, error) {
 	// 1. Save the file using the file service interface.
 	photo, err := u.fileService.SaveProfilePicture(userID, file, handler)
@@ -388,21 +637,13 @@ func (u *UserUseCase) UploadProfilePicture(userID primitive.ObjectID, file multi
 
 // core logic for  OAuth login/registration
 
-func (u *UserUseCase) OAuthLogin(provider, state, code, storedState string) (*domain.LoginResponse, error) {
-	// csrf protection
-	if state != storedState {
-		return nil, errors.New("invalid oauth state")
-	}
-	// exchange code for a token from the provider
-	token, err := u.oauthService.ExchangeCodeForToken(provider, code)
-	if err != nil {
-		return nil, fmt.Errorf("failed to exchange code: %w", err)
-	}
-	// get user info from the provider
-	oauthID, email, username, err := u.oauthService.GetUserInfo(provider, token)
+func (u *UserUseCase) OAuthLogin(provider, code string, flow *domain.OAuthFlow, client domain.ClientInfo) (*domain.LoginResponse, error) {
+	// exchange the code and get user info from the provider
+	info, err := u.oauthService.Exchange(provider, code, flow)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user info: %w", err)
+		return nil, err
 	}
+	oauthID, email, username := info.Subject, info.Email, info.Username
 	// check if a user with this oauth id already exists
 	user, err := u.userRepo.GetByOAuth(provider, oauthID)
 	if err != nil && err.Error() != "user not found" {
@@ -410,56 +651,36 @@ func (u *UserUseCase) OAuthLogin(provider, state, code, storedState string) (*do
 	}
 	// if user does not exist, it's a new registration
 	if user == nil {
+		// linking needs the owner to be logged in, see LinkIdentity
 		existingUser, _ := u.userRepo.GetByEmail(email)
 		if existingUser != nil {
-			return nil, errors.New("user with this email already exists, please log in with your password")
+			return nil, errors.New("user with this email already exists, please log in and link your " + provider + " account from your account settings")
+		}
+		// the account is created with a verified email and nothing else proves
+		// the address belongs to whoever is signing in
+		if !info.EmailVerified {
+			return nil, errors.New("your " + provider + " email address is not verified, please verify it with " + provider + " first")
 		}
 		// create new user
 		newUser := &domain.User{
 			Username:      username,
 			Email:         email,
 			EmailVerified: true,
-			OAuthProvider: provider,
-			OAuthID:       oauthID,
 			Role:          domain.RoleUser,
+			LinkedIdentities: []domain.LinkedIdentity{
+				{Provider: provider, Subject: oauthID, Email: email, LinkedAt: time.Now()},
+			},
 		}
 		if err := u.userRepo.Create(newUser); err != nil {
 			return nil, err
 		}
 		user = newUser
 	}
-
-	//issue our application's own JWTs
-	accessToken, err := u.jwtService.GenerateAccessToken(user.ID, user.Email, user.Role)
-	if err != nil {
-		return nil, err
-	}
-	refreshToken, err := u.jwtService.GenerateRefreshToken(user.ID, user.Email, user.Role)
-	if err != nil {
-		return nil, err
-	}
-	// session creation or updation for refresh token
-	session, _ := u.sessionRepo.GetByUserID(user.ID)
-	if session == nil {
-		session = &domain.Session{UserID: user.ID}
-	}
-	session.Username = user.Username
-	session.Token = refreshToken
-	session.IsActive = true
-	session.CreatedAt = time.Now()
-	session.ExpiresAt = time.Now().Add(time.Hour * 24 * 7)
-
-	//upsert logic for session
-	if _, err := u.sessionRepo.GetByUserID(user.ID); err != nil {
-		u.sessionRepo.Create(session)
-	} else {
-		u.sessionRepo.Update(session)
+	if restriction := user.ActiveRestriction(); restriction != nil {
+		u.recordLogin(user, domain.LoginMethodOAuthPrefix+provider, client, restriction)
+		return nil, restriction
 	}
 
-	return &domain.LoginResponse{
-		User:         user,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
-
+	//issue our application's own JWTs for a new device session
+	return u.completeLogin(user, domain.LoginMethodOAuthPrefix+provider, client)
 }
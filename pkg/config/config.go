@@ -11,14 +11,20 @@ import (
 )
 
 type Config struct {
-	Server  ServerConfig
-	MongoDB MongoDBConfig
-	JWT     JWTConfig
-	Email   EmailConfig
-	Upload  UploadConfig
-	AI      AIConfig
-	Redis   RedisConfig
-	OAuth   OAuthConfig
+	Server    ServerConfig
+	MongoDB   MongoDBConfig
+	JWT       JWTConfig
+	Email     EmailConfig
+	Upload    UploadConfig
+	AI        AIConfig
+	Redis     RedisConfig
+	OAuth     OAuthConfig
+	Worker    WorkerConfig
+	Scheduler SchedulerConfig
+	TwoFactor TwoFactorConfig
+	Login     LoginConfig
+	Password  PasswordConfig
+	LDAP      LDAPConfig
 }
 
 type ServerConfig struct {
@@ -32,9 +38,12 @@ type MongoDBConfig struct {
 }
 
 type JWTConfig struct {
-	Secret        string
-	AccessExpiry  time.Duration
-	RefreshExpiry time.Duration
+	Algorithm            string // HS256, RS256 or EdDSA
+	Secret               string
+	SigningKeyFile       string
+	VerificationKeyFiles []string // retired keys still accepted during rotation
+	AccessExpiry         time.Duration
+	RefreshExpiry        time.Duration
 }
 
 type EmailConfig struct {
@@ -58,6 +67,62 @@ type RedisConfig struct {
 	Password string `mapstructure:"REDIS_PASSWORD"`
 	DB       int    `mapstructure:"REDIS_DB"`
 }
+type WorkerConfig struct {
+	Backend           string // "redis" (durable) or "memory"
+	Workers           int
+	QueueSize         int // per priority lane
+	VisibilityTimeout time.Duration
+	ShutdownTimeout   time.Duration
+	MaxAttempts       int
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration
+	// encrypts one-time tokens in queued emails; must be the same on every instance
+	PayloadSecret string
+}
+type SchedulerConfig struct {
+	Enabled                   bool
+	SessionCleanupSchedule    string
+	DeadLetterCleanupSchedule string
+	DeadLetterRetention       time.Duration
+}
+type PasswordConfig struct {
+	Argon2Memory      int // KiB
+	Argon2Iterations  int
+	Argon2Parallelism int
+	MinLength         int
+	RequiredClasses   []string // any of upper, lower, number, special
+	MinScore          int      // 0-4
+	HistorySize       int
+}
+type LoginConfig struct {
+	MaxFailures     int // per account before it is locked
+	IPMaxFailures   int // per client IP before it is blocked
+	FailureWindow   time.Duration
+	LockoutDuration time.Duration
+}
+
+// directory login; off unless LDAP_URL is set
+type LDAPConfig struct {
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	BindDN             string
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string // {username} is replaced with the escaped login name
+	UsernameAttribute  string
+	EmailAttribute     string
+	IDAttribute        string
+	GroupBaseDN        string
+	GroupFilter        string // {dn} is replaced with the user's escaped DN; empty reads memberOf only
+	AdminGroups        []string
+	RoleGroups         []string // "role=group" pairs, e.g. editor=writers
+	AllowedGroups      []string
+	Timeout            time.Duration
+}
+type TwoFactorConfig struct {
+	Issuer string // shown next to the account in authenticator apps
+}
 type OAuthProvider struct {
 	ClientID     string   `mapstructure:"CLIENT_ID"`
 	ClientSecret string   `mapstructure:"CLIENT_SECRET"`
@@ -67,7 +132,25 @@ type OAuthProvider struct {
 type OAuthConfig struct {
 	Google      OAuthProvider
 	GitHub      OAuthProvider
-	StateSecret string `mapstructure:"OAUTH_STATE_SECRET"`
+	OIDC        []OIDCProvider
+	StateSecret string        `mapstructure:"OAUTH_STATE_SECRET"`
+	StateTTL    time.Duration `mapstructure:"OAUTH_STATE_TTL"`
+	// where the callback may send the browser after a login
+	AllowedRedirects []string `mapstructure:"OAUTH_ALLOWED_REDIRECTS"`
+	DefaultRedirect  string   `mapstructure:"OAUTH_DEFAULT_REDIRECT"`
+}
+
+// an OpenID Connect provider, configured with OIDC_<NAME>_* variables
+type OIDCProvider struct {
+	Name          string
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string
+	EmailClaim    string
+	TrustEmail    bool
 }
 
 func Load() *Config {
@@ -85,9 +168,12 @@ func Load() *Config {
 			Database: getEnv("MONGODB_DATABASE", "blog_db"),
 		},
 		JWT: JWTConfig{
-			Secret:        getEnv("JWT_SECRET", "your-super-secret-jwt-key-here"),
-			AccessExpiry:  getDurationEnv("JWT_ACCESS_EXPIRY", 15*time.Minute),
-			RefreshExpiry: getDurationEnv("JWT_REFRESH_EXPIRY", 168*time.Hour), // 7 days
+			Algorithm:            getEnv("JWT_ALGORITHM", "HS256"),
+			Secret:               getEnv("JWT_SECRET", ""),
+			SigningKeyFile:       getEnv("JWT_SIGNING_KEY_FILE", ""),
+			VerificationKeyFiles: getList("JWT_VERIFICATION_KEY_FILES"),
+			AccessExpiry:         getDurationEnv("JWT_ACCESS_EXPIRY", 15*time.Minute),
+			RefreshExpiry:        getDurationEnv("JWT_REFRESH_EXPIRY", 168*time.Hour), // 7 days
 		},
 		Email: EmailConfig{
 			Host:         getEnv("SMTP_HOST", "smtp.gmail.com"),
@@ -110,7 +196,10 @@ func Load() *Config {
 			DB:       getIntEnv("REDIS_DB", 0),
 		},
 		OAuth: OAuthConfig{
-			StateSecret: getEnv("OAUTH_STATE_SECRET", "a-very-secret-string-for-oauth-state-change-me"),
+			StateSecret:      getEnv("OAUTH_STATE_SECRET", ""),
+			StateTTL:         getDurationEnv("OAUTH_STATE_TTL", 10*time.Minute),
+			AllowedRedirects: getList("OAUTH_ALLOWED_REDIRECTS"),
+			DefaultRedirect:  getEnv("OAUTH_DEFAULT_REDIRECT", ""),
 			Google: OAuthProvider{
 				ClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
 				ClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
@@ -123,6 +212,60 @@ func Load() *Config {
 				RedirectURL:  getEnv("GITHUB_REDIRECT_URL", "http://localhost:8080/api/v1/auth/github/callback"),
 				Scopes:       getScopes("GITHUB_SCOPES", "read:user,user:email"),
 			},
+			OIDC: getOIDCProviders(),
+		},
+		Worker: WorkerConfig{
+			Backend:           getEnv("WORKER_BACKEND", "redis"),
+			Workers:           getIntEnv("WORKER_COUNT", 4),
+			QueueSize:         getIntEnv("WORKER_QUEUE_SIZE", 100),
+			VisibilityTimeout: getDurationEnv("WORKER_VISIBILITY_TIMEOUT", 5*time.Minute),
+			ShutdownTimeout:   getDurationEnv("WORKER_SHUTDOWN_TIMEOUT", 30*time.Second),
+			MaxAttempts:       getIntEnv("JOB_MAX_ATTEMPTS", 5),
+			InitialBackoff:    getDurationEnv("JOB_INITIAL_BACKOFF", 30*time.Second),
+			MaxBackoff:        getDurationEnv("JOB_MAX_BACKOFF", 30*time.Minute),
+			PayloadSecret:     getEnv("JOB_PAYLOAD_SECRET", ""),
+		},
+		Scheduler: SchedulerConfig{
+			Enabled:                   getBoolEnv("SCHEDULER_ENABLED", true),
+			SessionCleanupSchedule:    getEnv("SESSION_CLEANUP_SCHEDULE", "@hourly"),
+			DeadLetterCleanupSchedule: getEnv("DEAD_LETTER_CLEANUP_SCHEDULE", "@daily"),
+			DeadLetterRetention:       getDurationEnv("DEAD_LETTER_RETENTION", 30*24*time.Hour),
+		},
+		Password: PasswordConfig{
+			Argon2Memory:      getIntEnv("PASSWORD_ARGON2_MEMORY", 64*1024), // 64 MiB
+			Argon2Iterations:  getIntEnv("PASSWORD_ARGON2_ITERATIONS", 3),
+			Argon2Parallelism: getIntEnv("PASSWORD_ARGON2_PARALLELISM", 2),
+			MinLength:         getIntEnv("PASSWORD_MIN_LENGTH", 8),
+			RequiredClasses:   getScopes("PASSWORD_REQUIRED_CLASSES", "upper,lower,number,special"),
+			MinScore:          getIntEnv("PASSWORD_MIN_SCORE", 2),
+			HistorySize:       getIntEnv("PASSWORD_HISTORY_SIZE", 5),
+		},
+		Login: LoginConfig{
+			MaxFailures:     getIntEnv("LOGIN_MAX_FAILURES", 10),
+			IPMaxFailures:   getIntEnv("LOGIN_IP_MAX_FAILURES", 100),
+			FailureWindow:   getDurationEnv("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+			LockoutDuration: getDurationEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		},
+		TwoFactor: TwoFactorConfig{
+			Issuer: getEnv("TOTP_ISSUER", "Blog API"),
+		},
+		LDAP: LDAPConfig{
+			URL:                getEnv("LDAP_URL", ""),
+			StartTLS:           getBoolEnv("LDAP_START_TLS", false),
+			InsecureSkipVerify: getBoolEnv("LDAP_INSECURE_SKIP_VERIFY", false),
+			BindDN:             getEnv("LDAP_BIND_DN", ""),
+			BindPassword:       getEnv("LDAP_BIND_PASSWORD", ""),
+			BaseDN:             getEnv("LDAP_BASE_DN", ""),
+			UserFilter:         getEnv("LDAP_USER_FILTER", "(uid={username})"),
+			UsernameAttribute:  getEnv("LDAP_USERNAME_ATTRIBUTE", "uid"),
+			EmailAttribute:     getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+			IDAttribute:        getEnv("LDAP_ID_ATTRIBUTE", "entryUUID"),
+			GroupBaseDN:        getEnv("LDAP_GROUP_BASE_DN", ""),
+			GroupFilter:        getEnv("LDAP_GROUP_FILTER", ""),
+			AdminGroups:        getList("LDAP_ADMIN_GROUPS"),
+			RoleGroups:         getList("LDAP_ROLE_GROUPS"),
+			AllowedGroups:      getList("LDAP_ALLOWED_GROUPS"),
+			Timeout:            getDurationEnv("LDAP_TIMEOUT", 5*time.Second),
 		},
 	}
 }
@@ -153,6 +296,15 @@ func getInt64Env(key string, defaultValue int64) int64 {
 	return defaultValue
 }
 
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -165,3 +317,33 @@ func getScopes(key, defaultValue string) []string {
 	value := getEnv(key, defaultValue)
 	return strings.Split(value, ",")
 }
+
+// one provider per name in OIDC_PROVIDERS, e.g. "corp" reads OIDC_CORP_ISSUER
+func getOIDCProviders() []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range getList("OIDC_PROVIDERS") {
+		name = strings.TrimSpace(name)
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providers = append(providers, OIDCProvider{
+			Name:          name,
+			Issuer:        getEnv(prefix+"ISSUER", ""),
+			ClientID:      getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret:  getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:   getEnv(prefix+"REDIRECT_URL", "http://localhost:8080/api/v1/auth/"+name+"/callback"),
+			Scopes:        getScopes(prefix+"SCOPES", "openid,email,profile"),
+			UsernameClaim: getEnv(prefix+"USERNAME_CLAIM", "preferred_username"),
+			EmailClaim:    getEnv(prefix+"EMAIL_CLAIM", "email"),
+			TrustEmail:    getBoolEnv(prefix+"TRUST_EMAIL", false),
+		})
+	}
+	return providers
+}
+
+// comma-separated list, empty when unset
+func getList(key string) []string {
+	value := getEnv(key, "")
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}